@@ -8,16 +8,104 @@ const (
 	GetProductsList     = "/products"
 	GetProducts         = "/products/:id"
 	GetProductsByStocks = "/products/stock/:stock"
+	GetProductsExport   = "/products/export"
 	PostProducts        = "/products"
 	PutProducts         = "/products/:id"
 	DelProducts         = "/products/:id"
 
+	// Routing Imports
+	PostProductsImport = "/products/import"
+	GetImports         = "/imports/:id"
+
+	// Routing Enrollments
+	PostEnrollments = "/products/:id/enroll"
+
+	// Routing Reviews
+	GetProductReviews  = "/products/:id/reviews"
+	PostProductReviews = "/products/:id/reviews"
+	PutReviews         = "/reviews/:id"
+	DelReviews         = "/reviews/:id"
+	PostReviewsHide    = "/reviews/:id/hide"
+	PostReviewsUnhide  = "/reviews/:id/unhide"
+
+	// Routing Wishlist
+	GetWishlist  = "/wishlist"
+	PostWishlist = "/wishlist"
+	DelWishlist  = "/wishlist/:id"
+
+	// Routing Coupons
+	GetCouponsList   = "/coupons"
+	GetCoupons       = "/coupons/:id"
+	PostCoupons      = "/coupons"
+	PostCouponsCheck = "/coupons/validate"
+	PutCoupons       = "/coupons/:id"
+	DelCoupons       = "/coupons/:id"
+
+	// Routing Exchange Rates
+	GetRatesList = "/rates"
+	PutRates     = "/rates"
+
 	// Routing Users
-	GetUsersList = "/profiles"
-	GetUsers     = "/profiles/:id"
+	GetUsersList        = "/profiles"
+	GetUsers            = "/profiles/:id"
+	GetUsersExport      = "/profiles/export"
+	PostUsersUnlock     = "/profiles/:id/unlock"
+	PatchUsers          = "/profiles/:id"
+	PostUsersDeactivate = "/profiles/:id/deactivate"
+	PostUsersActivate   = "/profiles/:id/activate"
+	PostUsersBulk       = "/profiles/bulk"
+	PostUsersErase      = "/profiles/:id/erase"
+	GetUsersSessions    = "/profiles/:id/sessions"
+	DelUsersSessions    = "/profiles/:id/sessions/:session"
+
+	GetProfileMe          = "/profiles/me"
+	PatchProfileMe        = "/profiles/me"
+	DeleteProfileMe       = "/profiles/me"
+	PostProfileMePassword = "/profiles/me/password"
+
+	PostProfileMeDataExport = "/profiles/me/data-export"
+	GetProfileMeDataExport  = "/profiles/me/data-export/:id"
+	GetDataExportDownload   = "/data-exports/download"
+
+	// Routing API Keys
+	PostAPIKeys = "/api-keys"
+	GetAPIKeys  = "/api-keys"
+	DelAPIKeys  = "/api-keys/:id"
+
+	// Routing OAuth
+	PostOAuthClients    = "/oauth/clients"
+	GetOAuthClients     = "/oauth/clients"
+	DelOAuthClients     = "/oauth/clients/:id"
+	GetOAuthAuthorize   = "/oauth/authorize"
+	PostOAuthAuthorize  = "/oauth/authorize"
+	PostOAuthToken      = "/oauth/token"
+	PostOAuthIntrospect = "/oauth/introspect"
+	PostOAuthRevoke     = "/oauth/revoke"
+
+	// Routing Impersonation
+	PostImpersonate = "/admin/impersonate/:userId"
+
+	// Routing Audit Logs
+	GetAuditLogs       = "/audit-logs"
+	GetAuditLogsVerify = "/audit-logs/verify"
 
 	// Routing Auth
 	PostRegister = "/auth/register"
 	PostLogin    = "/auth/login"
 	GetLogout    = "/auth/logout"
+
+	GetSessions = "/auth/sessions"
+	DelSessions = "/auth/sessions/:id"
+
+	PostTwoFactorSetup  = "/auth/2fa/setup"
+	PostTwoFactorVerify = "/auth/2fa/verify"
+	PostTwoFactorLogin  = "/auth/2fa/login"
+
+	PostVerifyEmail       = "/auth/verify-email"
+	PostResendVerifyEmail = "/auth/verify-email/resend"
+	PostForgotPassword    = "/auth/forgot-password"
+	PostResetPassword     = "/auth/reset-password"
+
+	GetOIDCStart    = "/auth/oidc/:provider/start"
+	GetOIDCCallback = "/auth/oidc/:provider/callback"
 )
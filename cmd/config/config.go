@@ -2,13 +2,17 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/altsaqif/go-rest/cmd/shared/money"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type DbConfig struct {
@@ -18,10 +22,15 @@ type DbConfig struct {
 	Password string
 	Name     string
 	Driver   string
+	Reset    bool
 }
 
 type ApiConfig struct {
-	ApiPort string
+	ApiPort         string
+	DefaultCurrency string
+	// TrustedProxies are the IPs or CIDR ranges whose X-Forwarded-For and X-Real-IP headers give the client IP,
+	// none by default so a client can not choose the IP seen by IP allowlists, lockouts and logs
+	TrustedProxies []string
 }
 
 type TokenConfig struct {
@@ -29,28 +38,190 @@ type TokenConfig struct {
 	JwtSignatureKey  []byte `json:"JwtSignatureKey"`
 	JwtSigningMethod *jwt.SigningMethodHMAC
 	JwtExpiresTime   time.Duration
+	// JwtChallengeExpiresTime bounds the time between the password and the 2FA step of a login
+	JwtChallengeExpiresTime time.Duration
+}
+
+type NotificationConfig struct {
+	NotifierDriver string
+	WebhookURL     string
+	DedupeWindow   time.Duration
+	QueueSize      int
+}
+
+type ImportConfig struct {
+	BatchSize      int
+	AsyncThreshold int64
+	TempDir        string
+}
+
+// TimeoutConfig bounds how long a request may keep the database busy, RouteTimeouts is keyed by
+// "METHOD /full/path" and overrides QueryTimeout, zero disables the deadline
+type TimeoutConfig struct {
+	QueryTimeout  time.Duration
+	RouteTimeouts map[string]time.Duration
+}
+
+// PasswordConfig holds the password policy and the hashing parameters, Argon2Memory is in KiB
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	BreachedFile  string
+	HashAlgorithm string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+// LoginConfig throttles failed logins. Accounts wait BackoffBase doubled per failure up to BackoffMax,
+// accounts and IPs are locked for Lockout after MaxFailures or IPMaxFailures within FailureWindow.
+type LoginConfig struct {
+	MaxFailures   int
+	IPMaxFailures int
+	FailureWindow time.Duration
+	Lockout       time.Duration
+	BackoffBase   time.Duration
+	BackoffMax    time.Duration
+}
+
+// TwoFactorConfig names the issuer shown by authenticator apps and the roles that must use 2FA
+type TwoFactorConfig struct {
+	Issuer        string
+	RequiredRoles []string
+	RecoveryCodes int
+}
+
+// MailConfig selects how mail leaves the application, MailDriver is log, file or smtp
+type MailConfig struct {
+	MailDriver   string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	FileDir      string
+	QueueSize    int
+}
+
+// AccountConfig holds the email verification and password reset settings, links in the mails start with AppURL
+type AccountConfig struct {
+	AppURL               string
+	RequireVerifiedEmail bool
+	VerifyEmailTTL       time.Duration
+	ResetPasswordTTL     time.Duration
+}
+
+// PrivacyConfig holds the personal data export and erasure settings. Exports are kept in ExportDir for
+// ExportTTL, PseudonymKey keys the hashes that identify erased users without their email. Rotating PseudonymKey
+// breaks the match of earlier erasures, which is why it does not share the token secret.
+type PrivacyConfig struct {
+	ExportDir    string
+	ExportTTL    time.Duration
+	PseudonymKey []byte
+}
+
+// OAuthConfig holds the lifetimes of the authorization codes, access tokens and refresh tokens issued to OAuth
+// clients
+type OAuthConfig struct {
+	CodeTTL         time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// OIDCProviderConfig describes an OpenID Connect identity provider users can log in with. GroupRoles maps the
+// groups in GroupsClaim to roles, DefaultRole is given to new users without a mapped group and an empty one
+// refuses them.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	GroupsClaim  string
+	GroupRoles   map[string]string
+	DefaultRole  string
+	AllowSignup  bool
+}
+
+// OIDCConfig holds the identity providers. Their callbacks are served under APIURL, the public URL of this API,
+// and a login has StateTTL to come back from the provider.
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+	APIURL    string
+	StateTTL  time.Duration
+}
+
+// CookieConfig holds the attributes of the login cookies, MaxAge follows the lifetime of the token. CSRFKey signs
+// the CSRF token that binds state changing requests to the token cookie.
+type CookieConfig struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+	MaxAge   time.Duration
+	CSRFKey  []byte
+}
+
+// SessionConfig holds how long a login session lasts, the lifetime of its token, and how often a request of a
+// session is recorded as its last activity
+type SessionConfig struct {
+	TTL           time.Duration
+	TouchInterval time.Duration
+}
+
+// ImpersonationConfig holds how long the token an admin gets to act as another user lasts
+type ImpersonationConfig struct {
+	TTL time.Duration
+}
+
+// LogConfig holds the output of the logger, json or text, the level records are written from and the levels of
+// single packages, such as repository, that differ from it
+type LogConfig struct {
+	LogFormat     string
+	LogLevel      slog.Level
+	PackageLevels map[string]slog.Level
 }
 
 type Config struct {
 	DbConfig
 	ApiConfig
 	TokenConfig
+	NotificationConfig
+	ImportConfig
+	TimeoutConfig
+	PasswordConfig
+	LoginConfig
+	TwoFactorConfig
+	MailConfig
+	AccountConfig
+	PrivacyConfig
+	OAuthConfig
+	OIDCConfig
+	CookieConfig
+	SessionConfig
+	ImpersonationConfig
+	LogConfig
 }
 
 func (c *Config) readConfig() error {
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("Failed to get current directory: %v", err)
+		return fmt.Errorf("failed to get current directory: %v", err)
 	}
-	log.Println("Current directory:", cwd)
+	slog.Info("Current directory", "dir", cwd)
 
-	log.Println("Looking for .env file in current directory")
+	slog.Info("Looking for .env file in current directory")
 
 	if err := godotenv.Load(".env"); err != nil {
 		return fmt.Errorf("failed to load configuration: missing env file %v", err.Error())
 	}
 
-	log.Println(".env file loaded successfully")
+	slog.Info(".env file loaded successfully")
 
 	c.DbConfig = DbConfig{
 		Host:     os.Getenv("DB_HOST"),
@@ -60,15 +231,139 @@ func (c *Config) readConfig() error {
 		Name:     os.Getenv("DB_NAME"),
 		Driver:   os.Getenv("DB_DRIVER"),
 	}
+	c.DbConfig.Reset, _ = strconv.ParseBool(os.Getenv("DB_RESET"))
 
-	c.ApiConfig = ApiConfig{ApiPort: os.Getenv("API_PORT")}
+	c.ApiConfig = ApiConfig{
+		ApiPort:         os.Getenv("API_PORT"),
+		DefaultCurrency: strings.ToUpper(os.Getenv("APP_CURRENCY")),
+	}
+	if c.DefaultCurrency == "" {
+		c.DefaultCurrency = "IDR"
+	}
+	if !money.IsSupported(c.DefaultCurrency) {
+		return fmt.Errorf("unsupported APP_CURRENCY %q", c.DefaultCurrency)
+	}
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			c.TrustedProxies = append(c.TrustedProxies, proxy)
+		}
+	}
 
 	tokenExpire, _ := strconv.Atoi(os.Getenv("TOKEN_EXPIRE"))
 	c.TokenConfig = TokenConfig{
-		IssuerName:       os.Getenv("TOKEN_ISSUE"),
-		JwtSignatureKey:  []byte(os.Getenv("TOKEN_SECRET")),
-		JwtSigningMethod: jwt.SigningMethodHS256,
-		JwtExpiresTime:   time.Duration(tokenExpire) * time.Minute,
+		IssuerName:              os.Getenv("TOKEN_ISSUE"),
+		JwtSignatureKey:         []byte(os.Getenv("TOKEN_SECRET")),
+		JwtSigningMethod:        jwt.SigningMethodHS256,
+		JwtExpiresTime:          time.Duration(tokenExpire) * time.Minute,
+		JwtChallengeExpiresTime: time.Duration(envInt("TOKEN_CHALLENGE_EXPIRE", 5)) * time.Minute,
+	}
+
+	c.TwoFactorConfig = TwoFactorConfig{
+		Issuer:        os.Getenv("TWO_FACTOR_ISSUER"),
+		RecoveryCodes: envInt("TWO_FACTOR_RECOVERY_CODES", 10),
+	}
+	if c.TwoFactorConfig.Issuer == "" {
+		c.TwoFactorConfig.Issuer = c.IssuerName
+	}
+	for _, role := range strings.Split(os.Getenv("TWO_FACTOR_REQUIRED_ROLES"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			c.TwoFactorConfig.RequiredRoles = append(c.TwoFactorConfig.RequiredRoles, role)
+		}
+	}
+
+	dedupeWindow, err := strconv.Atoi(os.Getenv("NOTIFIER_DEDUPE_WINDOW"))
+	if err != nil || dedupeWindow <= 0 {
+		dedupeWindow = 24 * 60
+	}
+	queueSize, err := strconv.Atoi(os.Getenv("NOTIFIER_QUEUE_SIZE"))
+	if err != nil || queueSize <= 0 {
+		queueSize = 1000
+	}
+	c.NotificationConfig = NotificationConfig{
+		NotifierDriver: os.Getenv("NOTIFIER_DRIVER"),
+		WebhookURL:     os.Getenv("NOTIFIER_WEBHOOK_URL"),
+		DedupeWindow:   time.Duration(dedupeWindow) * time.Minute,
+		QueueSize:      queueSize,
+	}
+
+	batchSize, err := strconv.Atoi(os.Getenv("IMPORT_BATCH_SIZE"))
+	if err != nil || batchSize <= 0 {
+		batchSize = 100
+	}
+	asyncThreshold, err := strconv.ParseInt(os.Getenv("IMPORT_ASYNC_THRESHOLD"), 10, 64)
+	if err != nil || asyncThreshold <= 0 {
+		asyncThreshold = 1 << 20
+	}
+	c.ImportConfig = ImportConfig{
+		BatchSize:      batchSize,
+		AsyncThreshold: asyncThreshold,
+		TempDir:        os.Getenv("IMPORT_TEMP_DIR"),
+	}
+
+	queryTimeout := 5 * time.Second
+	if value := os.Getenv("QUERY_TIMEOUT"); value != "" {
+		if queryTimeout, err = time.ParseDuration(value); err != nil || queryTimeout < 0 {
+			return fmt.Errorf("invalid QUERY_TIMEOUT %q", value)
+		}
+	}
+	routeTimeouts, err := parseRouteTimeouts(os.Getenv("QUERY_TIMEOUT_ROUTES"))
+	if err != nil {
+		return err
+	}
+	c.TimeoutConfig = TimeoutConfig{
+		QueryTimeout:  queryTimeout,
+		RouteTimeouts: routeTimeouts,
+	}
+
+	if c.PasswordConfig, err = readPasswordConfig(); err != nil {
+		return err
+	}
+
+	if c.LoginConfig, err = readLoginConfig(); err != nil {
+		return err
+	}
+
+	if c.MailConfig, err = readMailConfig(); err != nil {
+		return err
+	}
+
+	if c.AccountConfig, err = readAccountConfig(); err != nil {
+		return err
+	}
+
+	if c.PrivacyConfig, err = readPrivacyConfig(); err != nil {
+		return err
+	}
+
+	if c.OAuthConfig, err = readOAuthConfig(); err != nil {
+		return err
+	}
+
+	if c.OIDCConfig, err = readOIDCConfig(); err != nil {
+		return err
+	}
+
+	if c.CookieConfig, err = readCookieConfig(); err != nil {
+		return err
+	}
+	c.CookieConfig.MaxAge = c.JwtExpiresTime
+
+	c.SessionConfig = SessionConfig{TTL: c.JwtExpiresTime, TouchInterval: time.Minute}
+	if value := os.Getenv("SESSION_TOUCH_INTERVAL"); value != "" {
+		if c.SessionConfig.TouchInterval, err = time.ParseDuration(value); err != nil || c.SessionConfig.TouchInterval < 0 {
+			return fmt.Errorf("invalid SESSION_TOUCH_INTERVAL %q", value)
+		}
+	}
+
+	c.ImpersonationConfig = ImpersonationConfig{TTL: 15 * time.Minute}
+	if value := os.Getenv("IMPERSONATION_TTL"); value != "" {
+		if c.ImpersonationConfig.TTL, err = time.ParseDuration(value); err != nil || c.ImpersonationConfig.TTL <= 0 {
+			return fmt.Errorf("invalid IMPERSONATION_TTL %q", value)
+		}
+	}
+
+	if c.LogConfig, err = readLogConfig(); err != nil {
+		return err
 	}
 
 	if c.Host == "" || c.Port == "" || c.User == "" || c.Name == "" || c.Driver == "" || c.ApiPort == "" ||
@@ -80,6 +375,344 @@ func (c *Config) readConfig() error {
 
 }
 
+func readPasswordConfig() (PasswordConfig, error) {
+	cfg := PasswordConfig{
+		MinLength:     envInt("PASSWORD_MIN_LENGTH", 8),
+		RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+		BreachedFile:  os.Getenv("PASSWORD_BREACHED_FILE"),
+		HashAlgorithm: strings.ToLower(os.Getenv("PASSWORD_HASH")),
+		BcryptCost:    envInt("PASSWORD_BCRYPT_COST", bcrypt.DefaultCost),
+		Argon2Time:    uint32(envInt("PASSWORD_ARGON2_TIME", 2)),
+		Argon2Memory:  uint32(envInt("PASSWORD_ARGON2_MEMORY", 19*1024)),
+		Argon2Threads: uint8(envInt("PASSWORD_ARGON2_THREADS", 1)),
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+	if cfg.HashAlgorithm == "" {
+		cfg.HashAlgorithm = "argon2id"
+	}
+
+	if cfg.HashAlgorithm != "argon2id" && cfg.HashAlgorithm != "bcrypt" {
+		return PasswordConfig{}, fmt.Errorf("unsupported PASSWORD_HASH %q", cfg.HashAlgorithm)
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return PasswordConfig{}, fmt.Errorf("invalid PASSWORD_BCRYPT_COST %d", cfg.BcryptCost)
+	}
+	if cfg.Argon2Threads == 0 || cfg.Argon2Time == 0 || cfg.Argon2Memory < 8*uint32(cfg.Argon2Threads) {
+		return PasswordConfig{}, fmt.Errorf("invalid argon2 parameters")
+	}
+	return cfg, nil
+}
+
+func readLoginConfig() (LoginConfig, error) {
+	cfg := LoginConfig{
+		MaxFailures:   envInt("LOGIN_MAX_FAILURES", 5),
+		IPMaxFailures: envInt("LOGIN_IP_MAX_FAILURES", 50),
+	}
+	durations := []struct {
+		key      string
+		target   *time.Duration
+		fallback time.Duration
+	}{
+		{"LOGIN_FAILURE_WINDOW", &cfg.FailureWindow, 15 * time.Minute},
+		{"LOGIN_LOCKOUT", &cfg.Lockout, 15 * time.Minute},
+		{"LOGIN_BACKOFF_BASE", &cfg.BackoffBase, time.Second},
+		{"LOGIN_BACKOFF_MAX", &cfg.BackoffMax, 30 * time.Second},
+	}
+	for _, d := range durations {
+		*d.target = d.fallback
+		if value := os.Getenv(d.key); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration < 0 {
+				return LoginConfig{}, fmt.Errorf("invalid %s %q", d.key, value)
+			}
+			*d.target = duration
+		}
+	}
+	return cfg, nil
+}
+
+func readMailConfig() (MailConfig, error) {
+	cfg := MailConfig{
+		MailDriver:   strings.ToLower(os.Getenv("MAIL_DRIVER")),
+		From:         os.Getenv("MAIL_FROM"),
+		SMTPHost:     os.Getenv("MAIL_SMTP_HOST"),
+		SMTPPort:     envInt("MAIL_SMTP_PORT", 587),
+		SMTPUser:     os.Getenv("MAIL_SMTP_USER"),
+		SMTPPassword: os.Getenv("MAIL_SMTP_PASSWORD"),
+		FileDir:      os.Getenv("MAIL_FILE_DIR"),
+		QueueSize:    envInt("MAIL_QUEUE_SIZE", 100),
+	}
+	if cfg.MailDriver == "" {
+		cfg.MailDriver = "log"
+	}
+	if cfg.From == "" {
+		cfg.From = "no-reply@localhost"
+	}
+	if cfg.FileDir == "" {
+		cfg.FileDir = "mails"
+	}
+
+	switch cfg.MailDriver {
+	case "log", "file":
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return MailConfig{}, fmt.Errorf("MAIL_SMTP_HOST is required for the smtp mail driver")
+		}
+	default:
+		return MailConfig{}, fmt.Errorf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+	}
+	return cfg, nil
+}
+
+func readAccountConfig() (AccountConfig, error) {
+	cfg := AccountConfig{
+		AppURL:               strings.TrimRight(os.Getenv("APP_URL"), "/"),
+		RequireVerifiedEmail: envBool("ACCOUNT_REQUIRE_VERIFIED_EMAIL", false),
+	}
+	if cfg.AppURL == "" {
+		cfg.AppURL = "http://localhost:" + os.Getenv("API_PORT")
+	}
+
+	durations := []struct {
+		key      string
+		target   *time.Duration
+		fallback time.Duration
+	}{
+		{"ACCOUNT_VERIFY_EMAIL_TTL", &cfg.VerifyEmailTTL, 24 * time.Hour},
+		{"ACCOUNT_RESET_PASSWORD_TTL", &cfg.ResetPasswordTTL, time.Hour},
+	}
+	for _, d := range durations {
+		*d.target = d.fallback
+		if value := os.Getenv(d.key); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return AccountConfig{}, fmt.Errorf("invalid %s %q", d.key, value)
+			}
+			*d.target = duration
+		}
+	}
+	return cfg, nil
+}
+
+func readPrivacyConfig() (PrivacyConfig, error) {
+	cfg := PrivacyConfig{
+		ExportDir:    os.Getenv("PRIVACY_EXPORT_DIR"),
+		ExportTTL:    24 * time.Hour,
+		PseudonymKey: []byte(os.Getenv("PRIVACY_PSEUDONYM_KEY")),
+	}
+	if len(cfg.PseudonymKey) == 0 {
+		return PrivacyConfig{}, fmt.Errorf("PRIVACY_PSEUDONYM_KEY is required")
+	}
+	if cfg.ExportDir == "" {
+		cfg.ExportDir = "exports"
+	}
+	if value := os.Getenv("PRIVACY_EXPORT_TTL"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return PrivacyConfig{}, fmt.Errorf("invalid PRIVACY_EXPORT_TTL %q", value)
+		}
+		cfg.ExportTTL = duration
+	}
+	return cfg, nil
+}
+
+func readOAuthConfig() (OAuthConfig, error) {
+	var cfg OAuthConfig
+	durations := []struct {
+		key      string
+		target   *time.Duration
+		fallback time.Duration
+	}{
+		{"OAUTH_CODE_TTL", &cfg.CodeTTL, 10 * time.Minute},
+		{"OAUTH_ACCESS_TOKEN_TTL", &cfg.AccessTokenTTL, time.Hour},
+		{"OAUTH_REFRESH_TOKEN_TTL", &cfg.RefreshTokenTTL, 30 * 24 * time.Hour},
+	}
+	for _, d := range durations {
+		*d.target = d.fallback
+		if value := os.Getenv(d.key); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration <= 0 {
+				return OAuthConfig{}, fmt.Errorf("invalid %s %q", d.key, value)
+			}
+			*d.target = duration
+		}
+	}
+	return cfg, nil
+}
+
+func readOIDCConfig() (OIDCConfig, error) {
+	cfg := OIDCConfig{
+		APIURL:   strings.TrimRight(os.Getenv("OIDC_API_URL"), "/"),
+		StateTTL: 10 * time.Minute,
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "http://localhost:" + os.Getenv("API_PORT")
+	}
+	if value := os.Getenv("OIDC_STATE_TTL"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return OIDCConfig{}, fmt.Errorf("invalid OIDC_STATE_TTL %q", value)
+		}
+		cfg.StateTTL = duration
+	}
+
+	roles := map[string]bool{"customer": true, "reseller": true, "admin": true}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			GroupsClaim:  os.Getenv(prefix + "GROUPS_CLAIM"),
+			GroupRoles:   map[string]string{},
+			DefaultRole:  os.Getenv(prefix + "DEFAULT_ROLE"),
+			AllowSignup:  envBool(prefix+"ALLOW_SIGNUP", true),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return OIDCConfig{}, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		if provider.GroupsClaim == "" {
+			provider.GroupsClaim = "groups"
+		}
+		switch provider.DefaultRole {
+		case "":
+			provider.DefaultRole = "customer"
+		case "none":
+			provider.DefaultRole = ""
+		}
+		if provider.DefaultRole != "" && !roles[provider.DefaultRole] {
+			return OIDCConfig{}, fmt.Errorf("invalid %sDEFAULT_ROLE %q", prefix, provider.DefaultRole)
+		}
+
+		// GROUP_ROLES looks like "it-admins=admin,sales=reseller"
+		for _, pair := range strings.Split(os.Getenv(prefix+"GROUP_ROLES"), ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			group, role, ok := strings.Cut(pair, "=")
+			role = strings.TrimSpace(role)
+			if !ok || strings.TrimSpace(group) == "" || !roles[role] {
+				return OIDCConfig{}, fmt.Errorf("invalid %sGROUP_ROLES entry %q", prefix, pair)
+			}
+			provider.GroupRoles[strings.TrimSpace(group)] = role
+		}
+		cfg.Providers = append(cfg.Providers, provider)
+	}
+	return cfg, nil
+}
+
+func readCookieConfig() (CookieConfig, error) {
+	cfg := CookieConfig{
+		Secure:  envBool("COOKIE_SECURE", false),
+		Domain:  os.Getenv("COOKIE_DOMAIN"),
+		CSRFKey: []byte(os.Getenv("CSRF_KEY")),
+	}
+	if len(cfg.CSRFKey) == 0 {
+		return CookieConfig{}, fmt.Errorf("CSRF_KEY is required")
+	}
+
+	switch value := strings.ToLower(os.Getenv("COOKIE_SAMESITE")); value {
+	case "", "lax":
+		cfg.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		cfg.SameSite = http.SameSiteNoneMode
+	default:
+		return CookieConfig{}, fmt.Errorf("invalid COOKIE_SAMESITE %q", value)
+	}
+	// Browsers drop SameSite=None cookies that are not Secure
+	if cfg.SameSite == http.SameSiteNoneMode && !cfg.Secure {
+		return CookieConfig{}, fmt.Errorf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+	}
+	return cfg, nil
+}
+
+func readLogConfig() (LogConfig, error) {
+	cfg := LogConfig{LogFormat: strings.ToLower(os.Getenv("LOG_FORMAT")), PackageLevels: map[string]slog.Level{}}
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = "json"
+	case "json", "text":
+	default:
+		return LogConfig{}, fmt.Errorf("unknown LOG_FORMAT %q", cfg.LogFormat)
+	}
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := cfg.LogLevel.UnmarshalText([]byte(value)); err != nil {
+			return LogConfig{}, fmt.Errorf("invalid LOG_LEVEL %q", value)
+		}
+	}
+
+	// LOG_LEVELS lists package=level pairs, such as repository=warn,middlewares=debug
+	for _, pair := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		pkg, value, ok := strings.Cut(pair, "=")
+		var level slog.Level
+		if !ok || strings.TrimSpace(pkg) == "" || level.UnmarshalText([]byte(strings.TrimSpace(value))) != nil {
+			return LogConfig{}, fmt.Errorf("invalid LOG_LEVELS entry %q", pair)
+		}
+		cfg.PackageLevels[strings.TrimSpace(pkg)] = level
+	}
+	return cfg, nil
+}
+
+// envInt reads a positive integer, falling back when the variable is empty or invalid
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func envBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// parseRouteTimeouts reads a comma separated list such as "GET /api/v1/products/export=2m"
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, duration, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath {
+			return nil, fmt.Errorf("invalid QUERY_TIMEOUT_ROUTES entry %q", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid QUERY_TIMEOUT_ROUTES entry %q", entry)
+		}
+		timeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = timeout
+	}
+	return timeouts, nil
+}
+
 func NewConfig() (*Config, error) {
 	cfg := &Config{}
 	if err := cfg.readConfig(); err != nil {
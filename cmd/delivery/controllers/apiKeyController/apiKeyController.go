@@ -0,0 +1,121 @@
+package apiKeyController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	apiKeyUc usecase.APIKeyUseCase
+	rg       *gin.RouterGroup
+	authMid  middlewares.AuthMiddleware
+}
+
+func NewAPIKeyController(apiKeyUc usecase.APIKeyUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *APIKeyController {
+	return &APIKeyController{apiKeyUc: apiKeyUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Create API key
+// @Description Create an API key for machine-to-machine access. The key acts as the current user, limited to its scopes, and is only shown in this response.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param APIKeyCreateRequestDto body dto.APIKeyCreateRequestDto true "API Key Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /api-keys [post]
+func (a *APIKeyController) CreateHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.APIKeyCreateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	key, err := a.apiKeyUc.CreateKey(ctx.Request.Context(), userID, payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "API key created, store it now as it can not be shown again", key)
+}
+
+// @Summary Get API keys
+// @Description Get the API keys of the current user, secrets are never returned
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /api-keys [get]
+func (a *APIKeyController) GetAllHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	keys, err := a.apiKeyUc.FindKeys(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", keys)
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key of the current user, admins can revoke any key
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API Key ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /api-keys/{id} [delete]
+func (a *APIKeyController) RevokeHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid API key ID"))
+		return
+	}
+
+	isAdmin := middlewares.GetUserRole(ctx) == "admin"
+	if err := a.apiKeyUc.RevokeKey(ctx.Request.Context(), userID, isAdmin, uint(id), ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "API key revoked successfully")
+}
+
+func (a *APIKeyController) Route() {
+	a.rg.POST(config.PostAPIKeys, a.authMid.RequireToken("reseller", "admin"), a.CreateHandler)
+	a.rg.GET(config.GetAPIKeys, a.authMid.RequireToken("reseller", "admin"), a.GetAllHandler)
+	a.rg.DELETE(config.DelAPIKeys, a.authMid.RequireToken("reseller", "admin"), a.RevokeHandler)
+}
@@ -0,0 +1,92 @@
+package auditLogController
+
+import (
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditLogController struct {
+	auditUc usecase.AuditUseCase
+	rg      *gin.RouterGroup
+	authMid middlewares.AuthMiddleware
+}
+
+func NewAuditLogController(auditUc usecase.AuditUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *AuditLogController {
+	return &AuditLogController{auditUc: auditUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get audit logs
+// @Description Get a page of the audit log, newest first. Every write to products, users and the auth flows is recorded with its actor, request ID, IP and the changed fields.
+// @Tags audit logs
+// @Produce json
+// @Param actor_id query int false "User who made the change, the admin for impersonated requests"
+// @Param action query string false "Action such as product.update or user.update"
+// @Param target_type query string false "product, user, api_key or oauth_client"
+// @Param target_id query string false "ID of the changed resource"
+// @Param request_id query string false "X-Request-ID of the request that made the change"
+// @Param created_after query string false "Created on or after this date, YYYY-MM-DD"
+// @Param created_before query string false "Created before this date, YYYY-MM-DD"
+// @Param sort query string false "Comma separated fields of id, created_at, actor_id and action, a leading - sorts descending"
+// @Param page query int false "Page number"
+// @Param size query int false "Page size, at most 100"
+// @Success 200 {object} model.PagedResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /audit-logs [get]
+func (a *AuditLogController) GetAllHandler(ctx *gin.Context) {
+	var filter dto.AuditLogFilterDto
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	entries, paging, err := a.auditUc.FindAuditLogs(ctx.Request.Context(), filter.Spec())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if len(entries) == 0 {
+		ctx.Error(apperror.NotFound("Audit logs not found"))
+		return
+	}
+
+	var interfaceSlice = make([]interface{}, len(entries))
+	for i, v := range entries {
+		interfaceSlice[i] = v
+	}
+
+	common.SendPagedResponse(ctx, interfaceSlice, paging, "Ok")
+}
+
+// @Summary Verify audit logs
+// @Description Walk the hash chain of the audit log. An entry that was changed, or follows one that was removed, breaks the chain and is returned as broken_at.
+// @Tags audit logs
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /audit-logs/verify [get]
+func (a *AuditLogController) VerifyHandler(ctx *gin.Context) {
+	result, err := a.auditUc.VerifyAuditLogs(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", result)
+}
+
+func (a *AuditLogController) Route() {
+	a.rg.GET(config.GetAuditLogs, a.authMid.RequireToken("admin"), a.GetAllHandler)
+	a.rg.GET(config.GetAuditLogsVerify, a.authMid.RequireToken("admin"), a.VerifyHandler)
+}
@@ -1,29 +1,29 @@
 package authController
 
 import (
-	"net/http"
-
 	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
 	"github.com/altsaqif/go-rest/cmd/shared/common"
 	"github.com/altsaqif/go-rest/cmd/usecase"
-	"github.com/altsaqif/go-rest/cmd/utils"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthController handles authentication
 type AuthController struct {
-	authUc usecase.AuthUseCase
-	rg     *gin.RouterGroup
+	authUc    usecase.AuthUseCase
+	accountUc usecase.AccountUseCase
+	rg        *gin.RouterGroup
+	authMid   middlewares.AuthMiddleware
 }
 
 // NewAuthController creates a new AuthController
-func NewAuthController(authUc usecase.AuthUseCase, rg *gin.RouterGroup) *AuthController {
-	return &AuthController{authUc: authUc, rg: rg}
+func NewAuthController(authUc usecase.AuthUseCase, accountUc usecase.AccountUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *AuthController {
+	return &AuthController{authUc: authUc, accountUc: accountUc, rg: rg, authMid: authMid}
 }
 
 // @Summary Login user
-// @Description Log in an existing user
+// @Description Log in an existing user, users with 2FA enabled get a challenge token for /auth/2fa/login instead
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -31,44 +31,129 @@ func NewAuthController(authUc usecase.AuthUseCase, rg *gin.RouterGroup) *AuthCon
 // @Success 200 {object} model.SingleResponse
 // @Failure 400 {object} model.Status
 // @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 429 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Router /auth/login [post]
 func (a *AuthController) loginHandler(ctx *gin.Context) {
 	var payload dto.AuthRequestLoginDto
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	token, err := a.authUc.Login(ctx.Request.Context(), payload, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+	if token.TwoFactorRequired {
+		common.SendSingleResponse(ctx, "Two-factor authentication required", token)
+		return
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := a.authUc.FindUserByEmail(payload.Email)
-		resultChan <- result{user, err}
-	}()
+	// Set token to cookie
+	a.authMid.SetTokenCookie(ctx, token.Token)
 
-	res := <-resultChan
-	if res.err != nil || !utils.CheckPasswordHash(payload.Password, res.user.Password) {
-		common.SendErrorResponse(ctx, http.StatusUnauthorized, "Invalid email or password")
+	common.SendSuccessResponse(ctx, "Successfully Login")
+}
+
+// @Summary Complete a 2FA login
+// @Description Exchange the challenge token of /auth/login and a TOTP or recovery code for a token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param TwoFactorLoginRequestDto body dto.TwoFactorLoginRequestDto true "2FA Login Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 429 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/2fa/login [post]
+func (a *AuthController) twoFactorLoginHandler(ctx *gin.Context) {
+	var payload dto.TwoFactorLoginRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	token, err := a.authUc.Login(payload)
+	token, err := a.authUc.LoginTwoFactor(ctx.Request.Context(), payload, ctx.ClientIP(), ctx.Request.UserAgent())
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		ctx.Error(err)
 		return
 	}
 
 	// Set token to cookie
-	ctx.SetCookie("token", token.Token, 3600, "/", "", false, true)
+	a.authMid.SetTokenCookie(ctx, token.Token)
 
 	common.SendSuccessResponse(ctx, "Successfully Login")
 }
 
+// @Summary Set up 2FA
+// @Description Generate a TOTP secret for the current user, scan the QR code and confirm with /auth/2fa/verify
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/2fa/setup [post]
+func (a *AuthController) twoFactorSetupHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	setup, err := a.authUc.SetupTwoFactor(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Scan the QR code and verify a code to enable two-factor authentication", setup)
+}
+
+// @Summary Verify 2FA
+// @Description Enable 2FA with a code of the new secret, the recovery codes are only shown once
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param TwoFactorVerifyRequestDto body dto.TwoFactorVerifyRequestDto true "2FA Verify Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/2fa/verify [post]
+func (a *AuthController) twoFactorVerifyHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.TwoFactorVerifyRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	verified, err := a.authUc.VerifyTwoFactor(ctx.Request.Context(), userID, middlewares.GetSessionID(ctx), payload.Code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// The new token carries the 2FA claim required for the enforced roles
+	a.authMid.SetTokenCookie(ctx, verified.Token)
+
+	common.SendSingleResponse(ctx, "Two-factor authentication enabled", verified)
+}
+
 // @Summary Register user
 // @Description Register a new user
 // @Tags auth
@@ -77,46 +162,30 @@ func (a *AuthController) loginHandler(ctx *gin.Context) {
 // @Param AuthRequestRegisterDto body dto.AuthRequestRegisterDto true "Register Payload"
 // @Success 201 {object} model.SingleResponse
 // @Failure 400 {object} model.Status
-// @Failure 400 {object} model.Status
+// @Failure 409 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Router /auth/register [post]
 func (a *AuthController) registerHandler(ctx *gin.Context) {
 	var payload dto.AuthRequestRegisterDto
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	if payload.Password != payload.PasswordConfirm {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Password not match")
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	type result struct {
-		user dto.UserWithProducts
-		err  error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		user, err := a.authUc.Register(payload)
-		resultChan <- result{user, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	user, err := a.authUc.Register(ctx.Request.Context(), payload)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	responseData := map[string]interface{}{
-		"id":         res.user.ID,
-		"username":   res.user.FirstName + " " + res.user.LastName,
-		"email":      res.user.Email,
-		"role":       res.user.Role,
-		"created_at": res.user.CreatedAt,
-		"updated_at": res.user.UpdatedAt,
-		"deleted_at": res.user.DeletedAt,
+		"id":         user.ID,
+		"username":   user.FirstName + " " + user.LastName,
+		"email":      user.Email,
+		"role":       user.Role,
+		"created_at": user.CreatedAt,
+		"updated_at": user.UpdatedAt,
+		"deleted_at": user.DeletedAt,
 	}
 
 	common.SendCreateResponse(ctx, "User registered successfully", responseData)
@@ -130,14 +199,121 @@ func (a *AuthController) registerHandler(ctx *gin.Context) {
 // @Router /auth/logout [get]
 func (a *AuthController) logoutHandler(ctx *gin.Context) {
 	// Clear the token cookie
-	ctx.SetCookie("token", "", -1, "/", "", false, true)
+	a.authMid.ClearTokenCookie(ctx)
 
 	common.SendSuccessResponse(ctx, "Logout successfully!")
 }
 
+// @Summary Verify email
+// @Description Verify the email address with the token from the verification mail
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param TokenRequestDto body dto.TokenRequestDto true "Verification Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/verify-email [post]
+func (a *AuthController) verifyEmailHandler(ctx *gin.Context) {
+	var payload dto.TokenRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	if err := a.accountUc.VerifyEmail(ctx.Request.Context(), payload.Token); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Email verified successfully")
+}
+
+// @Summary Resend verification mail
+// @Description Mail a new verification link, the response is the same whether the email is registered or not
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param EmailRequestDto body dto.EmailRequestDto true "Email Payload"
+// @Success 202 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/verify-email/resend [post]
+func (a *AuthController) resendVerificationHandler(ctx *gin.Context) {
+	var payload dto.EmailRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	if err := a.accountUc.ResendVerification(ctx.Request.Context(), payload.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendAcceptedResponse(ctx, "If the email is registered and not verified yet, a verification link has been sent", nil)
+}
+
+// @Summary Forgot password
+// @Description Mail a password reset link, the response is the same whether the email is registered or not
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param EmailRequestDto body dto.EmailRequestDto true "Email Payload"
+// @Success 202 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/forgot-password [post]
+func (a *AuthController) forgotPasswordHandler(ctx *gin.Context) {
+	var payload dto.EmailRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	if err := a.accountUc.ForgotPassword(ctx.Request.Context(), payload.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendAcceptedResponse(ctx, "If the email is registered, a password reset link has been sent", nil)
+}
+
+// @Summary Reset password
+// @Description Set a new password with the token from the password reset mail
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param ResetPasswordRequestDto body dto.ResetPasswordRequestDto true "Reset Password Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/reset-password [post]
+func (a *AuthController) resetPasswordHandler(ctx *gin.Context) {
+	var payload dto.ResetPasswordRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	if err := a.accountUc.ResetPassword(ctx.Request.Context(), payload, ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Password reset successfully, log in with the new password")
+}
+
 // Route initializes the auth routes
 func (a *AuthController) Route() {
 	a.rg.POST(config.PostLogin, a.loginHandler)
 	a.rg.POST(config.PostRegister, a.registerHandler)
 	a.rg.GET(config.GetLogout, a.logoutHandler)
+	a.rg.POST(config.PostTwoFactorLogin, a.twoFactorLoginHandler)
+	a.rg.POST(config.PostTwoFactorSetup, a.authMid.RequireTokenFor2FASetup("customer", "reseller", "admin"), a.twoFactorSetupHandler)
+	a.rg.POST(config.PostTwoFactorVerify, a.authMid.RequireTokenFor2FASetup("customer", "reseller", "admin"), a.twoFactorVerifyHandler)
+	a.rg.POST(config.PostVerifyEmail, a.verifyEmailHandler)
+	a.rg.POST(config.PostResendVerifyEmail, a.resendVerificationHandler)
+	a.rg.POST(config.PostForgotPassword, a.forgotPasswordHandler)
+	a.rg.POST(config.PostResetPassword, a.resetPasswordHandler)
 }
@@ -0,0 +1,219 @@
+package couponController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type CouponController struct {
+	couponUc usecase.CouponUseCase
+	rg       *gin.RouterGroup
+	authMid  middlewares.AuthMiddleware
+}
+
+func NewCouponController(couponUc usecase.CouponUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *CouponController {
+	return &CouponController{couponUc: couponUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get all coupons
+// @Description Get a list of all coupons with pagination
+// @Tags coupons
+// @Produce json
+// @Param page query int false "Page number"
+// @Param size query int false "Page size"
+// @Success 200 {object} model.PagedResponse
+// @Failure 500 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Router /coupons [get]
+func (c *CouponController) GetAllHandler(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	size, _ := strconv.Atoi(ctx.Query("size"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+
+	coupons, paging, err := c.couponUc.FindAllCoupons(ctx.Request.Context(), page, size)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if len(coupons) == 0 {
+		ctx.Error(apperror.NotFound("Coupons not found"))
+		return
+	}
+
+	var interfaceSlice = make([]interface{}, len(coupons))
+	for i, v := range coupons {
+		interfaceSlice[i] = v
+	}
+
+	common.SendPagedResponse(ctx, interfaceSlice, paging, "Ok")
+}
+
+// @Summary Get coupon by ID
+// @Description Get details of a coupon by ID
+// @Tags coupons
+// @Produce json
+// @Param id path string true "Coupon ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /coupons/{id} [get]
+func (c *CouponController) GetByIDHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid coupon ID"))
+		return
+	}
+
+	coupon, err := c.couponUc.FindCouponByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", coupon)
+}
+
+// @Summary Create coupon
+// @Description Create a new percentage or fixed amount coupon
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Param CouponRequestDto body dto.CouponRequestDto true "Coupon Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /coupons [post]
+func (c *CouponController) CreateHandler(ctx *gin.Context) {
+	var payload dto.CouponRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	coupon, err := c.couponUc.CreateCoupon(ctx.Request.Context(), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "Coupon created successfully", coupon)
+}
+
+// @Summary Update coupon
+// @Description Replace an existing coupon by ID, the usage counter is kept
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Param id path string true "Coupon ID"
+// @Param CouponRequestDto body dto.CouponRequestDto true "Coupon Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /coupons/{id} [put]
+func (c *CouponController) UpdateHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid coupon ID"))
+		return
+	}
+
+	var payload dto.CouponRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	coupon, err := c.couponUc.UpdateCoupon(ctx.Request.Context(), uint(id), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Coupon updated successfully", coupon)
+}
+
+// @Summary Delete coupon
+// @Description Delete a coupon by ID
+// @Tags coupons
+// @Produce json
+// @Param id path string true "Coupon ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /coupons/{id} [delete]
+func (c *CouponController) DeleteHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid coupon ID"))
+		return
+	}
+
+	if err := c.couponUc.DeleteCoupon(ctx.Request.Context(), uint(id)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Coupon deleted successfully")
+}
+
+// @Summary Validate coupon
+// @Description Check whether a coupon applies to a product for the current user without redeeming it
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Param CouponValidateRequestDto body dto.CouponValidateRequestDto true "Coupon Validate Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /coupons/validate [post]
+func (c *CouponController) ValidateHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.CouponValidateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	validation, err := c.couponUc.ValidateCoupon(ctx.Request.Context(), userID, payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Coupon is valid", validation)
+}
+
+func (c *CouponController) Route() {
+	c.rg.GET(config.GetCouponsList, c.authMid.RequireToken("admin"), c.GetAllHandler)
+	c.rg.GET(config.GetCoupons, c.authMid.RequireToken("admin"), c.GetByIDHandler)
+	c.rg.POST(config.PostCoupons, c.authMid.RequireToken("admin"), c.CreateHandler)
+	c.rg.POST(config.PostCouponsCheck, c.authMid.RequireToken("customer", "reseller", "admin"), c.ValidateHandler)
+	c.rg.PUT(config.PutCoupons, c.authMid.RequireToken("admin"), c.UpdateHandler)
+	c.rg.DELETE(config.DelCoupons, c.authMid.RequireToken("admin"), c.DeleteHandler)
+}
@@ -0,0 +1,71 @@
+package enrollmentController
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type EnrollmentController struct {
+	enrollmentUc usecase.EnrollmentUseCase
+	rg           *gin.RouterGroup
+	authMid      middlewares.AuthMiddleware
+}
+
+func NewEnrollmentController(enrollmentUc usecase.EnrollmentUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *EnrollmentController {
+	return &EnrollmentController{enrollmentUc: enrollmentUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Enroll in product
+// @Description Check out one unit of a product for the current user, optionally applying a coupon
+// @Tags enrollments
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param EnrollmentRequestDto body dto.EnrollmentRequestDto false "Enrollment Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /products/{id}/enroll [post]
+func (e *EnrollmentController) EnrollHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	productID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid product ID"))
+		return
+	}
+
+	// The body is optional, an empty body enrolls without a coupon
+	var payload dto.EnrollmentRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil && !errors.Is(err, io.EOF) {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	enrollment, err := e.enrollmentUc.Enroll(ctx.Request.Context(), userID, uint(productID), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "Enrolled successfully", enrollment)
+}
+
+func (e *EnrollmentController) Route() {
+	e.rg.POST(config.PostEnrollments, e.authMid.RequireToken("customer", "reseller", "admin"), e.EnrollHandler)
+}
@@ -0,0 +1,68 @@
+package exchangeRateController
+
+import (
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateController struct {
+	rateUc  usecase.ExchangeRateUseCase
+	rg      *gin.RouterGroup
+	authMid middlewares.AuthMiddleware
+}
+
+func NewExchangeRateController(rateUc usecase.ExchangeRateUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *ExchangeRateController {
+	return &ExchangeRateController{rateUc: rateUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get exchange rates
+// @Description Get all configured currency exchange rates
+// @Tags rates
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 500 {object} model.Status
+// @Router /rates [get]
+func (e *ExchangeRateController) GetAllHandler(ctx *gin.Context) {
+	rates, err := e.rateUc.FindAllRates(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", rates)
+}
+
+// @Summary Set exchange rate
+// @Description Create or replace the rate used to convert the base currency into the quote currency
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param ExchangeRateRequestDto body dto.ExchangeRateRequestDto true "Exchange Rate Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /rates [put]
+func (e *ExchangeRateController) PutHandler(ctx *gin.Context) {
+	var payload dto.ExchangeRateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	rate, err := e.rateUc.SetRate(ctx.Request.Context(), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Exchange rate saved successfully", rate)
+}
+
+func (e *ExchangeRateController) Route() {
+	e.rg.GET(config.GetRatesList, e.authMid.RequireToken("customer", "reseller", "admin"), e.GetAllHandler)
+	e.rg.PUT(config.PutRates, e.authMid.RequireToken("admin"), e.PutHandler)
+}
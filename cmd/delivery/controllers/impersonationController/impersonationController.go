@@ -0,0 +1,60 @@
+package impersonationController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ImpersonationController struct {
+	impersonationUc usecase.ImpersonationUseCase
+	rg              *gin.RouterGroup
+	authMid         middlewares.AuthMiddleware
+}
+
+func NewImpersonationController(impersonationUc usecase.ImpersonationUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *ImpersonationController {
+	return &ImpersonationController{impersonationUc: impersonationUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Impersonate user
+// @Description Get a short lived token to act as a customer or reseller, send it as Bearer token. Its responses carry the X-Impersonated-By header, every request made with it is written to the audit log, and it can not change the password, email or 2FA of the user, delete the account, export its data or manage API keys and OAuth clients.
+// @Tags users
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /admin/impersonate/{userId} [post]
+func (i *ImpersonationController) ImpersonateHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("userId"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	impersonation, err := i.impersonationUc.Impersonate(ctx.Request.Context(), actorID, uint(userID), ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Impersonation started", impersonation)
+}
+
+func (i *ImpersonationController) Route() {
+	i.rg.POST(config.PostImpersonate, i.authMid.RequireToken("admin"), i.ImpersonateHandler)
+}
@@ -0,0 +1,130 @@
+package importController
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	importUc usecase.ImportUseCase
+	rg       *gin.RouterGroup
+	authMid  middlewares.AuthMiddleware
+}
+
+func NewImportController(importUc usecase.ImportUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *ImportController {
+	return &ImportController{importUc: importUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Import products
+// @Description Create or update products from a CSV file (header: sku,name,description,category,stock,price,currency) or JSON Lines, matching by SKU or by name. Large files run in the background and respond with 202, poll GET /imports/{id} for the report.
+// @Tags products
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "csv or ndjson, defaults to the Content-Type"
+// @Param async query bool false "Always run in the background"
+// @Success 200 {object} model.SingleResponse
+// @Success 202 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 415 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /products/import [post]
+func (i *ImportController) ImportHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	format := importFormat(ctx)
+	if format == "" {
+		common.SendErrorResponse(ctx, http.StatusUnsupportedMediaType, "Send the file as text/csv or application/x-ndjson")
+		return
+	}
+	async, _ := strconv.ParseBool(ctx.Query("async"))
+
+	job, err := i.importUc.ImportProducts(ctx.Request.Context(), userID, format, ctx.Request.Body, ctx.Request.ContentLength, async)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if job.Status == entity.ImportStatusPending {
+		common.SendAcceptedResponse(ctx, "Import started", job)
+		return
+	}
+	common.SendSingleResponse(ctx, "Import finished", job)
+}
+
+// @Summary Get import
+// @Description Get the status and per-row report of a product import
+// @Tags products
+// @Produce json
+// @Param id path string true "Import ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /imports/{id} [get]
+func (i *ImportController) GetByIDHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid import ID"))
+		return
+	}
+
+	isAdmin := middlewares.GetUserRole(ctx) == "admin"
+
+	job, err := i.importUc.FindImport(ctx.Request.Context(), userID, isAdmin, uint(id))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", job)
+}
+
+// importFormat reads the format from the query or falls back to the request Content-Type
+func importFormat(ctx *gin.Context) string {
+	switch strings.ToLower(ctx.Query("format")) {
+	case "csv":
+		return usecase.ImportFormatCSV
+	case "ndjson", "jsonl":
+		return usecase.ImportFormatNDJSON
+	case "":
+	default:
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return usecase.ImportFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return usecase.ImportFormatNDJSON
+	default:
+		return ""
+	}
+}
+
+func (i *ImportController) Route() {
+	i.rg.POST(config.PostProductsImport, i.authMid.RequireToken("reseller", "admin"), i.ImportHandler)
+	i.rg.GET(config.GetImports, i.authMid.RequireToken("reseller", "admin"), i.GetByIDHandler)
+}
@@ -0,0 +1,307 @@
+package oauthController
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type OAuthController struct {
+	oauthUc usecase.OAuthUseCase
+	rg      *gin.RouterGroup
+	authMid middlewares.AuthMiddleware
+}
+
+func NewOAuthController(oauthUc usecase.OAuthUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *OAuthController {
+	return &OAuthController{oauthUc: oauthUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Register OAuth client
+// @Description Register an application that acts on behalf of users. Confidential clients get a secret that is only shown in this response, public clients (browser and mobile apps) have none.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param OAuthClientCreateRequestDto body dto.OAuthClientCreateRequestDto true "OAuth Client Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /oauth/clients [post]
+func (o *OAuthController) CreateClientHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.OAuthClientCreateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	client, err := o.oauthUc.RegisterClient(ctx.Request.Context(), userID, payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "OAuth client registered, store the secret now as it can not be shown again", client)
+}
+
+// @Summary Get OAuth clients
+// @Description Get the OAuth clients registered by the current user, secrets are never returned
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /oauth/clients [get]
+func (o *OAuthController) GetClientsHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	clients, err := o.oauthUc.FindClients(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", clients)
+}
+
+// @Summary Revoke OAuth client
+// @Description Revoke an OAuth client of the current user and every token issued to it, admins can revoke any client
+// @Tags oauth
+// @Produce json
+// @Param id path string true "Client ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /oauth/clients/{id} [delete]
+func (o *OAuthController) RevokeClientHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	isAdmin := middlewares.GetUserRole(ctx) == "admin"
+	if err := o.oauthUc.RevokeClient(ctx.Request.Context(), userID, isAdmin, ctx.Param("id"), ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "OAuth client revoked successfully")
+}
+
+// @Summary Check authorization request
+// @Description Check an authorization request of an OAuth client for the logged in user and describe the consent to show. PKCE with S256 is required.
+// @Tags oauth
+// @Produce json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string false "Registered redirect URI, optional when the client has only one"
+// @Param scope query string false "Space separated scopes, all scopes of the client when empty"
+// @Param state query string false "Opaque value returned to the client"
+// @Param code_challenge query string true "Base64url SHA-256 of the code verifier"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /oauth/authorize [get]
+func (o *OAuthController) AuthorizeHandler(ctx *gin.Context) {
+	var request dto.OAuthAuthorizeRequestDto
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	consent, err := o.oauthUc.Authorize(ctx.Request.Context(), request)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", consent)
+}
+
+// @Summary Answer authorization request
+// @Description Approve or deny an authorization request for the logged in user. The response holds the redirect_uri to send the user to, with a code or an access_denied error.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param OAuthConsentRequestDto body dto.OAuthConsentRequestDto true "Consent Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /oauth/authorize [post]
+func (o *OAuthController) ConsentHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var request dto.OAuthConsentRequestDto
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	redirect, err := o.oauthUc.Consent(ctx.Request.Context(), userID, request, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", redirect)
+}
+
+// @Summary Issue OAuth tokens
+// @Description Token endpoint of RFC 6749 for the authorization_code (with PKCE), client_credentials and refresh_token grants. Clients authenticate with HTTP Basic or client_id and client_secret, public clients send their client_id alone. Errors follow RFC 6749 section 5.2.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, client_credentials or refresh_token"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI of the authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param scope formData string false "Space separated scopes"
+// @Param client_id formData string false "Client ID"
+// @Param client_secret formData string false "Client secret"
+// @Success 200 {object} dto.OAuthTokenResponseDto
+// @Failure 400 {object} dto.OAuthErrorDto
+// @Failure 401 {object} dto.OAuthErrorDto
+// @Router /oauth/token [post]
+func (o *OAuthController) TokenHandler(ctx *gin.Context) {
+	var request dto.OAuthTokenRequestDto
+	if err := ctx.ShouldBindWith(&request, binding.FormPost); err != nil {
+		sendOAuthError(ctx, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "the request must be form encoded"})
+		return
+	}
+
+	token, err := o.oauthUc.Token(ctx.Request.Context(), clientAuth(ctx), request)
+	if err != nil {
+		sendOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.JSON(http.StatusOK, token)
+}
+
+// @Summary Introspect OAuth token
+// @Description Token introspection of RFC 7662 for confidential clients, tokens of other clients are reported inactive
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access or refresh token"
+// @Param token_type_hint formData string false "access_token or refresh_token, ignored"
+// @Success 200 {object} dto.OAuthIntrospectionDto
+// @Failure 400 {object} dto.OAuthErrorDto
+// @Failure 401 {object} dto.OAuthErrorDto
+// @Router /oauth/introspect [post]
+func (o *OAuthController) IntrospectHandler(ctx *gin.Context) {
+	token := ctx.PostForm("token")
+	if token == "" {
+		sendOAuthError(ctx, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "token is required"})
+		return
+	}
+
+	introspection, err := o.oauthUc.Introspect(ctx.Request.Context(), clientAuth(ctx), token)
+	if err != nil {
+		sendOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, introspection)
+}
+
+// @Summary Revoke OAuth token
+// @Description Token revocation of RFC 7009, revoking either token of a grant revokes both. Unknown tokens are answered with 200 too.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Access or refresh token"
+// @Param token_type_hint formData string false "access_token or refresh_token, ignored"
+// @Success 200
+// @Failure 400 {object} dto.OAuthErrorDto
+// @Failure 401 {object} dto.OAuthErrorDto
+// @Router /oauth/revoke [post]
+func (o *OAuthController) RevokeHandler(ctx *gin.Context) {
+	token := ctx.PostForm("token")
+	if token == "" {
+		sendOAuthError(ctx, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "token is required"})
+		return
+	}
+
+	if err := o.oauthUc.Revoke(ctx.Request.Context(), clientAuth(ctx), token); err != nil {
+		sendOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// clientAuth reads the client credentials from HTTP Basic, whose parts are form encoded, or from the form
+func clientAuth(ctx *gin.Context) dto.OAuthClientAuthDto {
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		if decodedID, err := url.QueryUnescape(id); err == nil {
+			id = decodedID
+		}
+		if decodedSecret, err := url.QueryUnescape(secret); err == nil {
+			secret = decodedSecret
+		}
+		return dto.OAuthClientAuthDto{ClientID: id, ClientSecret: secret}
+	}
+	return dto.OAuthClientAuthDto{ClientID: ctx.PostForm("client_id"), ClientSecret: ctx.PostForm("client_secret")}
+}
+
+// sendOAuthError answers the errors of the token, introspection and revocation endpoints as RFC 6749 expects,
+// other errors go to ErrorHandler
+func sendOAuthError(ctx *gin.Context, err error) {
+	var oauthErr *usecase.OAuthError
+	if !errors.As(err, &oauthErr) {
+		ctx.Error(err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == usecase.OAuthInvalidClient {
+		ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, dto.OAuthErrorDto{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
+
+func (o *OAuthController) Route() {
+	o.rg.POST(config.PostOAuthClients, o.authMid.RequireToken("reseller", "admin"), o.CreateClientHandler)
+	o.rg.GET(config.GetOAuthClients, o.authMid.RequireToken("reseller", "admin"), o.GetClientsHandler)
+	o.rg.DELETE(config.DelOAuthClients, o.authMid.RequireToken("reseller", "admin"), o.RevokeClientHandler)
+	o.rg.GET(config.GetOAuthAuthorize, o.authMid.RequireToken("customer", "reseller", "admin"), o.AuthorizeHandler)
+	o.rg.POST(config.PostOAuthAuthorize, o.authMid.RequireToken("customer", "reseller", "admin"), o.ConsentHandler)
+	o.rg.POST(config.PostOAuthToken, o.TokenHandler)
+	o.rg.POST(config.PostOAuthIntrospect, o.IntrospectHandler)
+	o.rg.POST(config.PostOAuthRevoke, o.RevokeHandler)
+}
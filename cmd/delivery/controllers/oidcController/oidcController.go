@@ -0,0 +1,109 @@
+package oidcController
+
+import (
+	"net/http"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+// stateCookie keeps the state token of a login between start and callback, it is only sent to the OIDC routes
+const (
+	stateCookie     = "oidc_state"
+	stateCookiePath = config.ApiGroup + "/auth/oidc/"
+)
+
+type OIDCController struct {
+	oidcUc  usecase.OIDCUseCase
+	rg      *gin.RouterGroup
+	authMid middlewares.AuthMiddleware
+	cookies config.CookieConfig
+}
+
+func NewOIDCController(oidcUc usecase.OIDCUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware, cookies config.CookieConfig) *OIDCController {
+	return &OIDCController{oidcUc: oidcUc, rg: rg, authMid: authMid, cookies: cookies}
+}
+
+// @Summary Start an OpenID Connect login
+// @Description Redirect the browser to the identity provider, it comes back to the callback of the same provider
+// @Tags auth
+// @Param provider path string true "Name of the identity provider"
+// @Success 302
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/oidc/{provider}/start [get]
+func (o *OIDCController) StartHandler(ctx *gin.Context) {
+	start, err := o.oidcUc.Start(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	o.setStateCookie(ctx, start.StateToken, int(start.StateTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, start.AuthURL)
+}
+
+// @Summary Complete an OpenID Connect login
+// @Description The identity provider redirects here. The identity is linked to the user with its verified email, or a new user is registered; users with 2FA enabled get a challenge token for /auth/2fa/login unless the provider asked for a second factor.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Name of the identity provider"
+// @Param code query string false "Authorization code"
+// @Param state query string true "State of the login"
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/oidc/{provider}/callback [get]
+func (o *OIDCController) CallbackHandler(ctx *gin.Context) {
+	var payload dto.OIDCCallbackRequestDto
+	if err := ctx.ShouldBindQuery(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	// The state can be used once, whatever the outcome of the login
+	stateToken, _ := ctx.Cookie(stateCookie)
+	o.setStateCookie(ctx, "", -1)
+
+	token, err := o.oidcUc.Callback(ctx.Request.Context(), ctx.Param("provider"), payload, stateToken, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if token.TwoFactorRequired {
+		common.SendSingleResponse(ctx, "Two-factor authentication required", token)
+		return
+	}
+
+	// Set token to cookie
+	o.authMid.SetTokenCookie(ctx, token.Token)
+
+	common.SendSuccessResponse(ctx, "Successfully Login")
+}
+
+// setStateCookie sets the state cookie with the domain and Secure flag of the token cookie. It is always Lax, so it
+// comes along on the redirect back from the provider whatever COOKIE_SAMESITE says.
+func (o *OIDCController) setStateCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     stateCookie,
+		Value:    value,
+		Path:     stateCookiePath,
+		Domain:   o.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   o.cookies.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (o *OIDCController) Route() {
+	o.rg.GET(config.GetOIDCStart, o.StartHandler)
+	o.rg.GET(config.GetOIDCCallback, o.CallbackHandler)
+}
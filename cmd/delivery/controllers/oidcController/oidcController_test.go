@@ -0,0 +1,70 @@
+package oidcController
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+// stubOIDCUseCase starts every login with the same state and refuses every callback
+type stubOIDCUseCase struct{}
+
+func (stubOIDCUseCase) Start(ctx context.Context, provider string) (dto.OIDCStartDto, error) {
+	return dto.OIDCStartDto{AuthURL: "https://idp.example.com/authorize", StateToken: "state-token", StateTTL: 10 * time.Minute}, nil
+}
+
+func (stubOIDCUseCase) Callback(ctx context.Context, provider string, payload dto.OIDCCallbackRequestDto, stateToken, ip, userAgent string) (dto.AuthResponseDto, error) {
+	return dto.AuthResponseDto{}, usecase.ErrInvalidOIDCState
+}
+
+func TestStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		cookies config.CookieConfig
+	}{
+		{name: "defaults", cookies: config.CookieConfig{SameSite: http.SameSiteLaxMode}},
+		{name: "secure with domain", cookies: config.CookieConfig{Secure: true, Domain: "example.com", SameSite: http.SameSiteStrictMode}},
+		{name: "same site none", cookies: config.CookieConfig{Secure: true, SameSite: http.SameSiteNoneMode}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.Use(middlewares.ErrorHandler())
+			NewOIDCController(stubOIDCUseCase{}, engine.Group(config.ApiGroup), nil, tt.cookies).Route()
+
+			check := func(step string, req *http.Request, maxAge int) {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, req)
+
+				var cookie *http.Cookie
+				for _, c := range w.Result().Cookies() {
+					if c.Name == stateCookie {
+						cookie = c
+					}
+				}
+				if cookie == nil {
+					t.Fatalf("%s: no state cookie", step)
+				}
+				if cookie.Secure != tt.cookies.Secure || cookie.Domain != tt.cookies.Domain || cookie.SameSite != http.SameSiteLaxMode ||
+					!cookie.HttpOnly || cookie.Path != stateCookiePath || cookie.MaxAge != maxAge {
+					t.Errorf("%s: cookie %+v", step, cookie)
+				}
+			}
+
+			check("start", httptest.NewRequest(http.MethodGet, config.ApiGroup+"/auth/oidc/corp/start", nil), 600)
+
+			callback := httptest.NewRequest(http.MethodGet, config.ApiGroup+"/auth/oidc/corp/callback?state=state&code=code", nil)
+			callback.AddCookie(&http.Cookie{Name: stateCookie, Value: "state-token"})
+			check("callback", callback, -1)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package privacyController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyController struct {
+	privacyUc usecase.PrivacyUseCase
+	rg        *gin.RouterGroup
+	authMid   middlewares.AuthMiddleware
+}
+
+func NewPrivacyController(privacyUc usecase.PrivacyUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *PrivacyController {
+	return &PrivacyController{privacyUc: privacyUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Export my data
+// @Description Start building a ZIP archive with the profile, enrollments, coupon redemptions, reviews, wishlist and audit entries of the logged in user. Poll GET /profiles/me/data-export/{id} for the download URL.
+// @Tags profile
+// @Produce json
+// @Success 202 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me/data-export [post]
+func (p *PrivacyController) RequestExportHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	export, err := p.privacyUc.RequestExport(ctx.Request.Context(), userID, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendAcceptedResponse(ctx, "Data export started", export)
+}
+
+// @Summary Get my data export
+// @Description Get the status of a data export, completed exports carry a signed download URL valid until the export expires
+// @Tags profile
+// @Produce json
+// @Param id path string true "Data Export ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me/data-export/{id} [get]
+func (p *PrivacyController) GetExportHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	export, err := p.privacyUc.FindExport(ctx.Request.Context(), userID, ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", export)
+}
+
+// @Summary Download data export
+// @Description Download the ZIP archive of a data export through the signed URL of the export
+// @Tags profile
+// @Produce application/zip
+// @Param token query string true "Signed download token"
+// @Success 200 {file} file
+// @Failure 403 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /data-exports/download [get]
+func (p *PrivacyController) DownloadHandler(ctx *gin.Context) {
+	path, err := p.privacyUc.OpenExport(ctx.Request.Context(), ctx.Query("token"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.FileAttachment(path, "personal-data.zip")
+}
+
+// @Summary Erase user
+// @Description Erase the personal data of a user on their request: the profile is anonymized, orders and coupon redemptions are kept for the books, and the erasure is recorded without the email
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/erase [post]
+func (p *PrivacyController) EraseHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	if err := p.privacyUc.Erase(ctx.Request.Context(), actorID, uint(userID), entity.ErasureChannelAdmin, ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "User erased successfully")
+}
+
+func (p *PrivacyController) Route() {
+	p.rg.POST(config.PostProfileMeDataExport, p.authMid.RequireToken("customer", "reseller", "admin"), p.RequestExportHandler)
+	p.rg.GET(config.GetProfileMeDataExport, p.authMid.RequireToken("customer", "reseller", "admin"), p.GetExportHandler)
+	p.rg.GET(config.GetDataExportDownload, p.DownloadHandler)
+	p.rg.POST(config.PostUsersErase, p.authMid.RequireToken("admin"), p.EraseHandler)
+}
@@ -1,19 +1,19 @@
 package productController
 
 import (
-	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/altsaqif/go-rest/cmd/config"
 	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
-	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
 	"github.com/altsaqif/go-rest/cmd/shared/common"
-	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/export"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
 	"github.com/altsaqif/go-rest/cmd/usecase"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type ProductController struct {
@@ -27,55 +27,97 @@ func NewProductController(productUc usecase.ProductUseCase, rg *gin.RouterGroup,
 }
 
 // @Summary Get all products
-// @Description Get a list of all products with pagination
+// @Description Get a page of products, searched by name, SKU and description and filtered by category and stock
 // @Tags products
 // @Produce json
+// @Param q query string false "Search term"
+// @Param category query string false "Exact category"
+// @Param in_stock query bool false "Only products with (true) or without (false) stock"
+// @Param sort query string false "Comma separated fields of id, sku, name, category, stock and created_at, a leading - sorts descending"
 // @Param page query int false "Page number"
-// @Param size query int false "Page size"
+// @Param size query int false "Page size, at most 100"
+// @Param currency query string false "ISO 4217 currency to convert prices into"
 // @Success 200 {object} model.PagedResponse
+// @Failure 400 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Failure 404 {object} model.Status
 // @Router /products [get]
 func (p *ProductController) GetAllHandler(ctx *gin.Context) {
-	page, _ := strconv.Atoi(ctx.Query("page"))
-	size, _ := strconv.Atoi(ctx.Query("size"))
+	var filter dto.ProductFilterDto
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
 
-	if page == 0 {
-		page = 1
+	currency := strings.ToUpper(ctx.Query("currency"))
+	if currency != "" && !money.IsSupported(currency) {
+		ctx.Error(apperror.Validation("Unsupported currency"))
+		return
 	}
-	if size == 0 {
-		size = 10
+
+	products, paging, err := p.productUc.FindAllProducts(ctx.Request.Context(), filter.Spec(), currency)
+	if err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	type result struct {
-		products []dto.ProductWithUsers
-		paging   model.Paging
-		err      error
+	if len(products) == 0 {
+		ctx.Error(apperror.NotFound("Products not found"))
+		return
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		products, paging, err := p.productUc.FindAllProducts(page, size)
-		resultChan <- result{products, paging, err}
-	}()
+	var interfaceSlice = make([]interface{}, len(products))
+	for i, v := range products {
+		interfaceSlice[i] = v
+	}
 
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	common.SendPagedResponse(ctx, interfaceSlice, paging, "Ok")
+}
+
+// @Summary Export products
+// @Description Stream the products matching the filters of the product list as CSV, NDJSON or XLSX, chosen by format or the Accept header
+// @Tags products
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv, ndjson or xlsx"
+// @Param q query string false "Search term"
+// @Param category query string false "Exact category"
+// @Param in_stock query bool false "Only products with (true) or without (false) stock"
+// @Param sort query string false "Comma separated fields of id, sku, name, category, stock and created_at, a leading - sorts descending"
+// @Param currency query string false "ISO 4217 currency to convert prices into"
+// @Success 200 {file} file
+// @Failure 400 {object} model.Status
+// @Failure 406 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /products/export [get]
+func (p *ProductController) ExportHandler(ctx *gin.Context) {
+	var filter dto.ProductFilterDto
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	if len(res.products) == 0 {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "Products not found")
+	currency := strings.ToUpper(ctx.Query("currency"))
+	if currency != "" && !money.IsSupported(currency) {
+		ctx.Error(apperror.Validation("Unsupported currency"))
 		return
 	}
 
-	var interfaceSlice = make([]interface{}, len(res.products))
-	for i, v := range res.products {
-		interfaceSlice[i] = v
+	format := export.Negotiate(ctx.Query("format"), ctx.GetHeader("Accept"))
+	if format == "" {
+		common.SendErrorResponse(ctx, http.StatusNotAcceptable, "Export is available as csv, ndjson or xlsx")
+		return
 	}
 
-	common.SendPagedResponse(ctx, interfaceSlice, res.paging, "Ok")
+	err := common.SendExportResponse(ctx, format, "products", dto.ProductExportColumns, func(w export.Writer) error {
+		return p.productUc.ExportProducts(ctx.Request.Context(), filter.Spec(), currency, func(product dto.ProductExportDto) error {
+			return w.WriteRow(product.Values()...)
+		})
+	})
+	if err != nil {
+		ctx.Error(err)
+	}
 }
 
 // @Summary Get product by ID
@@ -93,40 +135,24 @@ func (p *ProductController) GetByIDHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
 	convUint, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Invalid product ID")
+		ctx.Error(apperror.Validation("Invalid product ID"))
 		return
 	}
 
 	uintValue := uint(convUint)
-	type result struct {
-		product dto.ProductWithUsers
-		err     error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		product, err := p.productUc.FindProductByID(uintValue)
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		// Customize the error message for "record not found"
-		if errors.Is(res.err, gorm.ErrRecordNotFound) {
-			common.SendErrorResponse(ctx, http.StatusNotFound, "Product not found")
-		} else {
-			common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
-		}
+	product, err := p.productUc.FindProductByID(ctx.Request.Context(), uintValue)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	// Check if the product is empty
-	if res.product.ID == 0 {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "Product not found")
+	if product.ID == 0 {
+		ctx.Error(apperror.NotFound("Product not found"))
 		return
 	}
 
-	common.SendSingleResponse(ctx, "Ok", res.product)
+	common.SendSingleResponse(ctx, "Ok", product)
 }
 
 // @Summary Get products by stock
@@ -143,34 +169,23 @@ func (p *ProductController) GetByStockHandler(ctx *gin.Context) {
 	stockStr := ctx.Param("stock")
 	stock, err := strconv.Atoi(stockStr)
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Invalid stock value")
+		ctx.Error(apperror.Validation("Invalid stock value"))
 		return
 	}
 
-	type result struct {
-		products []dto.ProductWithUsers
-		err      error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		products, err := p.productUc.FindProductsByStock(stock)
-		resultChan <- result{products, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	products, err := p.productUc.FindProductsByStock(ctx.Request.Context(), stock)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
 	// Check if no products are found
-	if len(res.products) == 0 {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "Products not found")
+	if len(products) == 0 {
+		ctx.Error(apperror.NotFound("Products not found"))
 		return
 	}
 
-	common.SendSingleResponse(ctx, "Ok", res.products)
+	common.SendSingleResponse(ctx, "Ok", products)
 }
 
 // @Summary Create product
@@ -178,36 +193,26 @@ func (p *ProductController) GetByStockHandler(ctx *gin.Context) {
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param Product body entity.Product true "Product Payload"
+// @Param ProductCreateRequestDto body dto.ProductCreateRequestDto true "Product Payload"
 // @Success 201 {object} model.SingleResponse
 // @Failure 400 {object} model.Status
+// @Failure 409 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Router /products [post]
 func (p *ProductController) CreateHandler(ctx *gin.Context) {
-	var product entity.Product
-	if err := ctx.ShouldBindJSON(&product); err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, err.Error())
+	var payload dto.ProductCreateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	type result struct {
-		createdProduct dto.ProductWithUsers
-		err            error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		createdProduct, err := p.productUc.CreateProduct(product)
-		resultChan <- result{createdProduct, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	createdProduct, err := p.productUc.CreateProduct(ctx.Request.Context(), payload)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	common.SendSingleResponse(ctx, "Product created successfully", res.createdProduct)
+	common.SendSingleResponse(ctx, "Product created successfully", createdProduct)
 }
 
 // @Summary Update product
@@ -216,49 +221,35 @@ func (p *ProductController) CreateHandler(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
-// @Param Product body entity.Product true "Product Payload"
+// @Param ProductUpdateRequestDto body dto.ProductUpdateRequestDto true "Product Payload"
 // @Success 200 {object} model.SingleResponse
 // @Failure 400 {object} model.Status
 // @Failure 400 {object} model.Status
 // @Failure 404 {object} model.Status
+// @Failure 409 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Router /products/{id} [put]
 func (p *ProductController) UpdateHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
 	convUint, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Invalid product ID")
+		ctx.Error(apperror.Validation("Invalid product ID"))
 		return
 	}
 
 	uintValue := uint(convUint)
-	var payload entity.Product
+	var payload dto.ProductUpdateRequestDto
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Invalid request payload")
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	type result struct {
-		product dto.ProductWithUsers
-		err     error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		product, err := p.productUc.UpdateProduct(uintValue, payload)
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		if errors.Is(res.err, gorm.ErrRecordNotFound) {
-			common.SendErrorResponse(ctx, http.StatusNotFound, "Product not found")
-		} else {
-			common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
-		}
+	product, err := p.productUc.UpdateProduct(ctx.Request.Context(), uintValue, payload)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	common.SendSingleResponse(ctx, "Product updated successfully", res.product)
+	common.SendSingleResponse(ctx, "Product updated successfully", product)
 }
 
 // @Summary Delete product
@@ -276,37 +267,26 @@ func (p *ProductController) DeleteHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
 	convUint, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusBadRequest, "Invalid product ID")
+		ctx.Error(apperror.Validation("Invalid product ID"))
 		return
 	}
 
 	uintValue := uint(convUint)
 
 	// First, check if the product exists
-	exists, err := p.productUc.ProductExists(uintValue)
+	exists, err := p.productUc.ProductExists(ctx.Request.Context(), uintValue)
 	if err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		ctx.Error(err)
 		return
 	}
 	if !exists {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "Product not found")
+		ctx.Error(apperror.NotFound("Product not found"))
 		return
 	}
 
 	// Proceed to delete the product
-	type result struct {
-		err error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		err := p.productUc.DeleteProduct(uintValue)
-		resultChan <- result{err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	if err := p.productUc.DeleteProduct(ctx.Request.Context(), uintValue); err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -315,6 +295,7 @@ func (p *ProductController) DeleteHandler(ctx *gin.Context) {
 
 func (p *ProductController) Route() {
 	p.rg.GET(config.GetProductsList, p.authMid.RequireToken("customer", "reseller", "admin"), p.GetAllHandler)
+	p.rg.GET(config.GetProductsExport, p.authMid.RequireToken("admin"), p.ExportHandler)
 	p.rg.GET(config.GetProducts, p.authMid.RequireToken("customer", "reseller", "admin"), p.GetByIDHandler)
 	p.rg.GET(config.GetProductsByStocks, p.authMid.RequireToken("reseller", "admin"), p.GetByStockHandler)
 	p.rg.POST(config.PostProducts, p.authMid.RequireToken("reseller", "admin"), p.CreateHandler)
@@ -0,0 +1,83 @@
+package productController
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/delivery/validators"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testdb.New(t)
+	if err := common.SetupValidator(validators.Rules(usecase.NewUserUseCase(repository.NewUserRepository(db)))...); err != nil {
+		t.Fatalf("setup validator: %v", err)
+	}
+	productUc := usecase.NewProductUseCase(repository.NewProductRepository(db, nil), nil, usecase.NewAuditUseCase(repository.NewAuditLogRepository(db)), "USD")
+	for _, product := range []dto.ProductCreateRequestDto{
+		{Name: "Red Mug", Description: "Ceramic", Category: "kitchen", Stock: 3, Price: money.New(500, "USD")},
+		{Name: "Blue Mug", Description: "Ceramic", Category: "kitchen", Stock: 0, Price: money.New(500, "USD")},
+		{Name: "Mug Poster", Description: "Paper", Category: "art", Stock: 7, Price: money.New(900, "USD")},
+		{Name: "Green Mug", Description: "Ceramic", Category: "kitchen", Stock: 9, Price: money.New(500, "USD")},
+	} {
+		if _, err := productUc.CreateProduct(context.Background(), product); err != nil {
+			t.Fatalf("create product: %v", err)
+		}
+	}
+
+	engine := gin.New()
+	engine.Use(middlewares.ErrorHandler())
+	controller := NewProductController(productUc, engine.Group(config.ApiGroup), nil)
+	engine.GET(config.ApiGroup+config.GetProductsExport, controller.ExportHandler)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{name: "unfiltered", query: "", status: http.StatusOK, want: []string{"Red Mug", "Blue Mug", "Mug Poster", "Green Mug"}},
+		{name: "category and stock", query: "category=kitchen&in_stock=true", status: http.StatusOK, want: []string{"Red Mug", "Green Mug"}},
+		{name: "search sorted", query: "q=mug&category=kitchen&sort=-stock", status: http.StatusOK, want: []string{"Green Mug", "Red Mug", "Blue Mug"}},
+		{name: "out of stock", query: "in_stock=false", status: http.StatusOK, want: []string{"Blue Mug"}},
+		{name: "unknown sort field", query: "sort=price", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, config.ApiGroup+config.GetProductsExport+"?format=csv&"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+			if err != nil {
+				t.Fatalf("read csv: %v", err)
+			}
+			var names []string
+			for _, record := range records[1:] {
+				names = append(names, record[2])
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("exported %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package reviewController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewController struct {
+	reviewUc usecase.ReviewUseCase
+	rg       *gin.RouterGroup
+	authMid  middlewares.AuthMiddleware
+}
+
+func NewReviewController(reviewUc usecase.ReviewUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *ReviewController {
+	return &ReviewController{reviewUc: reviewUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get product reviews
+// @Description Get the reviews of a product with pagination, hidden reviews are only listed for admins
+// @Tags reviews
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param page query int false "Page number"
+// @Param size query int false "Page size"
+// @Success 200 {object} model.PagedResponse
+// @Failure 400 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /products/{id}/reviews [get]
+func (r *ReviewController) GetByProductHandler(ctx *gin.Context) {
+	productID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid product ID"))
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	size, _ := strconv.Atoi(ctx.Query("size"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+
+	includeHidden := middlewares.GetUserRole(ctx) == "admin"
+
+	reviews, paging, err := r.reviewUc.FindProductReviews(ctx.Request.Context(), uint(productID), includeHidden, page, size)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	var interfaceSlice = make([]interface{}, len(reviews))
+	for i, v := range reviews {
+		interfaceSlice[i] = v
+	}
+
+	common.SendPagedResponse(ctx, interfaceSlice, paging, "Ok")
+}
+
+// @Summary Create review
+// @Description Review a product the current user is enrolled in, once per product
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param ReviewRequestDto body dto.ReviewRequestDto true "Review Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /products/{id}/reviews [post]
+func (r *ReviewController) CreateHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	productID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid product ID"))
+		return
+	}
+
+	var payload dto.ReviewRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	review, err := r.reviewUc.CreateReview(ctx.Request.Context(), userID, uint(productID), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "Review created successfully", review)
+}
+
+// @Summary Update review
+// @Description Update a review written by the current user
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param id path string true "Review ID"
+// @Param ReviewRequestDto body dto.ReviewRequestDto true "Review Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /reviews/{id} [put]
+func (r *ReviewController) UpdateHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	reviewID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid review ID"))
+		return
+	}
+
+	var payload dto.ReviewRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	review, err := r.reviewUc.UpdateReview(ctx.Request.Context(), userID, uint(reviewID), payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Review updated successfully", review)
+}
+
+// @Summary Delete review
+// @Description Delete a review written by the current user
+// @Tags reviews
+// @Produce json
+// @Param id path string true "Review ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /reviews/{id} [delete]
+func (r *ReviewController) DeleteHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	reviewID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid review ID"))
+		return
+	}
+
+	if err := r.reviewUc.DeleteReview(ctx.Request.Context(), userID, uint(reviewID)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Review deleted successfully")
+}
+
+// @Summary Hide review
+// @Description Hide a review from customers and exclude it from the product rating
+// @Tags reviews
+// @Produce json
+// @Param id path string true "Review ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /reviews/{id}/hide [post]
+func (r *ReviewController) HideHandler(ctx *gin.Context) {
+	r.setHidden(ctx, true, "Review hidden successfully")
+}
+
+// @Summary Unhide review
+// @Description Show a hidden review again and include it in the product rating
+// @Tags reviews
+// @Produce json
+// @Param id path string true "Review ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /reviews/{id}/unhide [post]
+func (r *ReviewController) UnhideHandler(ctx *gin.Context) {
+	r.setHidden(ctx, false, "Review unhidden successfully")
+}
+
+func (r *ReviewController) setHidden(ctx *gin.Context, hidden bool, message string) {
+	reviewID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid review ID"))
+		return
+	}
+
+	review, err := r.reviewUc.SetReviewHidden(ctx.Request.Context(), uint(reviewID), hidden)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, message, review)
+}
+
+func (r *ReviewController) Route() {
+	r.rg.GET(config.GetProductReviews, r.authMid.RequireToken("customer", "reseller", "admin"), r.GetByProductHandler)
+	r.rg.POST(config.PostProductReviews, r.authMid.RequireToken("customer", "reseller", "admin"), r.CreateHandler)
+	r.rg.PUT(config.PutReviews, r.authMid.RequireToken("customer", "reseller", "admin"), r.UpdateHandler)
+	r.rg.DELETE(config.DelReviews, r.authMid.RequireToken("customer", "reseller", "admin"), r.DeleteHandler)
+	r.rg.POST(config.PostReviewsHide, r.authMid.RequireToken("admin"), r.HideHandler)
+	r.rg.POST(config.PostReviewsUnhide, r.authMid.RequireToken("admin"), r.UnhideHandler)
+}
@@ -0,0 +1,139 @@
+package sessionController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	sessionUc usecase.SessionUseCase
+	rg        *gin.RouterGroup
+	authMid   middlewares.AuthMiddleware
+}
+
+func NewSessionController(sessionUc usecase.SessionUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *SessionController {
+	return &SessionController{sessionUc: sessionUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get my sessions
+// @Description Get the active sessions of the current user with their device and last activity, the session of the request is marked as current
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/sessions [get]
+func (s *SessionController) GetAllHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	sessions, err := s.sessionUc.FindSessions(ctx.Request.Context(), userID, middlewares.GetSessionID(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", sessions)
+}
+
+// @Summary Revoke my session
+// @Description Log out a session of the current user, its tokens are rejected from now on
+// @Tags auth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /auth/sessions/{id} [delete]
+func (s *SessionController) RevokeHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	if err := s.sessionUc.RevokeSession(ctx.Request.Context(), userID, userID, ctx.Param("id"), ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Session revoked successfully")
+}
+
+// @Summary Get user sessions
+// @Description Get the active sessions of a user
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/sessions [get]
+func (s *SessionController) GetUserSessionsHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	sessions, err := s.sessionUc.FindSessions(ctx.Request.Context(), uint(userID), middlewares.GetSessionID(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", sessions)
+}
+
+// @Summary Revoke user session
+// @Description Log out a session of a user, its tokens are rejected from now on
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param session path string true "Session ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/sessions/{session} [delete]
+func (s *SessionController) RevokeUserSessionHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	if err := s.sessionUc.RevokeSession(ctx.Request.Context(), actorID, uint(userID), ctx.Param("session"), ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Session revoked successfully")
+}
+
+func (s *SessionController) Route() {
+	s.rg.GET(config.GetSessions, s.authMid.RequireToken("customer", "reseller", "admin"), s.GetAllHandler)
+	s.rg.DELETE(config.DelSessions, s.authMid.RequireToken("customer", "reseller", "admin"), s.RevokeHandler)
+	s.rg.GET(config.GetUsersSessions, s.authMid.RequireToken("admin"), s.GetUserSessionsHandler)
+	s.rg.DELETE(config.DelUsersSessions, s.authMid.RequireToken("admin"), s.RevokeUserSessionHandler)
+}
@@ -1,83 +1,112 @@
 package userController
 
 import (
-	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/altsaqif/go-rest/cmd/config"
 	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
 	"github.com/altsaqif/go-rest/cmd/shared/common"
-	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/export"
 	"github.com/altsaqif/go-rest/cmd/usecase"
 	"github.com/gin-gonic/gin"
 )
 
 type UserController struct {
-	userUc  usecase.UserUseCase
-	rg      *gin.RouterGroup
-	authMid middlewares.AuthMiddleware
+	userUc    usecase.UserUseCase
+	attemptUc usecase.LoginAttemptUseCase
+	accountUc usecase.AccountUseCase
+	rg        *gin.RouterGroup
+	authMid   middlewares.AuthMiddleware
 }
 
 // @Summary Get all users
-// @Description Get a list of all users with pagination
+// @Description Get a page of users, searched by email and names and filtered by role, creation date, enrollments and status
 // @Tags users
 // @Produce json
+// @Param q query string false "Search term"
+// @Param role query string false "customer, reseller or admin"
+// @Param created_after query string false "Created on or after this date, YYYY-MM-DD"
+// @Param created_before query string false "Created before this date, YYYY-MM-DD"
+// @Param has_products query bool false "Only users with (true) or without (false) products"
+// @Param status query string false "active, deactivated or unverified"
+// @Param sort query string false "Comma separated fields of id, email, firstname, lastname, role and created_at, a leading - sorts descending"
 // @Param page query int false "Page number"
-// @Param size query int false "Page size"
+// @Param size query int false "Page size, at most 100"
 // @Success 200 {object} model.PagedResponse
+// @Failure 400 {object} model.Status
 // @Failure 500 {object} model.Status
 // @Failure 404 {object} model.Status
 // @Router /profiles [get]
 func (u *UserController) GetAllHandler(ctx *gin.Context) {
-	page, errPage := strconv.Atoi(ctx.DefaultQuery("page", "1"))
-	if errPage != nil {
-		log.Fatal("Error : ", errPage)
+	var filter dto.UserFilterDto
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
 	}
 
-	size, errSize := strconv.Atoi(ctx.DefaultQuery("size", "10"))
-	if errSize != nil {
-		log.Fatal("Error : ", errSize)
+	users, paging, err := u.userUc.FindAllUsers(ctx.Request.Context(), filter.Spec())
+	if err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	if page < 1 {
-		page = 1
-	}
-	if size < 1 {
-		size = 10
+	// Check if no users were found
+	if len(users) == 0 {
+		ctx.Error(apperror.NotFound("User not found"))
+		return
 	}
 
-	type result struct {
-		users  []dto.UserWithProducts
-		paging model.Paging
-		err    error
+	var interfaceSlice = make([]interface{}, len(users))
+	for i, v := range users {
+		interfaceSlice[i] = v
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		users, paging, err := u.userUc.FindAllUsers(page, size)
-		resultChan <- result{users, paging, err}
-	}()
+	common.SendPagedResponse(ctx, interfaceSlice, paging, "Ok")
+}
 
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+// @Summary Export users
+// @Description Stream the users matching the filters of the user list as CSV, NDJSON or XLSX, chosen by format or the Accept header. Passwords are never exported.
+// @Tags users
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv, ndjson or xlsx"
+// @Param q query string false "Search term"
+// @Param role query string false "customer, reseller or admin"
+// @Param created_after query string false "Created on or after this date, YYYY-MM-DD"
+// @Param created_before query string false "Created before this date, YYYY-MM-DD"
+// @Param has_products query bool false "Only users with (true) or without (false) products"
+// @Param status query string false "active, deactivated or unverified"
+// @Param sort query string false "Comma separated fields of id, email, firstname, lastname, role and created_at, a leading - sorts descending"
+// @Success 200 {file} file
+// @Failure 400 {object} model.Status
+// @Failure 406 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/export [get]
+func (u *UserController) ExportHandler(ctx *gin.Context) {
+	var filter dto.UserFilterDto
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.Error(common.BindError(ctx, err))
 		return
 	}
 
-	// Check if no users were found
-	if len(res.users) == 0 {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "User not found")
+	format := export.Negotiate(ctx.Query("format"), ctx.GetHeader("Accept"))
+	if format == "" {
+		common.SendErrorResponse(ctx, http.StatusNotAcceptable, "Export is available as csv, ndjson or xlsx")
 		return
 	}
 
-	var interfaceSlice = make([]interface{}, len(res.users))
-	for i, v := range res.users {
-		interfaceSlice[i] = v
+	err := common.SendExportResponse(ctx, format, "profiles", dto.UserExportColumns, func(w export.Writer) error {
+		return u.userUc.ExportUsers(ctx.Request.Context(), filter.Spec(), func(user dto.UserExportDto) error {
+			return w.WriteRow(user.Values()...)
+		})
+	})
+	if err != nil {
+		ctx.Error(err)
 	}
-
-	common.SendPagedResponse(ctx, interfaceSlice, res.paging, "Ok")
 }
 
 // @Summary Get user by ID
@@ -93,42 +122,335 @@ func (u *UserController) GetHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
 	convUint, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		log.Fatalf("Error parsing string to uint64 : %v", err)
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
 	}
 
 	uintValue := uint(convUint)
 
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+	user, err := u.userUc.FindUserByID(ctx.Request.Context(), uintValue)
+	if err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := u.userUc.FindUserByID(uintValue)
-		resultChan <- result{user, err}
-	}()
+	// Check if user was not found by checking the ID field
+	if user.ID == 0 {
+		ctx.Error(apperror.NotFound("User not found"))
+		return
+	}
 
-	res := <-resultChan
-	if res.err != nil {
-		common.SendErrorResponse(ctx, http.StatusInternalServerError, res.err.Error())
+	common.SendSingleResponse(ctx, "Ok", user)
+}
+
+// @Summary Unlock user
+// @Description Clear the failed logins and lockout of a user
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/unlock [post]
+func (u *UserController) UnlockHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
 		return
 	}
 
-	// Check if user was not found by checking the ID field
-	if res.user.ID == 0 {
-		common.SendErrorResponse(ctx, http.StatusNotFound, "User not found")
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
 		return
 	}
 
-	common.SendSingleResponse(ctx, "Ok", res.user)
+	if err := u.attemptUc.Unlock(ctx.Request.Context(), actorID, uint(userID), ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "User unlocked successfully")
+}
+
+// @Summary Update user
+// @Description Update the names, email or role of any user, a new role revokes the tokens of the user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param UserUpdateRequestDto body dto.UserUpdateRequestDto true "User Update Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id} [patch]
+func (u *UserController) UpdateHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.UserUpdateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	user, err := u.accountUc.UpdateUser(ctx.Request.Context(), actorID, uint(userID), payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "User updated successfully", dto.ConvertUserToProfile(user))
+}
+
+// @Summary Deactivate user
+// @Description Block the login of a user and revoke their tokens
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/deactivate [post]
+func (u *UserController) DeactivateHandler(ctx *gin.Context) {
+	u.setActive(ctx, false, "User deactivated successfully")
+}
+
+// @Summary Activate user
+// @Description Allow a deactivated user to log in again
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/{id}/activate [post]
+func (u *UserController) ActivateHandler(ctx *gin.Context) {
+	u.setActive(ctx, true, "User activated successfully")
+}
+
+// @Summary Bulk update users
+// @Description Change the role of, deactivate or activate every user matching the filter of the listing, except yourself.
+// @Description The filter must not be empty, with dry_run only the matching users are counted.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param UserBulkRequestDto body dto.UserBulkRequestDto true "Bulk Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 403 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/bulk [post]
+func (u *UserController) BulkHandler(ctx *gin.Context) {
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.UserBulkRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	result, err := u.accountUc.BulkUpdate(ctx.Request.Context(), actorID, payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	message := "Users updated successfully"
+	if payload.DryRun {
+		message = "Ok"
+	}
+	common.SendSingleResponse(ctx, message, result)
+}
+
+func (u *UserController) setActive(ctx *gin.Context, active bool, message string) {
+	userID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid user ID"))
+		return
+	}
+
+	actorID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	if err := u.accountUc.SetActive(ctx.Request.Context(), actorID, uint(userID), active, ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, message)
+}
+
+// @Summary Get my profile
+// @Description Get the profile of the logged in user
+// @Tags profile
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me [get]
+func (u *UserController) GetMeHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	user, err := u.userUc.FindUserByID(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", dto.ConvertUserToProfile(user))
+}
+
+// @Summary Update my profile
+// @Description Update the names or the email of the logged in user, a new email needs the current password and a new verification
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param ProfileUpdateRequestDto body dto.ProfileUpdateRequestDto true "Profile Update Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 409 {object} model.Status
+// @Failure 429 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me [patch]
+func (u *UserController) UpdateMeHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.ProfileUpdateRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	user, err := u.accountUc.UpdateProfile(ctx.Request.Context(), userID, payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Profile updated successfully", dto.ConvertUserToProfile(user))
+}
+
+// @Summary Change my password
+// @Description Change the password of the logged in user, every other session is logged out
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param ChangePasswordRequestDto body dto.ChangePasswordRequestDto true "Change Password Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 429 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me/password [post]
+func (u *UserController) ChangePasswordHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.ChangePasswordRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	token, err := u.accountUc.ChangePassword(ctx.Request.Context(), userID, middlewares.GetSessionID(ctx), payload, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// The token of this session was revoked along with the others
+	u.authMid.SetTokenCookie(ctx, token.Token)
+
+	common.SendSingleResponse(ctx, "Password changed successfully", token)
+}
+
+// @Summary Delete my account
+// @Description Anonymize and delete the account of the logged in user
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param DeleteAccountRequestDto body dto.DeleteAccountRequestDto true "Delete Account Payload"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 401 {object} model.Status
+// @Failure 429 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /profiles/me [delete]
+func (u *UserController) DeleteMeHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.DeleteAccountRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+
+	if err := u.accountUc.DeleteAccount(ctx.Request.Context(), userID, payload.Password, ctx.ClientIP()); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	u.authMid.ClearTokenCookie(ctx)
+
+	common.SendSuccessResponse(ctx, "Account deleted successfully")
 }
 
 func (u *UserController) Route() {
 	u.rg.GET(config.GetUsersList, u.authMid.RequireToken("admin"), u.GetAllHandler)
+	u.rg.GET(config.GetUsersExport, u.authMid.RequireToken("admin"), u.ExportHandler)
 	u.rg.GET(config.GetUsers, u.authMid.RequireToken("admin"), u.GetHandler)
+	u.rg.POST(config.PostUsersUnlock, u.authMid.RequireToken("admin"), u.UnlockHandler)
+	u.rg.PATCH(config.PatchUsers, u.authMid.RequireToken("admin"), u.UpdateHandler)
+	u.rg.POST(config.PostUsersDeactivate, u.authMid.RequireToken("admin"), u.DeactivateHandler)
+	u.rg.POST(config.PostUsersActivate, u.authMid.RequireToken("admin"), u.ActivateHandler)
+	u.rg.POST(config.PostUsersBulk, u.authMid.RequireToken("admin"), u.BulkHandler)
+
+	u.rg.GET(config.GetProfileMe, u.authMid.RequireToken("customer", "reseller", "admin"), u.GetMeHandler)
+	u.rg.PATCH(config.PatchProfileMe, u.authMid.RequireToken("customer", "reseller", "admin"), u.UpdateMeHandler)
+	u.rg.POST(config.PostProfileMePassword, u.authMid.RequireToken("customer", "reseller", "admin"), u.ChangePasswordHandler)
+	u.rg.DELETE(config.DeleteProfileMe, u.authMid.RequireToken("customer", "reseller", "admin"), u.DeleteMeHandler)
 }
 
-func NewUserController(userUc usecase.UserUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *UserController {
-	return &UserController{userUc: userUc, rg: rg, authMid: authMid}
+func NewUserController(userUc usecase.UserUseCase, attemptUc usecase.LoginAttemptUseCase, accountUc usecase.AccountUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *UserController {
+	return &UserController{userUc: userUc, attemptUc: attemptUc, accountUc: accountUc, rg: rg, authMid: authMid}
 }
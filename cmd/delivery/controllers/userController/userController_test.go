@@ -0,0 +1,81 @@
+package userController
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/delivery/validators"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testdb.New(t)
+	userRepo := repository.NewUserRepository(db)
+	if err := common.SetupValidator(validators.Rules(usecase.NewUserUseCase(userRepo))...); err != nil {
+		t.Fatalf("setup validator: %v", err)
+	}
+	for _, user := range []entity.User{
+		{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "hash", Role: "admin"},
+		{FirstName: "Alan", LastName: "Turing", Email: "alan@example.com", Password: "hash", Role: "customer"},
+		{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.org", Password: "hash", Role: "customer"},
+		{FirstName: "Edsger", LastName: "Dijkstra", Email: "edsger@example.com", Password: "hash", Role: "reseller"},
+	} {
+		if _, err := userRepo.Create(context.Background(), user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	engine := gin.New()
+	engine.Use(middlewares.ErrorHandler())
+	controller := NewUserController(usecase.NewUserUseCase(userRepo), nil, nil, engine.Group(config.ApiGroup), nil)
+	engine.GET(config.ApiGroup+config.GetUsersExport, controller.ExportHandler)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{name: "unfiltered", query: "", status: http.StatusOK, want: []string{"ada@example.com", "alan@example.com", "grace@example.org", "edsger@example.com"}},
+		{name: "role", query: "role=customer", status: http.StatusOK, want: []string{"alan@example.com", "grace@example.org"}},
+		{name: "search sorted", query: "q=example.com&sort=-email", status: http.StatusOK, want: []string{"edsger@example.com", "alan@example.com", "ada@example.com"}},
+		{name: "invalid role", query: "role=owner", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, config.ApiGroup+config.GetUsersExport+"?format=csv&"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+			if err != nil {
+				t.Fatalf("read csv: %v", err)
+			}
+			var emails []string
+			for _, record := range records[1:] {
+				emails = append(emails, record[3])
+			}
+			if !reflect.DeepEqual(emails, tt.want) {
+				t.Errorf("exported %v, want %v", emails, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package wishlistController
+
+import (
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+type WishlistController struct {
+	wishlistUc usecase.WishlistUseCase
+	rg         *gin.RouterGroup
+	authMid    middlewares.AuthMiddleware
+}
+
+func NewWishlistController(wishlistUc usecase.WishlistUseCase, rg *gin.RouterGroup, authMid middlewares.AuthMiddleware) *WishlistController {
+	return &WishlistController{wishlistUc: wishlistUc, rg: rg, authMid: authMid}
+}
+
+// @Summary Get wishlist
+// @Description Get the wishlist of the current user
+// @Tags wishlist
+// @Produce json
+// @Success 200 {object} model.SingleResponse
+// @Failure 401 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /wishlist [get]
+func (w *WishlistController) GetHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	items, err := w.wishlistUc.FindWishlist(ctx.Request.Context(), userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSingleResponse(ctx, "Ok", items)
+}
+
+// @Summary Add to wishlist
+// @Description Save a product to the wishlist of the current user, a notification is sent when it is back in stock
+// @Tags wishlist
+// @Accept json
+// @Produce json
+// @Param WishlistRequestDto body dto.WishlistRequestDto true "Wishlist Payload"
+// @Success 201 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /wishlist [post]
+func (w *WishlistController) AddHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	var payload dto.WishlistRequestDto
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.Error(common.BindError(ctx, err))
+		return
+	}
+	if payload.ProductID == 0 {
+		ctx.Error(apperror.Validation("Invalid product ID"))
+		return
+	}
+
+	item, err := w.wishlistUc.AddToWishlist(ctx.Request.Context(), userID, payload.ProductID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendCreateResponse(ctx, "Product added to wishlist", item)
+}
+
+// @Summary Remove from wishlist
+// @Description Remove a product from the wishlist of the current user
+// @Tags wishlist
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} model.SingleResponse
+// @Failure 400 {object} model.Status
+// @Failure 404 {object} model.Status
+// @Failure 500 {object} model.Status
+// @Router /wishlist/{id} [delete]
+func (w *WishlistController) RemoveHandler(ctx *gin.Context) {
+	userID, ok := middlewares.GetUserID(ctx)
+	if !ok {
+		ctx.Error(middlewares.ErrLoginRequired)
+		return
+	}
+
+	productID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.Error(apperror.Validation("Invalid product ID"))
+		return
+	}
+
+	if err := w.wishlistUc.RemoveFromWishlist(ctx.Request.Context(), userID, uint(productID)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	common.SendSuccessResponse(ctx, "Product removed from wishlist")
+}
+
+func (w *WishlistController) Route() {
+	w.rg.GET(config.GetWishlist, w.authMid.RequireToken("customer", "reseller", "admin"), w.GetHandler)
+	w.rg.POST(config.PostWishlist, w.authMid.RequireToken("customer", "reseller", "admin"), w.AddHandler)
+	w.rg.DELETE(config.DelWishlist, w.authMid.RequireToken("customer", "reseller", "admin"), w.RemoveHandler)
+}
@@ -1,22 +1,46 @@
 package middlewares
 
 import (
-	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"strings"
 
-	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/requestinfo"
 	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/altsaqif/go-rest/cmd/usecase"
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	// ErrLoginRequired is returned for requests to protected routes without a token
+	ErrLoginRequired = apperror.Unauthenticated("Please login first")
+
+	errInvalidToken      = apperror.Unauthenticated("Invalid or expired token")
+	errInvalidRole       = apperror.Forbidden("Invalid role")
+	errTwoFactorRequired = apperror.Forbidden("Two-factor authentication is required for your role, set it up first")
+)
+
 type AuthMiddleware interface {
+	// RequireToken accepts a token or, on the routes listed in scopedRoutes, an API key or OAuth access token
 	RequireToken(roles ...string) gin.HandlerFunc
+	// RequireTokenFor2FASetup skips the 2FA policy so users of a role that requires 2FA can enroll
+	RequireTokenFor2FASetup(roles ...string) gin.HandlerFunc
+	// SetTokenCookie stores the token in the HttpOnly token cookie and its CSRF token in the CSRF cookie,
+	// ClearTokenCookie removes both
+	SetTokenCookie(ctx *gin.Context, token string)
+	ClearTokenCookie(ctx *gin.Context)
 }
 
 type authMiddleware struct {
 	jwtService service.JwtService
+	userUc     usecase.UserUseCase
+	apiKeyUc   usecase.APIKeyUseCase
+	oauthUc    usecase.OAuthUseCase
+	sessionUc  usecase.SessionUseCase
+	// twoFactorRoles must have logged in with 2FA
+	twoFactorRoles []string
+	cookies        config.CookieConfig
 }
 
 type AuthHeader struct {
@@ -24,60 +48,183 @@ type AuthHeader struct {
 }
 
 func (a *authMiddleware) RequireToken(roles ...string) gin.HandlerFunc {
+	requireToken := a.requireToken(true, roles)
+	return func(ctx *gin.Context) {
+		if key := apiKeyFromRequest(ctx); key != "" {
+			a.requireAPIKey(ctx, key, roles)
+			return
+		}
+		requireToken(ctx)
+	}
+}
+
+func (a *authMiddleware) RequireTokenFor2FASetup(roles ...string) gin.HandlerFunc {
+	return a.requireToken(false, roles)
+}
+
+func (a *authMiddleware) requireToken(enforceTwoFactor bool, roles []string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var authHeader AuthHeader
 		if err := ctx.ShouldBindHeader(&authHeader); err != nil {
-			log.Printf("RequireToken: Error binding header: %v \n", err)
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: binding header", "err", err)
 		}
 
 		tokenHeader := strings.TrimPrefix(authHeader.AuthorizationHeader, "Bearer ")
+		fromCookie := tokenHeader == ""
 
-		if tokenHeader == "" {
-			// Log when checking the cookie
-			log.Println("RequireToken: Checking cookie for token")
-			cookie, err := ctx.Cookie("token")
-			fmt.Println("Token : ", cookie)
+		if fromCookie {
+			cookie, err := ctx.Cookie(TokenCookie)
 			if err != nil {
-				log.Println("RequireToken: Error retrieving token from cookie:", err)
-				common.SendErrorResponse(ctx, http.StatusUnauthorized, "Please login first")
+				slog.DebugContext(ctx.Request.Context(), "RequireToken: no token cookie", "err", err)
+				abortWithError(ctx, ErrLoginRequired)
 				return
 			}
 			tokenHeader = cookie
-			log.Printf("RequireToken: Retrieved token from cookie: %v\n", tokenHeader)
 		}
 
 		if tokenHeader == "" {
-			log.Println("RequireToken: Token is empty")
-			common.SendErrorResponse(ctx, http.StatusUnauthorized, "Please login first")
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: Token is empty")
+			abortWithError(ctx, ErrLoginRequired)
 			return
 		}
 
+		// Browsers send the cookie along with requests other sites make, but not the CSRF header
+		if fromCookie {
+			if err := a.checkCSRF(ctx, tokenHeader); err != nil {
+				slog.DebugContext(ctx.Request.Context(), "RequireToken: Invalid CSRF token")
+				abortWithError(ctx, err)
+				return
+			}
+		}
+
 		claims, err := a.jwtService.ParseToken(tokenHeader)
 		if err != nil {
-			log.Printf("RequireToken: Error parsing token: %v \n", err)
-			common.SendErrorResponse(ctx, http.StatusInternalServerError, "RequireToken: Error parsing token")
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: parsing token", "err", err)
+			abortWithError(ctx, errInvalidToken)
+			return
+		}
+
+		// Challenge tokens only prove the password, they are exchanged at the 2FA login
+		if _, ok := claims["purpose"]; ok {
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: Token is not an access token")
+			abortWithError(ctx, errInvalidToken)
+			return
+		}
+
+		// OAuth access tokens are limited to their scopes and die with their grant, the user passed the 2FA
+		// policy when approving the client, like the admin did when impersonating the user
+		_, isOAuth := claims["client_id"].(string)
+		if isOAuth {
+			grantID, _ := claims["jti"].(string)
+			if err := a.oauthUc.CheckAccessToken(ctx.Request.Context(), grantID); err != nil {
+				slog.DebugContext(ctx.Request.Context(), "RequireToken: rejected", "err", err)
+				abortWithError(ctx, err)
+				return
+			}
+			scope, _ := claims["scope"].(string)
+			if err := checkScope(ctx, strings.Fields(scope)); err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+		}
+
+		// Password changes, role changes, deactivation and deletion revoke the tokens issued before
+		userID, _ := claims["userId"].(float64)
+		version, _ := claims["ver"].(float64)
+		if err := a.userUc.CheckToken(ctx.Request.Context(), uint(userID), uint(version)); err != nil {
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: rejected", "err", err)
+			abortWithError(ctx, err)
 			return
 		}
 
+		// Tokens of a login are bound to its session, tokens issued before sessions were recorded have none
+		if sessionID, _ := claims["sid"].(string); sessionID != "" {
+			if err := a.sessionUc.CheckSession(ctx.Request.Context(), sessionID, uint(userID), ctx.ClientIP()); err != nil {
+				slog.DebugContext(ctx.Request.Context(), "RequireToken: rejected", "err", err)
+				abortWithError(ctx, err)
+				return
+			}
+			ctx.Set("session", sessionID)
+		}
+
 		ctx.Set("user", claims["userId"])
 
+		// Impersonation tokens die with the tokens of the admin as well, and can not reach the routes that would
+		// let the admin take over the account
+		act, impersonated := claims["act"].(map[string]interface{})
+		if impersonated {
+			if err := a.checkActor(ctx, act); err != nil {
+				slog.DebugContext(ctx.Request.Context(), "RequireToken: rejected", "err", err)
+				abortWithError(ctx, err)
+				return
+			}
+		}
+
 		role, ok := claims["role"]
 		if !ok {
-			log.Println("RequireToken: Missing role in token")
-			common.SendErrorResponse(ctx, http.StatusInternalServerError, "Missing role in token")
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: Missing role in token")
+			abortWithError(ctx, errInvalidToken)
 			return
 		}
 
 		if !isValidRole(role.(string), roles) {
-			log.Println("RequireToken: Invalid role")
-			common.SendErrorResponse(ctx, http.StatusForbidden, "Invalid role")
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: Invalid role")
+			abortWithError(ctx, errInvalidRole)
+			return
+		}
+
+		twoFactor, _ := claims["twoFactor"].(bool)
+		if enforceTwoFactor && !isOAuth && !impersonated && !twoFactor && isValidRole(role.(string), a.twoFactorRoles) {
+			slog.DebugContext(ctx.Request.Context(), "RequireToken: Two-factor authentication required")
+			abortWithError(ctx, errTwoFactorRequired)
 			return
 		}
+		ctx.Set("role", role)
+		setRequestUser(ctx)
 
 		ctx.Next()
 	}
 }
 
+// setRequestUser adds the user and the impersonating admin stored by RequireToken to the request info
+func setRequestUser(ctx *gin.Context) {
+	info := requestinfo.FromContext(ctx.Request.Context())
+	info.UserID, _ = GetUserID(ctx)
+	info.ImpersonatorID, _ = GetActorID(ctx)
+	ctx.Request = ctx.Request.WithContext(requestinfo.NewContext(ctx.Request.Context(), info))
+}
+
+// abortWithError stops the chain and leaves the response to ErrorHandler
+func abortWithError(ctx *gin.Context, err error) {
+	ctx.Error(err)
+	ctx.Abort()
+}
+
+// GetUserID returns the ID of the authenticated user stored by RequireToken
+func GetUserID(ctx *gin.Context) (uint, bool) {
+	value, exists := ctx.Get("user")
+	if !exists {
+		return 0, false
+	}
+
+	id, ok := value.(float64)
+	if !ok || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetSessionID returns the ID of the session of the token stored by RequireToken, it is empty for API keys,
+// OAuth access tokens and tokens issued before sessions were recorded
+func GetSessionID(ctx *gin.Context) string {
+	return ctx.GetString("session")
+}
+
+// GetUserRole returns the role of the authenticated user stored by RequireToken
+func GetUserRole(ctx *gin.Context) string {
+	return ctx.GetString("role")
+}
+
 func isValidRole(userRole string, validRoles []string) bool {
 	for _, role := range validRoles {
 		if userRole == role {
@@ -87,6 +234,6 @@ func isValidRole(userRole string, validRoles []string) bool {
 	return false
 }
 
-func NewAuthMiddleware(jwtService service.JwtService) AuthMiddleware {
-	return &authMiddleware{jwtService: jwtService}
+func NewAuthMiddleware(jwtService service.JwtService, userUc usecase.UserUseCase, apiKeyUc usecase.APIKeyUseCase, oauthUc usecase.OAuthUseCase, sessionUc usecase.SessionUseCase, cfg config.TwoFactorConfig, cookies config.CookieConfig) AuthMiddleware {
+	return &authMiddleware{jwtService: jwtService, userUc: userUc, apiKeyUc: apiKeyUc, oauthUc: oauthUc, sessionUc: sessionUc, twoFactorRoles: cfg.RequiredRoles, cookies: cookies}
 }
@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+// Names of the login cookies. The CSRF cookie is readable by scripts, the frontend repeats it in CSRFHeader on
+// every state changing request authenticated by the token cookie.
+const (
+	TokenCookie = "token"
+	CSRFCookie  = "csrf_token"
+	CSRFHeader  = "X-CSRF-Token"
+)
+
+var errInvalidCSRFToken = apperror.Forbidden("Missing or invalid CSRF token")
+
+// checkCSRF fails a state changing request unless CSRFHeader holds the CSRF token of the token cookie. Other sites
+// can make the browser send the cookie but can not read it, nor plant a CSRF token that matches it.
+func (a *authMiddleware) checkCSRF(ctx *gin.Context, token string) error {
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	header := ctx.GetHeader(CSRFHeader)
+	if header == "" || !hmac.Equal([]byte(header), []byte(a.csrfToken(token))) {
+		return errInvalidCSRFToken
+	}
+	return nil
+}
+
+// csrfToken derives the CSRF token from the login token, so it changes with every login
+func (a *authMiddleware) csrfToken(token string) string {
+	mac := hmac.New(sha256.New, a.cookies.CSRFKey)
+	mac.Write([]byte("csrf:" + token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *authMiddleware) SetTokenCookie(ctx *gin.Context, token string) {
+	maxAge := int(a.cookies.MaxAge.Seconds())
+	a.setCookie(ctx, TokenCookie, token, maxAge, true)
+	a.setCookie(ctx, CSRFCookie, a.csrfToken(token), maxAge, false)
+}
+
+func (a *authMiddleware) ClearTokenCookie(ctx *gin.Context) {
+	a.setCookie(ctx, TokenCookie, "", -1, true)
+	a.setCookie(ctx, CSRFCookie, "", -1, false)
+}
+
+func (a *authMiddleware) setCookie(ctx *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   a.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   a.cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: a.cookies.SameSite,
+	})
+}
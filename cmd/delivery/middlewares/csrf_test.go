@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireTokenCSRF(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.token(t, env.createUser(t, "customer@example.com", "customer"))
+	csrf := env.auth.(*authMiddleware).csrfToken(token)
+
+	engine := gin.New()
+	engine.Use(ErrorHandler())
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) }
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost, http.MethodDelete} {
+		engine.Handle(method, "/resource", env.auth.RequireToken("customer"), ok)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		bearer bool
+		cookie bool
+		header string
+		want   int
+	}{
+		{name: "bearer without csrf token", method: http.MethodPost, bearer: true, want: http.StatusNoContent},
+		{name: "bearer with foreign cookie", method: http.MethodDelete, bearer: true, cookie: true, want: http.StatusNoContent},
+		{name: "cookie without csrf header", method: http.MethodPost, cookie: true, want: http.StatusForbidden},
+		{name: "cookie with mismatched csrf header", method: http.MethodPost, cookie: true, header: "not-the-token", want: http.StatusForbidden},
+		{name: "cookie with csrf of another token", method: http.MethodDelete, cookie: true, header: env.auth.(*authMiddleware).csrfToken(token + "x"), want: http.StatusForbidden},
+		{name: "cookie with csrf header", method: http.MethodPost, cookie: true, header: csrf, want: http.StatusNoContent},
+		{name: "cookie on get", method: http.MethodGet, cookie: true, want: http.StatusNoContent},
+		{name: "cookie on head", method: http.MethodHead, cookie: true, want: http.StatusNoContent},
+		{name: "cookie on options", method: http.MethodOptions, cookie: true, want: http.StatusNoContent},
+		{name: "no token", method: http.MethodPost, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/resource", nil)
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: TokenCookie, Value: token})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+
+			if w := serve(engine, req); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetTokenCookie(t *testing.T) {
+	env := newTestEnv(t)
+	engine := gin.New()
+	engine.GET("/login", func(ctx *gin.Context) { env.auth.SetTokenCookie(ctx, "a.b.c") })
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range serve(engine, httptest.NewRequest(http.MethodGet, "/login", nil)).Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	if token := cookies[TokenCookie]; token == nil || !token.HttpOnly || token.Value != "a.b.c" {
+		t.Fatalf("token cookie = %+v", token)
+	}
+	if csrf := cookies[CSRFCookie]; csrf == nil || csrf.HttpOnly || csrf.Value != env.auth.(*authMiddleware).csrfToken("a.b.c") {
+		t.Fatalf("csrf cookie = %+v", csrf)
+	}
+}
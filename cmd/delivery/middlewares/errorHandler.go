@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is the non standard status logged when the client went away before the response
+const StatusClientClosedRequest = 499
+
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindNotFound:        http.StatusNotFound,
+	apperror.KindConflict:        http.StatusConflict,
+	apperror.KindValidation:      http.StatusBadRequest,
+	apperror.KindForbidden:       http.StatusForbidden,
+	apperror.KindUnauthenticated: http.StatusUnauthorized,
+	apperror.KindTooManyRequests: http.StatusTooManyRequests,
+}
+
+// ErrorHandler turns the last error a handler attached with ctx.Error into the error response. Domain errors
+// keep their message, anything else is logged and answered with a generic 500.
+func ErrorHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 {
+			return
+		}
+		err := ctx.Errors.Last().Err
+		if ctx.Writer.Written() {
+			slog.ErrorContext(ctx.Request.Context(), "ErrorHandler: response already sent", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "err", err)
+			return
+		}
+
+		if status, ok := kindStatus[apperror.KindOf(err)]; ok {
+			if retryAfter := apperror.RetryAfterOf(err); retryAfter > 0 {
+				ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+			common.SendFieldErrorResponse(ctx, status, err.Error(), apperror.FieldsOf(err))
+			return
+		}
+
+		// Anything else is internal, a query cut short by the request deadline answers 504
+		reqErr := ctx.Request.Context().Err()
+		slog.ErrorContext(ctx.Request.Context(), "ErrorHandler", "method", ctx.Request.Method, "path", ctx.Request.URL.Path, "err", err)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(reqErr, context.DeadlineExceeded):
+			common.SendErrorResponse(ctx, http.StatusGatewayTimeout, "The request took too long to complete")
+		case errors.Is(err, context.Canceled) || errors.Is(reqErr, context.Canceled):
+			common.SendErrorResponse(ctx, StatusClientClosedRequest, "The request was canceled")
+		default:
+			common.SendErrorResponse(ctx, http.StatusInternalServerError, "Internal server error")
+		}
+	}
+}
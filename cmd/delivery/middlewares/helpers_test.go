@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var testCookies = config.CookieConfig{SameSite: http.SameSiteLaxMode, MaxAge: time.Hour, CSRFKey: []byte("csrf-key")}
+
+// testEnv holds the dependencies of the auth middleware on a test database
+type testEnv struct {
+	db         *gorm.DB
+	jwtService service.JwtService
+	userUc     usecase.UserUseCase
+	auditUc    usecase.AuditUseCase
+	sessionUc  usecase.SessionUseCase
+	auth       AuthMiddleware
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	gin.SetMode(gin.TestMode)
+	db := testdb.New(t)
+	jwtService := service.NewJwtService(config.TokenConfig{
+		IssuerName:              "test",
+		JwtSignatureKey:         []byte("signature-key"),
+		JwtSigningMethod:        jwt.SigningMethodHS256,
+		JwtExpiresTime:          time.Hour,
+		JwtChallengeExpiresTime: time.Minute,
+	})
+	userUc := usecase.NewUserUseCase(repository.NewUserRepository(db))
+	auditUc := usecase.NewAuditUseCase(repository.NewAuditLogRepository(db))
+	sessionUc := usecase.NewSessionUseCase(repository.NewSessionRepository(db), auditUc, jwtService, config.SessionConfig{TTL: time.Hour, TouchInterval: time.Minute})
+	apiKeyUc := usecase.NewAPIKeyUseCase(repository.NewAPIKeyRepository(db), userUc, auditUc)
+	auth := NewAuthMiddleware(jwtService, userUc, apiKeyUc, nil, sessionUc, config.TwoFactorConfig{}, testCookies)
+	return &testEnv{db: db, jwtService: jwtService, userUc: userUc, auditUc: auditUc, sessionUc: sessionUc, auth: auth}
+}
+
+func (e *testEnv) createUser(t *testing.T, email, role string) dto.UserWithProducts {
+	t.Helper()
+	user, err := repository.NewUserRepository(e.db).Create(context.Background(), entity.User{FirstName: "Test", LastName: "User", Email: email, Password: "hash", Role: role})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func (e *testEnv) token(t *testing.T, user dto.UserWithProducts) string {
+	t.Helper()
+	token, err := e.jwtService.CreateToken(user, "", false)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	return token.Token
+}
+
+// serve runs req through engine and returns the recorded response
+func serve(engine *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHeader is set on every response to a request made with an impersonation token, it holds the ID
+// of the admin acting as the user
+const ImpersonationHeader = "X-Impersonated-By"
+
+var errImpersonationDenied = apperror.Forbidden("this action is not available while impersonating a user")
+
+// impersonationDeniedRoutes would let an admin acting as a user take over or lock out the account, or keep
+// access after the impersonation token expires
+var impersonationDeniedRoutes = map[string]bool{
+	http.MethodPatch + " " + config.PatchProfileMe:         true,
+	http.MethodDelete + " " + config.DeleteProfileMe:       true,
+	http.MethodPost + " " + config.PostProfileMePassword:   true,
+	http.MethodPost + " " + config.PostProfileMeDataExport: true,
+	http.MethodPost + " " + config.PostTwoFactorSetup:      true,
+	http.MethodPost + " " + config.PostTwoFactorVerify:     true,
+	http.MethodPost + " " + config.PostAPIKeys:             true,
+	http.MethodGet + " " + config.GetAPIKeys:               true,
+	http.MethodDelete + " " + config.DelAPIKeys:            true,
+	http.MethodPost + " " + config.PostOAuthClients:        true,
+	http.MethodDelete + " " + config.DelOAuthClients:       true,
+	http.MethodPost + " " + config.PostOAuthAuthorize:      true,
+	http.MethodPost + " " + config.PostImpersonate:         true,
+	http.MethodDelete + " " + config.DelSessions:           true,
+}
+
+// checkActor authenticates the admin in the act claim of an impersonation token: their own tokens must still be
+// valid and the route must not be denied to impersonation
+func (a *authMiddleware) checkActor(ctx *gin.Context, act map[string]interface{}) error {
+	subject, _ := act["sub"].(string)
+	actorID, err := strconv.ParseUint(subject, 10, 64)
+	if err != nil || actorID == 0 {
+		return errInvalidToken
+	}
+	version, _ := act["ver"].(float64)
+	if err := a.userUc.CheckToken(ctx.Request.Context(), uint(actorID), uint(version)); err != nil {
+		return err
+	}
+
+	// Stored like the user, GetActorID reads a float64
+	ctx.Set("actor", float64(actorID))
+	ctx.Header(ImpersonationHeader, subject)
+
+	if impersonationDeniedRoutes[ctx.Request.Method+" "+strings.TrimPrefix(ctx.FullPath(), config.ApiGroup)] {
+		return errImpersonationDenied
+	}
+	return nil
+}
+
+// GetActorID returns the ID of the admin impersonating the authenticated user, stored by RequireToken
+func GetActorID(ctx *gin.Context) (uint, bool) {
+	value, exists := ctx.Get("actor")
+	if !exists {
+		return 0, false
+	}
+
+	id, ok := value.(float64)
+	if !ok || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// AuditImpersonation writes every request made with an impersonation token to the audit log once it is answered,
+// denied ones included. It goes before ErrorHandler, which writes the status of failed requests.
+func AuditImpersonation(impersonationUc usecase.ImpersonationUseCase) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		actorID, ok := GetActorID(ctx)
+		if !ok {
+			return
+		}
+		userID, _ := GetUserID(ctx)
+		// The request context may have timed out, the entry is written regardless
+		reqCtx := context.WithoutCancel(ctx.Request.Context())
+		impersonationUc.RecordRequest(reqCtx, actorID, userID, ctx.Request.Method, ctx.Request.URL.Path, ctx.Writer.Status(), ctx.ClientIP())
+	}
+}
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/gin-gonic/gin"
+)
+
+// pathParam matches the parameters of a route, requests fill them in
+var pathParam = regexp.MustCompile(`:[A-Za-z]+`)
+
+func TestRequireTokenImpersonationDeniedRoutes(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser(t, "admin@example.com", "admin")
+	user := env.createUser(t, "customer@example.com", "customer")
+	impersonation, err := env.jwtService.CreateImpersonationToken(user, admin, time.Hour)
+	if err != nil {
+		t.Fatalf("create impersonation token: %v", err)
+	}
+	token := env.token(t, user)
+
+	engine := gin.New()
+	engine.Use(ErrorHandler())
+	rg := engine.Group(config.ApiGroup)
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) }
+	for route := range impersonationDeniedRoutes {
+		method, path, _ := strings.Cut(route, " ")
+		rg.Handle(method, path, env.auth.RequireToken("customer", "reseller", "admin"), ok)
+	}
+	rg.GET(config.GetProfileMe, env.auth.RequireToken("customer"), ok)
+
+	request := func(route, token string) *httptest.ResponseRecorder {
+		method, path, _ := strings.Cut(route, " ")
+		req := httptest.NewRequest(method, config.ApiGroup+pathParam.ReplaceAllString(path, "1"), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serve(engine, req)
+	}
+
+	for route := range impersonationDeniedRoutes {
+		t.Run(route, func(t *testing.T) {
+			w := request(route, impersonation)
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), errImpersonationDenied.Message) {
+				t.Errorf("impersonated: status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+			}
+			if w.Header().Get(ImpersonationHeader) == "" {
+				t.Errorf("impersonated: missing %s header", ImpersonationHeader)
+			}
+			if w := request(route, token); w.Code != http.StatusNoContent {
+				t.Errorf("own token: status = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("allowed route", func(t *testing.T) {
+		if w := request(http.MethodGet+" "+config.GetProfileMe, impersonation); w.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+		}
+	})
+}
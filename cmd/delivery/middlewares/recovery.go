@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery answers a request whose handler panicked with a 500 and logs the panic with its stack
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		logger.ErrorContext(ctx.Request.Context(), "panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+		if !ctx.Writer.Written() {
+			common.SendErrorResponse(ctx, http.StatusInternalServerError, "Internal server error")
+		}
+		ctx.Abort()
+	})
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/shared/requestinfo"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestIDLength keeps a client supplied ID from bloating the logs
+const maxRequestIDLength = 128
+
+// RequestID reuses the X-Request-ID sent by the client or generates one, and echoes it on the response
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(common.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		common.SetRequestID(ctx, id)
+		ctx.Header(common.RequestIDHeader, id)
+		// Usecases only get the request context, the audit log finds the request ID and IP there
+		info := requestinfo.Info{RequestID: id, IP: ctx.ClientIP()}
+		ctx.Request = ctx.Request.WithContext(requestinfo.NewContext(ctx.Request.Context(), info))
+		ctx.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
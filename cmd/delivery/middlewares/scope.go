@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/gin-gonic/gin"
+)
+
+var errScopedAccessNotAccepted = apperror.Forbidden("API keys and OAuth tokens can not be used for this endpoint")
+
+// scopedRoutes maps the routes API keys and OAuth tokens may call to the scope they need, every other route
+// refuses them
+var scopedRoutes = map[string]string{
+	http.MethodGet + " " + config.GetProductsList:     entity.ScopeProductsRead,
+	http.MethodGet + " " + config.GetProducts:         entity.ScopeProductsRead,
+	http.MethodGet + " " + config.GetProductsByStocks: entity.ScopeProductsRead,
+	http.MethodGet + " " + config.GetProductsExport:   entity.ScopeProductsRead,
+	http.MethodPost + " " + config.PostProducts:       entity.ScopeProductsWrite,
+	http.MethodPut + " " + config.PutProducts:         entity.ScopeProductsWrite,
+	http.MethodDelete + " " + config.DelProducts:      entity.ScopeProductsWrite,
+	http.MethodPost + " " + config.PostProductsImport: entity.ScopeImportsWrite,
+	http.MethodGet + " " + config.GetImports:          entity.ScopeImportsRead,
+	http.MethodGet + " " + config.GetProductReviews:   entity.ScopeReviewsRead,
+	http.MethodGet + " " + config.GetCouponsList:      entity.ScopeCouponsRead,
+	http.MethodGet + " " + config.GetCoupons:          entity.ScopeCouponsRead,
+	http.MethodPost + " " + config.PostCouponsCheck:   entity.ScopeCouponsRead,
+	http.MethodPost + " " + config.PostCoupons:        entity.ScopeCouponsWrite,
+	http.MethodPut + " " + config.PutCoupons:          entity.ScopeCouponsWrite,
+	http.MethodDelete + " " + config.DelCoupons:       entity.ScopeCouponsWrite,
+	http.MethodGet + " " + config.GetRatesList:        entity.ScopeRatesRead,
+	http.MethodPut + " " + config.PutRates:            entity.ScopeRatesWrite,
+	http.MethodGet + " " + config.GetUsersList:        entity.ScopeUsersRead,
+	http.MethodGet + " " + config.GetUsers:            entity.ScopeUsersRead,
+	http.MethodGet + " " + config.GetUsersExport:      entity.ScopeUsersRead,
+	http.MethodGet + " " + config.GetProfileMe:        entity.ScopeProfileRead,
+}
+
+// checkScope fails unless the route accepts scoped access and granted holds the scope it needs
+func checkScope(ctx *gin.Context, granted []string) error {
+	scope, ok := scopedRoutes[ctx.Request.Method+" "+strings.TrimPrefix(ctx.FullPath(), config.ApiGroup)]
+	if !ok {
+		return errScopedAccessNotAccepted
+	}
+	if !isValidRole(scope, granted) {
+		return apperror.Forbidden("the " + scope + " scope is required for this endpoint")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout puts a deadline on the request context, every query made with that context is cancelled once it
+// passes and the handler answers 504. Routes listed in RouteTimeouts use their own deadline.
+func Timeout(cfg config.TimeoutConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		timeout := cfg.QueryTimeout
+		if routeTimeout, ok := cfg.RouteTimeouts[ctx.Request.Method+" "+ctx.FullPath()]; ok {
+			timeout = routeTimeout
+		}
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
@@ -2,15 +2,35 @@ package delivery
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/apiKeyController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/auditLogController"
 	"github.com/altsaqif/go-rest/cmd/delivery/controllers/authController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/couponController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/enrollmentController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/exchangeRateController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/impersonationController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/importController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/oauthController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/oidcController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/privacyController"
 	"github.com/altsaqif/go-rest/cmd/delivery/controllers/productController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/reviewController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/sessionController"
 	"github.com/altsaqif/go-rest/cmd/delivery/controllers/userController"
+	"github.com/altsaqif/go-rest/cmd/delivery/controllers/wishlistController"
 	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
-	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/delivery/validators"
+	"github.com/altsaqif/go-rest/cmd/migration"
 	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/shared/logger"
+	"github.com/altsaqif/go-rest/cmd/shared/mail"
 	"github.com/altsaqif/go-rest/cmd/shared/service"
 	"github.com/altsaqif/go-rest/cmd/usecase"
 	_ "github.com/altsaqif/go-rest/docs"
@@ -19,27 +39,60 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type Server struct {
-	productUc  usecase.ProductUseCase
-	userUc     usecase.UserUseCase
-	authUc     usecase.AuthUseCase
-	jwtService service.JwtService
-	engine     *gin.Engine
-	host       string
+	productUc       usecase.ProductUseCase
+	userUc          usecase.UserUseCase
+	authUc          usecase.AuthUseCase
+	rateUc          usecase.ExchangeRateUseCase
+	couponUc        usecase.CouponUseCase
+	enrollmentUc    usecase.EnrollmentUseCase
+	reviewUc        usecase.ReviewUseCase
+	wishlistUc      usecase.WishlistUseCase
+	importUc        usecase.ImportUseCase
+	attemptUc       usecase.LoginAttemptUseCase
+	accountUc       usecase.AccountUseCase
+	privacyUc       usecase.PrivacyUseCase
+	apiKeyUc        usecase.APIKeyUseCase
+	oauthUc         usecase.OAuthUseCase
+	oidcUc          usecase.OIDCUseCase
+	sessionUc       usecase.SessionUseCase
+	impersonationUc usecase.ImpersonationUseCase
+	auditUc         usecase.AuditUseCase
+	jwtService      service.JwtService
+	twoFactorCfg    config.TwoFactorConfig
+	cookieCfg       config.CookieConfig
+	logger          *slog.Logger
+	engine          *gin.Engine
+	host            string
 }
 
 func (s *Server) initRoute() {
 	rg := s.engine.Group(config.ApiGroup)
-	authMid := middlewares.NewAuthMiddleware(s.jwtService)
-	authController.NewAuthController(s.authUc, rg).Route()
-	userController.NewUserController(s.userUc, rg, authMid).Route()
+	authMid := middlewares.NewAuthMiddleware(s.jwtService, s.userUc, s.apiKeyUc, s.oauthUc, s.sessionUc, s.twoFactorCfg, s.cookieCfg)
+	authController.NewAuthController(s.authUc, s.accountUc, rg, authMid).Route()
+	userController.NewUserController(s.userUc, s.attemptUc, s.accountUc, rg, authMid).Route()
 	productController.NewProductController(s.productUc, rg, authMid).Route()
+	exchangeRateController.NewExchangeRateController(s.rateUc, rg, authMid).Route()
+	couponController.NewCouponController(s.couponUc, rg, authMid).Route()
+	enrollmentController.NewEnrollmentController(s.enrollmentUc, rg, authMid).Route()
+	reviewController.NewReviewController(s.reviewUc, rg, authMid).Route()
+	wishlistController.NewWishlistController(s.wishlistUc, rg, authMid).Route()
+	importController.NewImportController(s.importUc, rg, authMid).Route()
+	privacyController.NewPrivacyController(s.privacyUc, rg, authMid).Route()
+	apiKeyController.NewAPIKeyController(s.apiKeyUc, rg, authMid).Route()
+	oauthController.NewOAuthController(s.oauthUc, rg, authMid).Route()
+	oidcController.NewOIDCController(s.oidcUc, rg, authMid, s.cookieCfg).Route()
+	sessionController.NewSessionController(s.sessionUc, rg, authMid).Route()
+	impersonationController.NewImpersonationController(s.impersonationUc, rg, authMid).Route()
+	auditLogController.NewAuditLogController(s.auditUc, rg, authMid).Route()
 }
 
 func (s *Server) Run() {
 	s.initRoute()
+	s.logger.Info("Listening", "host", s.host)
 	if err := s.engine.Run(s.host); err != nil {
 		panic(fmt.Errorf("server not running on host %s, because error %v", s.host, err.Error()))
 	}
@@ -48,47 +101,136 @@ func (s *Server) Run() {
 func NewServer() *Server {
 	cfg, err := config.NewConfig()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
+	// The logger is the default, so packages log through it with slog.InfoContext and friends
+	appLogger := logger.New(os.Stdout, cfg.LogConfig)
+	slog.SetDefault(appLogger)
+
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger: gormlogger.New(slog.NewLogLogger(appLogger.Handler(), slog.LevelWarn), gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
 		panic("connection error")
 	}
 
-	// Drop existing tables
-	db.Migrator().DropTable(&entity.User{}, &entity.Product{}, &entity.Enrollment{})
-
-	err = db.AutoMigrate(&entity.User{}, &entity.Product{}, &entity.Enrollment{})
+	if err := migration.Run(db, cfg); err != nil {
+		fatal("Failed to migrate", err)
+	}
 
+	notifier, err := service.NewNotifier(cfg.NotificationConfig)
 	if err != nil {
-		log.Fatalf("failed to run migrations: %v", err)
+		fatal("Failed to create notifier", err)
 	}
+	notificationService := service.NewNotificationService(notifier, cfg.NotificationConfig)
 
-	db.SetupJoinTable(&entity.User{}, "Products", &entity.Enrollment{})
+	mailSender, err := mail.NewSender(cfg.MailConfig)
+	if err != nil {
+		fatal("Failed to create mail sender", err)
+	}
+	mailer := mail.NewMailer(mailSender, cfg.MailConfig)
 
 	jwtService := service.NewJwtService(cfg.TokenConfig)
-	productRepo := repository.NewProductRepository(db)
+	passwordService := service.NewPasswordService(cfg.PasswordConfig)
+	productRepo := repository.NewProductRepository(db, notificationService)
 	userRepo := repository.NewUserRepository(db)
-
-	productUc := usecase.NewProductUseCase(productRepo)
+	rateRepo := repository.NewExchangeRateRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	enrollmentRepo := repository.NewEnrollmentRepository(db)
+	reviewRepo := repository.NewReviewRepository(db)
+	wishlistRepo := repository.NewWishlistRepository(db)
+	importJobRepo := repository.NewImportJobRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	dataExportRepo := repository.NewDataExportRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	userIdentityRepo := repository.NewUserIdentityRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+
+	rateUc := usecase.NewExchangeRateUseCase(rateRepo)
+	auditUc := usecase.NewAuditUseCase(auditLogRepo)
+	productUc := usecase.NewProductUseCase(productRepo, rateUc, auditUc, cfg.DefaultCurrency)
 	userUc := usecase.NewUserUseCase(userRepo)
-	authUc := usecase.NewAuthUseCase(userUc, jwtService)
+	couponUc := usecase.NewCouponUseCase(couponRepo, productRepo)
+	enrollmentUc := usecase.NewEnrollmentUseCase(enrollmentRepo, couponUc)
+	reviewUc := usecase.NewReviewUseCase(reviewRepo)
+	wishlistUc := usecase.NewWishlistUseCase(wishlistRepo)
+	importUc := usecase.NewImportUseCase(importJobRepo, productRepo, auditUc, cfg.ImportConfig, cfg.DefaultCurrency)
+	attemptUc := usecase.NewLoginAttemptUseCase(loginAttemptRepo, userUc, auditUc, cfg.LoginConfig)
+	twoFactorUc := usecase.NewTwoFactorUseCase(userUc, recoveryCodeRepo, cfg.TwoFactorConfig)
+	sessionUc := usecase.NewSessionUseCase(sessionRepo, auditUc, jwtService, cfg.SessionConfig)
+	privacyUc := usecase.NewPrivacyUseCase(userUc, auditUc, dataExportRepo, jwtService, cfg.PrivacyConfig)
+	accountUc := usecase.NewAccountUseCase(userUc, attemptUc, auditUc, privacyUc, sessionUc, userTokenRepo, jwtService, passwordService, mailer, cfg.AccountConfig)
+	apiKeyUc := usecase.NewAPIKeyUseCase(apiKeyRepo, userUc, auditUc)
+	oauthUc := usecase.NewOAuthUseCase(oauthRepo, userUc, auditUc, jwtService, cfg.OAuthConfig)
+	oidcUc := usecase.NewOIDCUseCase(userIdentityRepo, userUc, auditUc, sessionUc, jwtService, &http.Client{Timeout: 10 * time.Second}, cfg.OIDCConfig)
+	impersonationUc := usecase.NewImpersonationUseCase(userUc, auditUc, jwtService, cfg.ImpersonationConfig)
+	authUc := usecase.NewAuthUseCase(userUc, attemptUc, twoFactorUc, accountUc, sessionUc, auditUc, jwtService, passwordService)
+
+	if err := common.SetupValidator(validators.Rules(userUc)...); err != nil {
+		fatal("Failed to set up request validation", err)
+	}
 
-	engine := gin.Default()
+	engine, err := newEngine(cfg.TrustedProxies)
+	if err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
+	engine.Use(middlewares.RequestID(), middlewares.RequestLogger(appLogger), middlewares.Recovery(appLogger), middlewares.AuditImpersonation(impersonationUc), middlewares.ErrorHandler(), middlewares.Timeout(cfg.TimeoutConfig))
 	host := fmt.Sprintf(":%s", cfg.ApiPort)
 
 	// Swagger handler
 	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return &Server{
-		productUc:  productUc,
-		userUc:     userUc,
-		authUc:     authUc,
-		jwtService: jwtService,
-		engine:     engine,
-		host:       host,
+		productUc:       productUc,
+		userUc:          userUc,
+		authUc:          authUc,
+		rateUc:          rateUc,
+		couponUc:        couponUc,
+		enrollmentUc:    enrollmentUc,
+		reviewUc:        reviewUc,
+		wishlistUc:      wishlistUc,
+		importUc:        importUc,
+		attemptUc:       attemptUc,
+		accountUc:       accountUc,
+		privacyUc:       privacyUc,
+		apiKeyUc:        apiKeyUc,
+		oauthUc:         oauthUc,
+		oidcUc:          oidcUc,
+		sessionUc:       sessionUc,
+		impersonationUc: impersonationUc,
+		auditUc:         auditUc,
+		jwtService:      jwtService,
+		twoFactorCfg:    cfg.TwoFactorConfig,
+		cookieCfg:       cfg.CookieConfig,
+		logger:          appLogger,
+		engine:          engine,
+		host:            host,
 	}
 }
+
+// newEngine returns an engine reading the client IP from forwarding headers sent by trustedProxies only
+func newEngine(trustedProxies []string) (*gin.Engine, error) {
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// fatal logs err and stops the process, the server can not start without what failed
+func fatal(message string, err error) {
+	slog.Error(message, "err", err)
+	os.Exit(1)
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/delivery/middlewares"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewEngineTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testdb.New(t)
+	jwtService := service.NewJwtService(config.TokenConfig{IssuerName: "test", JwtSignatureKey: []byte("signature-key"), JwtSigningMethod: jwt.SigningMethodHS256, JwtExpiresTime: time.Hour})
+	userUc := usecase.NewUserUseCase(repository.NewUserRepository(db))
+	auditUc := usecase.NewAuditUseCase(repository.NewAuditLogRepository(db))
+	sessionUc := usecase.NewSessionUseCase(repository.NewSessionRepository(db), auditUc, jwtService, config.SessionConfig{TTL: time.Hour, TouchInterval: time.Minute})
+	apiKeyUc := usecase.NewAPIKeyUseCase(repository.NewAPIKeyRepository(db), userUc, auditUc)
+	auth := middlewares.NewAuthMiddleware(jwtService, userUc, apiKeyUc, nil, sessionUc, config.TwoFactorConfig{}, config.CookieConfig{})
+
+	user, err := repository.NewUserRepository(db).Create(context.Background(), entity.User{FirstName: "Test", LastName: "User", Email: "key@example.com", Password: "hash", Role: "customer"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	key, err := apiKeyUc.CreateKey(context.Background(), user.ID, dto.APIKeyCreateRequestDto{
+		Name:       "office",
+		Scopes:     []string{entity.ScopeProductsRead},
+		AllowedIPs: []string{"10.0.0.1"},
+	}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		headers        map[string]string
+		status         int
+	}{
+		{name: "allowed IP", remoteAddr: "10.0.0.1:4000", status: http.StatusOK},
+		{name: "other IP", remoteAddr: "192.0.2.10:4000", status: http.StatusForbidden},
+		{name: "spoofed X-Forwarded-For", remoteAddr: "192.0.2.10:4000", headers: map[string]string{"X-Forwarded-For": "10.0.0.1"}, status: http.StatusForbidden},
+		{name: "spoofed X-Real-IP", remoteAddr: "192.0.2.10:4000", headers: map[string]string{"X-Real-IP": "10.0.0.1"}, status: http.StatusForbidden},
+		{
+			name:           "X-Forwarded-For of a trusted proxy",
+			trustedProxies: []string{"192.0.2.0/24"},
+			remoteAddr:     "192.0.2.10:4000",
+			headers:        map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			status:         http.StatusOK,
+		},
+		{
+			name:           "X-Forwarded-For through an untrusted proxy",
+			trustedProxies: []string{"192.0.2.0/24"},
+			remoteAddr:     "198.51.100.7:4000",
+			headers:        map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			status:         http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := newEngine(tt.trustedProxies)
+			if err != nil {
+				t.Fatalf("newEngine: %v", err)
+			}
+			engine.Use(middlewares.ErrorHandler())
+			engine.GET(config.ApiGroup+config.GetProductsList, auth.RequireToken("customer"), func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, config.ApiGroup+config.GetProductsList, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-API-Key", key.Key)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewEngineRejectsInvalidProxy(t *testing.T) {
+	if _, err := newEngine([]string{"not-an-ip"}); err == nil {
+		t.Error("newEngine accepted an invalid trusted proxy")
+	}
+}
@@ -0,0 +1,86 @@
+package validators
+
+import (
+	"context"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/common"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"github.com/altsaqif/go-rest/cmd/usecase"
+	"github.com/go-playground/validator/v10"
+)
+
+// Rules returns the custom binding rules of the API
+func Rules(userUc usecase.UserUseCase) []common.ValidationRule {
+	return []common.ValidationRule{
+		{
+			Tag:  "unique_email",
+			Func: uniqueEmail(userUc),
+			Messages: map[string]string{
+				"en": "{0} is already registered",
+				"id": "{0} sudah terdaftar",
+			},
+		},
+		{
+			Tag:  "sort",
+			Func: sortFields,
+			Messages: map[string]string{
+				"en": "{0} can only sort by the documented fields",
+				"id": "{0} hanya dapat mengurutkan berdasarkan kolom yang didokumentasikan",
+			},
+		},
+		{
+			Tag:  "scope",
+			Func: knownScope,
+			Messages: map[string]string{
+				"en": "{0} must be one of the documented scopes",
+				"id": "{0} harus salah satu cakupan yang didokumentasikan",
+			},
+		},
+		{
+			Tag:  "price",
+			Func: nonNegativeMoney,
+			Messages: map[string]string{
+				"en": "{0} must not be negative",
+				"id": "{0} tidak boleh negatif",
+			},
+		},
+	}
+}
+
+// uniqueEmail rejects emails that already belong to a user. Any lookup failure passes, the unique
+// index still stops a duplicate when the user is saved.
+func uniqueEmail(userUc usecase.UserUseCase) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		_, err := userUc.FindUserByEmail(context.Background(), fl.Field().String())
+		return err != nil
+	}
+}
+
+// sortFields accepts a sort list like "-created_at,email" over the space separated fields of the rule parameter
+func sortFields(fl validator.FieldLevel) bool {
+	_, err := query.ParseSort(fl.Field().String(), strings.Fields(fl.Param())...)
+	return err == nil
+}
+
+func knownScope(fl validator.FieldLevel) bool {
+	for _, scope := range entity.Scopes {
+		if fl.Field().String() == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func nonNegativeMoney(fl validator.FieldLevel) bool {
+	switch value := fl.Field().Interface().(type) {
+	case money.Money:
+		return value.Amount >= 0
+	case *money.Money:
+		return value == nil || value.Amount >= 0
+	default:
+		return false
+	}
+}
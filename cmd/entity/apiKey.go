@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// APIKey lets a machine act as its owner within its scopes. Only the SHA-256 of the secret is stored, the
+// public prefix finds the key. Scopes and AllowedIPs are comma separated, no AllowedIPs allows every IP.
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null;uniqueIndex" json:"prefix"`
+	SecretHash string     `gorm:"type:char(64);not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(500);not null" json:"-"`
+	AllowedIPs string     `gorm:"type:varchar(1000)" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"type:varchar(45)" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditLoginLockout         = "auth.lockout"
+	AuditLoginUnlock          = "auth.unlock"
+	AuditPasswordReset        = "auth.password_reset"
+	AuditPasswordChange       = "user.password_change"
+	AuditAccountDelete        = "user.delete"
+	AuditUserUpdate           = "user.update"
+	AuditUserDeactivate       = "user.deactivate"
+	AuditUserActivate         = "user.activate"
+	AuditUserBulkUpdate       = "user.bulk_update"
+	AuditUserErase            = "user.erase"
+	AuditDataExport           = "user.data_export"
+	AuditAPIKeyCreate         = "api_key.create"
+	AuditAPIKeyRevoke         = "api_key.revoke"
+	AuditOAuthClientCreate    = "oauth_client.create"
+	AuditOAuthClientRevoke    = "oauth_client.revoke"
+	AuditOAuthConsent         = "oauth.consent"
+	AuditOIDCLink             = "auth.oidc_link"
+	AuditOIDCProvision        = "auth.oidc_provision"
+	AuditSessionRevoke        = "session.revoke"
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditProductCreate        = "product.create"
+	AuditProductUpdate        = "product.update"
+	AuditProductDelete        = "product.delete"
+	AuditUserRegister         = "user.register"
+	AuditLogin                = "auth.login"
+	AuditTwoFactorEnable      = "auth.2fa_enable"
+)
+
+// AuditErasedPrefix starts the target ID of entries that named the email of an erased user
+const AuditErasedPrefix = "erased:"
+
+// AuditLog records a security relevant event or a write, ActorID is empty for events without a logged in user.
+// Changes holds the fields a write changed as {"field": {"before": .., "after": ..}}.
+//
+// Entries are only ever inserted, the hooks refuse updates and deletes, and each one is sealed with the hash of
+// the entry before it so removing or editing one breaks the chain. The chain covers TargetHash rather than
+// TargetID, so erasing an email from TargetID keeps it intact as long as the erasure record lists the email.
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	ActorID    *uint     `gorm:"index" json:"actor_id"`
+	Action     string    `gorm:"type:varchar(64);not null;index" json:"action"`
+	TargetType string    `gorm:"type:varchar(64)" json:"target_type"`
+	TargetID   string    `gorm:"type:varchar(320)" json:"target_id"`
+	IP         string    `gorm:"type:varchar(45)" json:"ip"`
+	Details    string    `gorm:"type:text" json:"details"`
+	RequestID  string    `gorm:"type:varchar(128);index" json:"request_id"`
+	Changes    string    `gorm:"type:text" json:"changes,omitempty"`
+	TargetHash string    `gorm:"type:char(64)" json:"-"`
+	PrevHash   string    `gorm:"type:char(64);uniqueIndex" json:"prev_hash"`
+	Hash       string    `gorm:"type:char(64);uniqueIndex" json:"hash"`
+}
+
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrImmutable
+}
+
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrImmutable
+}
+
+// Seal links the entry to the entry with prevHash, CreatedAt must be set to what the database stores
+func (a *AuditLog) Seal(prevHash string) {
+	a.TargetHash = sha256Hex(a.TargetID)
+	a.PrevHash = prevHash
+	a.Hash = a.computeHash()
+}
+
+// Intact reports whether the entry follows the entry with prevHash and is unchanged since it was sealed. An
+// erased target is only intact when one of the erasures of its subject, erasures are keyed by subject, erased
+// the target the entry was sealed with.
+func (a AuditLog) Intact(prevHash string, erasures map[string][]ErasureRecord) bool {
+	if a.PrevHash != prevHash || a.Hash != a.computeHash() {
+		return false
+	}
+
+	subject, erased := strings.CutPrefix(a.TargetID, AuditErasedPrefix)
+	if !erased {
+		return a.TargetHash == sha256Hex(a.TargetID)
+	}
+	for _, record := range erasures[subject] {
+		if record.Erased(a.TargetHash) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AuditLog) computeHash() string {
+	sealed, _ := json.Marshal(struct {
+		PrevHash   string `json:"prev_hash"`
+		CreatedAt  int64  `json:"created_at"`
+		ActorID    *uint  `json:"actor_id"`
+		Action     string `json:"action"`
+		TargetType string `json:"target_type"`
+		TargetHash string `json:"target_hash"`
+		IP         string `json:"ip"`
+		Details    string `json:"details"`
+		RequestID  string `json:"request_id"`
+		Changes    string `json:"changes"`
+	}{a.PrevHash, a.CreatedAt.UnixMilli(), a.ActorID, a.Action, a.TargetType, a.TargetHash, a.IP, a.Details, a.RequestID, a.Changes})
+	return sha256Hex(string(sealed))
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"gorm.io/gorm"
+)
+
+const (
+	CouponTypePercentage = "percentage"
+	CouponTypeFixed      = "fixed"
+)
+
+type Coupon struct {
+	gorm.Model
+	Code         string      `gorm:"type:varchar(64);not null;uniqueIndex" json:"code"`
+	Type         string      `gorm:"type:varchar(20);not null" json:"type"`
+	PercentOff   int         `gorm:"not null;default:0" json:"percent_off"`
+	AmountOff    money.Money `gorm:"embedded;embeddedPrefix:amount_off_" json:"amount_off" swaggertype:"object,string"`
+	MinOrder     money.Money `gorm:"embedded;embeddedPrefix:min_order_" json:"min_order" swaggertype:"object,string"`
+	UsageLimit   int         `gorm:"not null;default:0" json:"usage_limit"`
+	PerUserLimit int         `gorm:"not null;default:0" json:"per_user_limit"`
+	UsedCount    int         `gorm:"not null;default:0" json:"used_count"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	Category     string      `gorm:"type:varchar(100)" json:"category"`
+	Products     []Product   `gorm:"many2many:coupon_products;" json:"products"`
+}
+
+type CouponRedemption struct {
+	gorm.Model
+	CouponID  uint        `gorm:"not null;index" json:"coupon_id"`
+	UserID    uint        `gorm:"not null;index" json:"user_id"`
+	ProductID uint        `gorm:"not null" json:"product_id"`
+	Discount  money.Money `gorm:"embedded;embeddedPrefix:discount_" json:"discount" swaggertype:"object,string"`
+}
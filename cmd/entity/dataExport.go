@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	DataExportStatusPending   = "pending"
+	DataExportStatusCompleted = "completed"
+	DataExportStatusFailed    = "failed"
+)
+
+// DataExport is a ZIP archive of the personal data of a user, the file is named after the ID
+type DataExport struct {
+	ID         string     `gorm:"type:char(32);primaryKey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	Status     string     `gorm:"type:varchar(20);not null" json:"status"`
+	Size       int64      `gorm:"not null;default:0" json:"size"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+}
@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+type APIKeyCreateRequestDto struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,scope"`
+	// AllowedIPs are IPs or CIDR ranges, an empty list allows every IP
+	AllowedIPs []string   `json:"allowed_ips" binding:"max=20,dive,ip|cidr"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type APIKeyResponseDto struct {
+	ID         uint       `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// APIKeyCreatedResponseDto carries the full key, it is shown only once
+type APIKeyCreatedResponseDto struct {
+	APIKeyResponseDto
+	Key string `json:"key"`
+}
+
+func ConvertAPIKeyToResponse(key entity.APIKey) APIKeyResponseDto {
+	return APIKeyResponseDto{
+		ID:         key.ID,
+		CreatedAt:  key.CreatedAt,
+		UserID:     key.UserID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     splitList(key.Scopes),
+		AllowedIPs: splitList(key.AllowedIPs),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+// splitList reads a comma separated column, an empty column is an empty list
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
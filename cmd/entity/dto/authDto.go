@@ -2,28 +2,68 @@ package dto
 
 import (
 	"database/sql"
+	"math"
 	"time"
 
 	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
 	"gorm.io/gorm"
 )
 
 type AuthRequestRegisterDto struct {
-	FirstName       string `gorm:"type:varchar(300);not null" json:"firstname"`
-	LastName        string `gorm:"type:varchar(300);not null" json:"lastname"`
-	Email           string `gorm:"not null;unique" json:"email"`
-	Password        string `gorm:"not null" json:"password"`
-	PasswordConfirm string `gorm:"not null" json:"password_confirm"`
-	Role            string `gorm:"not null" json:"role"`
+	FirstName       string `gorm:"type:varchar(300);not null" json:"firstname" binding:"required,max=300"`
+	LastName        string `gorm:"type:varchar(300);not null" json:"lastname" binding:"required,max=300"`
+	Email           string `gorm:"not null;unique" json:"email" binding:"required,email,unique_email"`
+	Password        string `gorm:"not null" json:"password" binding:"required,max=128"`
+	PasswordConfirm string `gorm:"not null" json:"password_confirm" binding:"required,eqfield=Password"`
+	Role            string `gorm:"not null" json:"role" binding:"required,oneof=customer reseller admin"`
 }
 
 type AuthRequestLoginDto struct {
-	Email    string `gorm:"not null;unique" json:"email"`
-	Password string `gorm:"not null" json:"password"`
+	Email    string `gorm:"not null;unique" json:"email" binding:"required,email"`
+	Password string `gorm:"not null" json:"password" binding:"required"`
 }
 
+// AuthResponseDto carries either the token or, when 2FA is enabled, the challenge for the second step
 type AuthResponseDto struct {
-	Token string `json:"token"`
+	Token             string `json:"token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+type TwoFactorSetupResponseDto struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          string `json:"qr_code"`
+}
+
+type TwoFactorVerifyRequestDto struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type TwoFactorVerifyResponseDto struct {
+	Token         string   `json:"token"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorLoginRequestDto completes a login, Code is a TOTP code or one of the recovery codes
+type TwoFactorLoginRequestDto struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required,max=32"`
+}
+
+type EmailRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type TokenRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequestDto struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,max=128"`
+	PasswordConfirm string `json:"password_confirm" binding:"required,eqfield=Password"`
 }
 
 type AuthResponseRegisterDto struct {
@@ -50,26 +90,33 @@ type UserWithoutProducts struct {
 }
 
 type ProductWithUsers struct {
-	ID          uint                  `json:"ID"`
-	CreatedAt   time.Time             `json:"CreatedAt"`
-	UpdatedAt   time.Time             `json:"UpdatedAt"`
-	DeletedAt   DeletedAt             `gorm:"index" json:"DeletedAt,omitempty"`
-	Name        string                `json:"name"`
-	Description string                `json:"description"`
-	Stock       int                   `json:"stock"`
-	Price       float64               `json:"price"`
-	Users       []UserWithoutProducts `json:"users"`
+	ID            uint                  `json:"ID"`
+	CreatedAt     time.Time             `json:"CreatedAt"`
+	UpdatedAt     time.Time             `json:"UpdatedAt"`
+	DeletedAt     DeletedAt             `gorm:"index" json:"DeletedAt,omitempty"`
+	SKU           *string               `json:"sku"`
+	Name          string                `json:"name"`
+	Description   string                `json:"description"`
+	Category      string                `json:"category"`
+	Stock         int                   `json:"stock"`
+	Price         money.Money           `json:"price" swaggertype:"object,string"`
+	OriginalPrice *money.Money          `json:"original_price,omitempty" swaggertype:"object,string"`
+	AverageRating float64               `json:"average_rating"`
+	ReviewCount   int                   `json:"review_count"`
+	Users         []UserWithoutProducts `json:"users"`
 }
 
 type ProductWithoutUsers struct {
-	ID          uint      `json:"ID"`
-	CreatedAt   time.Time `json:"CreatedAt"`
-	UpdatedAt   time.Time `json:"UpdatedAt"`
-	DeletedAt   DeletedAt `json:"DeletedAt,omitempty"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Stock       int       `json:"stock"`
-	Price       float64   `json:"price"`
+	ID          uint        `json:"ID"`
+	CreatedAt   time.Time   `json:"CreatedAt"`
+	UpdatedAt   time.Time   `json:"UpdatedAt"`
+	DeletedAt   DeletedAt   `json:"DeletedAt,omitempty"`
+	SKU         *string     `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Category    string      `json:"category"`
+	Stock       int         `json:"stock"`
+	Price       money.Money `json:"price" swaggertype:"object,string"`
 }
 
 type UserWithProducts struct {
@@ -83,20 +130,30 @@ type UserWithProducts struct {
 	Password  string                `json:"password"`
 	Role      string                `json:"role"`
 	Products  []ProductWithoutUsers `json:"products"`
+
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	TOTPSecret       string     `json:"-"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TokenVersion     uint       `json:"-"`
+	DeactivatedAt    *time.Time `json:"deactivated_at"`
 }
 
 // Helper function to convert Product model to ProductWithUsers DTO
 func ConvertProductToResponse(product entity.Product) ProductWithUsers {
 	responseProduct := ProductWithUsers{
-		ID:          product.ID,
-		CreatedAt:   product.CreatedAt,
-		UpdatedAt:   product.UpdatedAt,
-		DeletedAt:   DeletedAt(product.DeletedAt),
-		Name:        product.Name,
-		Description: product.Description,
-		Stock:       product.Stock,
-		Price:       product.Price,
-		Users:       []UserWithoutProducts{},
+		ID:            product.ID,
+		CreatedAt:     product.CreatedAt,
+		UpdatedAt:     product.UpdatedAt,
+		DeletedAt:     DeletedAt(product.DeletedAt),
+		SKU:           product.SKU,
+		Name:          product.Name,
+		Description:   product.Description,
+		Category:      product.Category,
+		Stock:         product.Stock,
+		Price:         product.Price,
+		AverageRating: averageRating(product.RatingTotal, product.ReviewCount),
+		ReviewCount:   product.ReviewCount,
+		Users:         []UserWithoutProducts{},
 	}
 
 	for _, user := range product.Users {
@@ -116,6 +173,14 @@ func ConvertProductToResponse(product entity.Product) ProductWithUsers {
 	return responseProduct
 }
 
+// averageRating rounds the mean of the stored rating counters to two decimals
+func averageRating(total, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return math.Round(float64(total)/float64(count)*100) / 100
+}
+
 // Helper function to convert User model to UserWithProducts DTO
 func ConvertUserToResponse(user entity.User) UserWithProducts {
 	responseUser := UserWithProducts{
@@ -129,6 +194,12 @@ func ConvertUserToResponse(user entity.User) UserWithProducts {
 		Password:  user.Password,
 		Role:      user.Role,
 		Products:  []ProductWithoutUsers{},
+
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		TOTPSecret:       user.TOTPSecret,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		TokenVersion:     user.TokenVersion,
+		DeactivatedAt:    user.DeactivatedAt,
 	}
 
 	for _, product := range user.Products {
@@ -137,8 +208,10 @@ func ConvertUserToResponse(user entity.User) UserWithProducts {
 			CreatedAt:   product.CreatedAt,
 			UpdatedAt:   product.UpdatedAt,
 			DeletedAt:   DeletedAt(product.DeletedAt),
+			SKU:         product.SKU,
 			Name:        product.Name,
 			Description: product.Description,
+			Category:    product.Category,
 			Stock:       product.Stock,
 			Price:       product.Price,
 		})
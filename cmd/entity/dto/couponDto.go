@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+)
+
+type CouponRequestDto struct {
+	Code         string       `json:"code" binding:"required,max=64"`
+	Type         string       `json:"type" binding:"required"`
+	PercentOff   int          `json:"percent_off" binding:"gte=0,lte=100"`
+	AmountOff    *money.Money `json:"amount_off" binding:"omitempty,price" swaggertype:"object,string"`
+	MinOrder     *money.Money `json:"min_order" binding:"omitempty,price" swaggertype:"object,string"`
+	UsageLimit   int          `json:"usage_limit" binding:"gte=0"`
+	PerUserLimit int          `json:"per_user_limit" binding:"gte=0"`
+	StartsAt     *time.Time   `json:"starts_at"`
+	EndsAt       *time.Time   `json:"ends_at"`
+	Category     string       `json:"category"`
+	ProductIDs   []uint       `json:"product_ids"`
+}
+
+type CouponValidateRequestDto struct {
+	Code      string `json:"code" binding:"required"`
+	ProductID uint   `json:"product_id" binding:"required"`
+}
+
+type CouponValidateResponseDto struct {
+	Code       string      `json:"code"`
+	ProductID  uint        `json:"product_id"`
+	Price      money.Money `json:"price" swaggertype:"object,string"`
+	Discount   money.Money `json:"discount" swaggertype:"object,string"`
+	FinalPrice money.Money `json:"final_price" swaggertype:"object,string"`
+}
+
+type CouponResponseDto struct {
+	ID           uint        `json:"ID"`
+	CreatedAt    time.Time   `json:"CreatedAt"`
+	UpdatedAt    time.Time   `json:"UpdatedAt"`
+	Code         string      `json:"code"`
+	Type         string      `json:"type"`
+	PercentOff   int         `json:"percent_off,omitempty"`
+	AmountOff    money.Money `json:"amount_off" swaggertype:"object,string"`
+	MinOrder     money.Money `json:"min_order" swaggertype:"object,string"`
+	UsageLimit   int         `json:"usage_limit"`
+	PerUserLimit int         `json:"per_user_limit"`
+	UsedCount    int         `json:"used_count"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	Category     string      `json:"category"`
+	ProductIDs   []uint      `json:"product_ids"`
+}
+
+// Helper function to convert Coupon model to CouponResponseDto
+func ConvertCouponToResponse(coupon entity.Coupon) CouponResponseDto {
+	responseCoupon := CouponResponseDto{
+		ID:           coupon.ID,
+		CreatedAt:    coupon.CreatedAt,
+		UpdatedAt:    coupon.UpdatedAt,
+		Code:         coupon.Code,
+		Type:         coupon.Type,
+		PercentOff:   coupon.PercentOff,
+		AmountOff:    coupon.AmountOff,
+		MinOrder:     coupon.MinOrder,
+		UsageLimit:   coupon.UsageLimit,
+		PerUserLimit: coupon.PerUserLimit,
+		UsedCount:    coupon.UsedCount,
+		StartsAt:     coupon.StartsAt,
+		EndsAt:       coupon.EndsAt,
+		Category:     coupon.Category,
+		ProductIDs:   []uint{},
+	}
+
+	for _, product := range coupon.Products {
+		responseCoupon.ProductIDs = append(responseCoupon.ProductIDs, product.ID)
+	}
+
+	return responseCoupon
+}
@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+)
+
+type EnrollmentRequestDto struct {
+	CouponCode string `json:"coupon_code"`
+}
+
+type EnrollmentResponseDto struct {
+	UserID     uint        `json:"user_id"`
+	ProductID  uint        `json:"product_id"`
+	AmountPaid money.Money `json:"amount_paid" swaggertype:"object,string"`
+	Discount   money.Money `json:"discount" swaggertype:"object,string"`
+	CouponID   *uint       `json:"coupon_id,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Helper function to convert Enrollment model to EnrollmentResponseDto
+func ConvertEnrollmentToResponse(enrollment entity.Enrollment) EnrollmentResponseDto {
+	return EnrollmentResponseDto{
+		UserID:     enrollment.UserID,
+		ProductID:  enrollment.ProductID,
+		AmountPaid: enrollment.AmountPaid,
+		Discount:   enrollment.Discount,
+		CouponID:   enrollment.CouponID,
+		CreatedAt:  enrollment.CreatedAt,
+	}
+}
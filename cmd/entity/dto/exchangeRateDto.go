@@ -0,0 +1,7 @@
+package dto
+
+type ExchangeRateRequestDto struct {
+	BaseCurrency  string `json:"base_currency" binding:"required,len=3"`
+	QuoteCurrency string `json:"quote_currency" binding:"required,len=3"`
+	Rate          string `json:"rate" binding:"required"`
+}
@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+// ProductExportColumns names the values returned by ProductExportDto.Values
+var ProductExportColumns = []string{
+	"id", "sku", "name", "description", "category", "stock", "price", "currency",
+	"original_price", "original_currency", "average_rating", "review_count", "created_at", "updated_at",
+}
+
+// UserExportColumns names the values returned by UserExportDto.Values, passwords are never exported
+var UserExportColumns = []string{"id", "firstname", "lastname", "email", "role", "created_at", "updated_at"}
+
+type ProductExportDto struct {
+	ProductWithUsers
+}
+
+type UserExportDto struct {
+	ID        uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	FirstName string
+	LastName  string
+	Email     string
+	Role      string
+}
+
+func (p ProductExportDto) Values() []interface{} {
+	originalPrice, originalCurrency := "", ""
+	if p.OriginalPrice != nil {
+		originalPrice, originalCurrency = p.OriginalPrice.String(), p.OriginalPrice.Currency
+	}
+
+	return []interface{}{
+		p.ID, p.SKU, p.Name, p.Description, p.Category, p.Stock, p.Price.String(), p.Price.Currency,
+		originalPrice, originalCurrency, p.AverageRating, p.ReviewCount, p.CreatedAt, p.UpdatedAt,
+	}
+}
+
+func (u UserExportDto) Values() []interface{} {
+	return []interface{}{u.ID, u.FirstName, u.LastName, u.Email, u.Role, u.CreatedAt, u.UpdatedAt}
+}
+
+// Helper function to convert User model to UserExportDto
+func ConvertUserToExport(user entity.User) UserExportDto {
+	return UserExportDto{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Role:      user.Role,
+	}
+}
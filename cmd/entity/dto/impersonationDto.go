@@ -0,0 +1,10 @@
+package dto
+
+import "time"
+
+// ImpersonationResponseDto carries the token an admin uses as Bearer token to act as the user
+type ImpersonationResponseDto struct {
+	Token     string    `json:"token"`
+	UserID    uint      `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
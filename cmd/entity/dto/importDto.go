@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+const (
+	ImportActionCreated = "created"
+	ImportActionUpdated = "updated"
+	ImportActionFailed  = "failed"
+)
+
+// ProductImportRow is one raw CSV record or NDJSON object before validation
+type ProductImportRow struct {
+	SKU         string
+	Name        string
+	Description string
+	Category    string
+	Stock       string
+	Price       string
+	Currency    string
+}
+
+type ImportRowResultDto struct {
+	Row       int    `json:"row"`
+	Action    string `json:"action"`
+	ProductID uint   `json:"product_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ImportJobResponseDto struct {
+	ID         uint                 `json:"ID"`
+	CreatedAt  time.Time            `json:"CreatedAt"`
+	UpdatedAt  time.Time            `json:"UpdatedAt"`
+	Format     string               `json:"format"`
+	Status     string               `json:"status"`
+	Total      int                  `json:"total"`
+	Created    int                  `json:"created"`
+	Updated    int                  `json:"updated"`
+	Failed     int                  `json:"failed"`
+	Error      string               `json:"error,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at"`
+	Rows       []ImportRowResultDto `json:"rows"`
+}
+
+// Helper function to convert ImportJob model to ImportJobResponseDto
+func ConvertImportJobToResponse(job entity.ImportJob) ImportJobResponseDto {
+	responseJob := ImportJobResponseDto{
+		ID:         job.ID,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		Format:     job.Format,
+		Status:     job.Status,
+		Total:      job.Total,
+		Created:    job.Created,
+		Updated:    job.Updated,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		FinishedAt: job.FinishedAt,
+		Rows:       []ImportRowResultDto{},
+	}
+
+	if job.Report != "" {
+		_ = json.Unmarshal([]byte(job.Report), &responseJob.Rows)
+	}
+
+	return responseJob
+}
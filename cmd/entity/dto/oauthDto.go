@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+type OAuthClientCreateRequestDto struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Type string `json:"type" binding:"required,oneof=confidential public"`
+	// RedirectURIs must match the redirect_uri of an authorization request exactly
+	RedirectURIs []string `json:"redirect_uris" binding:"required_if=Type public,max=10,dive,url,max=500"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,scope"`
+}
+
+type OAuthClientResponseDto struct {
+	ClientID     string     `json:"client_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uint       `json:"user_id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+}
+
+// OAuthClientCreatedResponseDto carries the secret of a confidential client, it is shown only once
+type OAuthClientCreatedResponseDto struct {
+	OAuthClientResponseDto
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func ConvertOAuthClientToResponse(client entity.OAuthClient) OAuthClientResponseDto {
+	return OAuthClientResponseDto{
+		ClientID:     client.ID,
+		CreatedAt:    client.CreatedAt,
+		UserID:       client.UserID,
+		Name:         client.Name,
+		Type:         client.Type,
+		RedirectURIs: splitList(client.RedirectURIs),
+		Scopes:       splitList(client.Scopes),
+		RevokedAt:    client.RevokedAt,
+	}
+}
+
+// OAuthAuthorizeRequestDto is an authorization request (RFC 6749 section 4.1.1), PKCE with S256 is required
+type OAuthAuthorizeRequestDto struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required,eq=code"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required,max=32"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"omitempty,url,max=500"`
+	Scope               string `form:"scope" json:"scope" binding:"max=500"`
+	State               string `form:"state" json:"state" binding:"max=500"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required,min=43,max=128"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required,eq=S256"`
+}
+
+// OAuthConsentRequestDto answers an authorization request on behalf of the logged in user
+type OAuthConsentRequestDto struct {
+	OAuthAuthorizeRequestDto
+	Approve bool `json:"approve"`
+}
+
+// OAuthConsentDto describes what the user is asked to approve
+type OAuthConsentDto struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	Scopes      []string `json:"scopes"`
+	RedirectURI string   `json:"redirect_uri"`
+	State       string   `json:"state,omitempty"`
+}
+
+// OAuthRedirectDto holds the URL the frontend sends the user to after the consent
+type OAuthRedirectDto struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// OAuthClientAuthDto holds the credentials a client authenticates with, the secret is empty for public clients
+type OAuthClientAuthDto struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// OAuthTokenRequestDto is a form posted to the token endpoint, its fields are checked per grant type
+type OAuthTokenRequestDto struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// OAuthTokenResponseDto is the access token response of RFC 6749 section 5.1
+type OAuthTokenResponseDto struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospectionDto is the introspection response of RFC 7662, inactive tokens only carry Active
+type OAuthIntrospectionDto struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+// OAuthErrorDto is the error response of RFC 6749 section 5.2
+type OAuthErrorDto struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+// OIDCStartDto holds the URL of the identity provider and the state token kept in a cookie until the callback
+type OIDCStartDto struct {
+	AuthURL    string
+	StateToken string
+	StateTTL   time.Duration
+}
+
+// OIDCCallbackRequestDto is the query of the redirect back from the identity provider, Error is set when the
+// user did not log in there
+type OIDCCallbackRequestDto struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+// PersonalDataDto holds everything stored about a user, each field becomes one JSON file of the export
+type PersonalDataDto struct {
+	Profile           ProfileResponseDto
+	Enrollments       []EnrollmentResponseDto
+	CouponRedemptions []entity.CouponRedemption
+	Reviews           []entity.Review
+	Wishlist          []WishlistItemResponseDto
+	AuditLogs         []entity.AuditLog
+}
+
+type DataExportResponseDto struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func ConvertDataExportToResponse(export entity.DataExport) DataExportResponseDto {
+	return DataExportResponseDto{
+		ID:         export.ID,
+		Status:     export.Status,
+		Size:       export.Size,
+		Error:      export.Error,
+		CreatedAt:  export.CreatedAt,
+		FinishedAt: export.FinishedAt,
+		ExpiresAt:  export.ExpiresAt,
+	}
+}
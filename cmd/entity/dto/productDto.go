@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+)
+
+type ProductCreateRequestDto struct {
+	SKU         *string     `json:"sku" binding:"omitempty,min=1,max=64"`
+	Name        string      `json:"name" binding:"required,max=255"`
+	Description string      `json:"description" binding:"required"`
+	Category    string      `json:"category" binding:"max=100"`
+	Stock       int         `json:"stock" binding:"gte=0"`
+	Price       money.Money `json:"price" binding:"required,price" swaggertype:"object,string"`
+}
+
+// ProductUpdateRequestDto only changes the fields present in the payload
+type ProductUpdateRequestDto struct {
+	SKU         *string      `json:"sku" binding:"omitempty,min=1,max=64"`
+	Name        *string      `json:"name" binding:"omitempty,min=1,max=255"`
+	Description *string      `json:"description" binding:"omitempty,min=1"`
+	Category    *string      `json:"category" binding:"omitempty,max=100"`
+	Stock       *int         `json:"stock" binding:"omitempty,gte=0"`
+	Price       *money.Money `json:"price" binding:"omitempty,price" swaggertype:"object,string"`
+}
+
+// ProductFilterDto selects the products of the listing
+type ProductFilterDto struct {
+	Query    string `form:"q" json:"q" binding:"max=100"`
+	Category string `form:"category" json:"category" binding:"max=100"`
+	InStock  *bool  `form:"in_stock" json:"in_stock"`
+	Sort     string `form:"sort" json:"sort" binding:"omitempty,sort=id sku name category stock created_at"`
+	Page     int    `form:"page" json:"page" binding:"omitempty,min=1"`
+	Size     int    `form:"size" json:"size" binding:"omitempty,min=1,max=100"`
+}
+
+// Spec turns the filter into a query spec, unknown sort fields were already rejected by the binding
+func (f ProductFilterDto) Spec() query.Spec {
+	spec := query.Spec{Search: f.Query}.Paginate(f.Page, f.Size)
+	if f.Category != "" {
+		spec = spec.Where("category", query.Eq, f.Category)
+	}
+	if f.InStock != nil && *f.InStock {
+		spec = spec.Where("stock", query.Gt, 0)
+	} else if f.InStock != nil {
+		spec = spec.Where("stock", query.Lte, 0)
+	}
+	if orders, err := query.ParseSort(f.Sort, "id", "sku", "name", "category", "stock", "created_at"); err == nil {
+		spec = spec.OrderBy(orders...)
+	}
+	return spec
+}
+
+// ToEntity converts the create payload into a Product
+func (p ProductCreateRequestDto) ToEntity() entity.Product {
+	return entity.Product{
+		SKU:         p.SKU,
+		Name:        p.Name,
+		Description: p.Description,
+		Category:    p.Category,
+		Stock:       p.Stock,
+		Price:       p.Price,
+	}
+}
+
+// Changes returns the columns to update, a stock of zero is kept so a product can be sold out
+func (p ProductUpdateRequestDto) Changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if p.SKU != nil {
+		changes["sku"] = *p.SKU
+	}
+	if p.Name != nil {
+		changes["name"] = *p.Name
+	}
+	if p.Description != nil {
+		changes["description"] = *p.Description
+	}
+	if p.Category != nil {
+		changes["category"] = *p.Category
+	}
+	if p.Stock != nil {
+		changes["stock"] = *p.Stock
+	}
+	if p.Price != nil {
+		changes["price_amount"] = p.Price.Amount
+		changes["price_currency"] = p.Price.Currency
+	}
+	return changes
+}
@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+)
+
+// ProfileResponseDto is the account of the logged in user, without the password hash
+type ProfileResponseDto struct {
+	ID               uint       `json:"id"`
+	FirstName        string     `json:"firstname"`
+	LastName         string     `json:"lastname"`
+	Email            string     `json:"email"`
+	Role             string     `json:"role"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	DeactivatedAt    *time.Time `json:"deactivated_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// ProfileUpdateRequestDto changes the given fields only, a new email needs the current password
+type ProfileUpdateRequestDto struct {
+	FirstName       *string `json:"firstname" binding:"omitempty,min=1,max=300"`
+	LastName        *string `json:"lastname" binding:"omitempty,min=1,max=300"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password" binding:"required_with=Email,max=128"`
+}
+
+// UserUpdateRequestDto is the admin update of any user, the given fields only
+type UserUpdateRequestDto struct {
+	FirstName *string `json:"firstname" binding:"omitempty,min=1,max=300"`
+	LastName  *string `json:"lastname" binding:"omitempty,min=1,max=300"`
+	Email     *string `json:"email" binding:"omitempty,email"`
+	Role      *string `json:"role" binding:"omitempty,oneof=customer reseller admin"`
+}
+
+type ChangePasswordRequestDto struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=128"`
+	Password        string `json:"password" binding:"required,max=128"`
+	PasswordConfirm string `json:"password_confirm" binding:"required,eqfield=Password"`
+}
+
+type DeleteAccountRequestDto struct {
+	Password string `json:"password" binding:"required,max=128"`
+}
+
+// UserFilterDto selects users for the admin listing and the bulk actions, dates are YYYY-MM-DD
+type UserFilterDto struct {
+	Query         string `form:"q" json:"q,omitempty" binding:"max=100"`
+	Role          string `form:"role" json:"role,omitempty" binding:"omitempty,oneof=customer reseller admin"`
+	CreatedAfter  string `form:"created_after" json:"created_after,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	CreatedBefore string `form:"created_before" json:"created_before,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	HasProducts   *bool  `form:"has_products" json:"has_products,omitempty"`
+	Status        string `form:"status" json:"status,omitempty" binding:"omitempty,oneof=active deactivated unverified"`
+	Sort          string `form:"sort" json:"sort,omitempty" binding:"omitempty,sort=id email firstname lastname role created_at"`
+	Page          int    `form:"page" json:"page,omitempty" binding:"omitempty,min=1"`
+	Size          int    `form:"size" json:"size,omitempty" binding:"omitempty,min=1,max=100"`
+}
+
+// Spec turns the filter into a query spec, unknown sort fields were already rejected by the binding
+func (f UserFilterDto) Spec() query.Spec {
+	spec := query.Spec{Search: f.Query}.Paginate(f.Page, f.Size)
+	if f.Role != "" {
+		spec = spec.Where("role", query.Eq, f.Role)
+	}
+	if after, err := time.ParseInLocation("2006-01-02", f.CreatedAfter, time.Local); err == nil {
+		spec = spec.Where("created_at", query.Gte, after)
+	}
+	if before, err := time.ParseInLocation("2006-01-02", f.CreatedBefore, time.Local); err == nil {
+		spec = spec.Where("created_at", query.Lt, before)
+	}
+	if f.HasProducts != nil {
+		spec = spec.Where("has_products", query.Eq, *f.HasProducts)
+	}
+	switch f.Status {
+	case "active":
+		spec = spec.Where("deactivated_at", query.Null, true)
+	case "deactivated":
+		spec = spec.Where("deactivated_at", query.Null, false)
+	case "unverified":
+		spec = spec.Where("email_verified_at", query.Null, true)
+	}
+	if orders, err := query.ParseSort(f.Sort, "id", "email", "firstname", "lastname", "role", "created_at"); err == nil {
+		spec = spec.OrderBy(orders...)
+	}
+	return spec
+}
+
+// UserBulkRequestDto applies an action to every user matching the filter, the acting admin is always left out.
+// Sort and page of the filter are ignored.
+type UserBulkRequestDto struct {
+	Filter UserFilterDto `json:"filter"`
+	Action string        `json:"action" binding:"required,oneof=set_role deactivate activate"`
+	Role   string        `json:"role" binding:"required_if=Action set_role,omitempty,oneof=customer reseller admin"`
+	// DryRun only counts the matching users
+	DryRun bool `json:"dry_run"`
+}
+
+type UserBulkResponseDto struct {
+	Matched int64 `json:"matched"`
+	Updated int64 `json:"updated"`
+}
+
+func ConvertUserToProfile(user UserWithProducts) ProfileResponseDto {
+	return ProfileResponseDto{
+		ID:               user.ID,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		Email:            user.Email,
+		Role:             user.Role,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		DeactivatedAt:    user.DeactivatedAt,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+type ReviewRequestDto struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"required,max=200"`
+	Body   string `json:"body"`
+}
+
+type ReviewResponseDto struct {
+	ID        uint      `json:"ID"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	ProductID uint      `json:"product_id"`
+	UserID    uint      `json:"user_id"`
+	Author    string    `json:"author"`
+	Rating    int       `json:"rating"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Hidden    bool      `json:"hidden"`
+}
+
+// Helper function to convert Review model to ReviewResponseDto
+func ConvertReviewToResponse(review entity.Review) ReviewResponseDto {
+	return ReviewResponseDto{
+		ID:        review.ID,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+		ProductID: review.ProductID,
+		UserID:    review.UserID,
+		Author:    review.User.FirstName + " " + review.User.LastName,
+		Rating:    review.Rating,
+		Title:     review.Title,
+		Body:      review.Body,
+		Hidden:    review.Hidden,
+	}
+}
@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+type SessionResponseDto struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	LastSeenIP string    `json:"last_seen_ip"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the request
+	Current bool `json:"current"`
+}
+
+func ConvertSessionToResponse(session entity.Session, currentID string) SessionResponseDto {
+	return SessionResponseDto{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		LastSeenAt: session.LastSeenAt,
+		LastSeenIP: session.LastSeenIP,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.ID == currentID,
+	}
+}
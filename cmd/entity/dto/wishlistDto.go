@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+)
+
+type WishlistRequestDto struct {
+	ProductID uint `json:"product_id" binding:"required"`
+}
+
+type WishlistItemResponseDto struct {
+	ProductID uint                `json:"product_id"`
+	AddedAt   time.Time           `json:"added_at"`
+	InStock   bool                `json:"in_stock"`
+	Product   ProductWithoutUsers `json:"product"`
+}
+
+// Helper function to convert WishlistItem model to WishlistItemResponseDto
+func ConvertWishlistItemToResponse(item entity.WishlistItem) WishlistItemResponseDto {
+	return WishlistItemResponseDto{
+		ProductID: item.ProductID,
+		AddedAt:   item.CreatedAt,
+		InStock:   item.Product.Stock > 0,
+		Product: ProductWithoutUsers{
+			ID:          item.Product.ID,
+			CreatedAt:   item.Product.CreatedAt,
+			UpdatedAt:   item.Product.UpdatedAt,
+			DeletedAt:   DeletedAt(item.Product.DeletedAt),
+			SKU:         item.Product.SKU,
+			Name:        item.Product.Name,
+			Description: item.Product.Description,
+			Category:    item.Product.Category,
+			Stock:       item.Product.Stock,
+			Price:       item.Product.Price,
+		},
+	}
+}
@@ -1,8 +1,18 @@
 package entity
 
+import (
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+)
+
 type Enrollment struct {
-	UserID    uint    `gorm:"primaryKey;column:user_id"`
-	ProductID uint    `gorm:"primaryKey;column:product_id"`
-	User      User    `gorm:"foreignKey:UserID"`
-	Product   Product `gorm:"foreignKey:ProductID"`
+	UserID     uint        `gorm:"primaryKey;column:user_id"`
+	ProductID  uint        `gorm:"primaryKey;column:product_id"`
+	User       User        `gorm:"foreignKey:UserID"`
+	Product    Product     `gorm:"foreignKey:ProductID"`
+	AmountPaid money.Money `gorm:"embedded;embeddedPrefix:amount_paid_"`
+	Discount   money.Money `gorm:"embedded;embeddedPrefix:discount_"`
+	CouponID   *uint       `gorm:"column:coupon_id"`
+	CreatedAt  time.Time
 }
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ErasureChannelSelf  = "self"
+	ErasureChannelAdmin = "admin"
+)
+
+// ErrImmutable is returned when an insert-only record would be changed or deleted
+var ErrImmutable = errors.New("record is immutable")
+
+// ErasureRecord proves that the personal data of a user was erased. Subject is a keyed hash of the erased
+// email, so a later request of the same person can be answered without keeping their email. TargetHashes are
+// the space separated target hashes of the audit entries whose target became the subject, they let the audit
+// chain tell those entries from forged ones. Records are only ever inserted, the hooks refuse updates and
+// deletes.
+type ErasureRecord struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Subject      string    `gorm:"type:char(64);not null;index" json:"subject"`
+	RequestedBy  uint      `gorm:"not null" json:"requested_by"`
+	Channel      string    `gorm:"type:varchar(20);not null" json:"channel"`
+	IP           string    `gorm:"type:varchar(45)" json:"ip"`
+	TargetHashes string    `gorm:"type:text" json:"-"`
+}
+
+// AddTarget records that audit entries with targetID are erased
+func (e *ErasureRecord) AddTarget(targetID string) {
+	hash := sha256Hex(targetID)
+	if !e.Erased(hash) {
+		e.TargetHashes = strings.TrimSpace(e.TargetHashes + " " + hash)
+	}
+}
+
+// Erased reports whether the record erased the target of audit entries sealed with targetHash
+func (e ErasureRecord) Erased(targetHash string) bool {
+	return slices.Contains(strings.Fields(e.TargetHashes), targetHash)
+}
+
+func (ErasureRecord) BeforeUpdate(*gorm.DB) error {
+	return ErrImmutable
+}
+
+func (ErasureRecord) BeforeDelete(*gorm.DB) error {
+	return ErrImmutable
+}
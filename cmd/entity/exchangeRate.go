@@ -0,0 +1,12 @@
+package entity
+
+import (
+	"gorm.io/gorm"
+)
+
+type ExchangeRate struct {
+	gorm.Model
+	BaseCurrency  string `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"base_currency"`
+	QuoteCurrency string `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"quote_currency"`
+	Rate          string `gorm:"type:decimal(24,10);not null" json:"rate"`
+}
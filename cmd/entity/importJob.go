@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+type ImportJob struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Format     string     `gorm:"type:varchar(10);not null" json:"format"`
+	Status     string     `gorm:"type:varchar(20);not null" json:"status"`
+	Total      int        `gorm:"not null;default:0" json:"total"`
+	Created    int        `gorm:"not null;default:0" json:"created"`
+	Updated    int        `gorm:"not null;default:0" json:"updated"`
+	Failed     int        `gorm:"not null;default:0" json:"failed"`
+	Error      string     `gorm:"type:text" json:"error"`
+	Report     string     `gorm:"type:longtext" json:"-"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
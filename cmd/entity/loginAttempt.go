@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// LoginAttempt counts the recent failed logins of one account or client IP, Key is "email:..." or "ip:..."
+type LoginAttempt struct {
+	Key           string     `gorm:"type:varchar(320);primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
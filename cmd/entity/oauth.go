@@ -0,0 +1,55 @@
+package entity
+
+import "time"
+
+// Types of OAuth clients. Confidential clients authenticate with their secret, public clients (browser and
+// mobile apps) have none and can only use the authorization code grant.
+const (
+	OAuthClientConfidential = "confidential"
+	OAuthClientPublic       = "public"
+)
+
+// OAuthClient is an application registered to act on behalf of users. Its ID is the client_id, only the
+// SHA-256 of the secret is stored. RedirectURIs and Scopes are comma separated, Scopes bounds what the client
+// may ask for.
+type OAuthClient struct {
+	ID           string     `gorm:"type:char(32);primarykey" json:"client_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Name         string     `gorm:"type:varchar(100);not null" json:"name"`
+	Type         string     `gorm:"type:varchar(16);not null" json:"type"`
+	SecretHash   string     `gorm:"type:char(64)" json:"-"`
+	RedirectURIs string     `gorm:"type:varchar(2000)" json:"-"`
+	Scopes       string     `gorm:"type:varchar(500);not null" json:"-"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+}
+
+// OAuthCode is an authorization code approved by a user, stored by the SHA-256 of the code. It can be
+// exchanged once, with the verifier of its PKCE challenge.
+type OAuthCode struct {
+	ID            string     `gorm:"type:char(64);primarykey" json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ClientID      string     `gorm:"type:char(32);not null;index" json:"client_id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	RedirectURI   string     `gorm:"type:varchar(500);not null" json:"redirect_uri"`
+	Scopes        string     `gorm:"type:varchar(500);not null" json:"scopes"`
+	CodeChallenge string     `gorm:"type:varchar(128);not null" json:"-"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at"`
+}
+
+// OAuthGrant backs an access token and its refresh token, its ID is the JWT ID of the access token. Revoking
+// the grant revokes both. TokenVersion is the token version of the user at issue, refreshing fails once it
+// changed. Client credentials grants act as the owner of the client and have no refresh token.
+type OAuthGrant struct {
+	ID               string     `gorm:"type:char(32);primarykey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ClientID         string     `gorm:"type:char(32);not null;index" json:"client_id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	Scopes           string     `gorm:"type:varchar(500);not null" json:"scopes"`
+	TokenVersion     uint       `gorm:"not null;default:0" json:"-"`
+	RefreshHash      *string    `gorm:"type:char(64);uniqueIndex" json:"-"`
+	AccessExpiresAt  time.Time  `gorm:"not null" json:"access_expires_at"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+}
@@ -1,14 +1,19 @@
 package entity
 
 import (
+	"github.com/altsaqif/go-rest/cmd/shared/money"
 	"gorm.io/gorm"
 )
 
 type Product struct {
 	gorm.Model
-	Name        string  `gorm:"not null" json:"name"`
-	Description string  `gorm:"not null" json:"description"`
-	Stock       int     `gorm:"not null" json:"stock"`
-	Price       float64 `gorm:"not null" json:"price"`
-	Users       []User  `gorm:"many2many:enrollments;" json:"users"`
+	SKU         *string     `gorm:"type:varchar(64);uniqueIndex" json:"sku"`
+	Name        string      `gorm:"not null" json:"name"`
+	Description string      `gorm:"not null" json:"description"`
+	Category    string      `gorm:"type:varchar(100);index" json:"category"`
+	Stock       int         `gorm:"not null" json:"stock"`
+	Price       money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price" swaggertype:"object,string"`
+	ReviewCount int         `gorm:"not null;default:0" json:"-"`
+	RatingTotal int         `gorm:"not null;default:0" json:"-"`
+	Users       []User      `gorm:"many2many:enrollments;" json:"users"`
 }
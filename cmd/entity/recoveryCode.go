@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// RecoveryCode is a one-time 2FA code, only the SHA-256 hash is stored
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"time"
+)
+
+// Review is hard deleted so the author can review the product again
+type Review struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_review_user_product" json:"user_id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:idx_review_user_product;index" json:"product_id"`
+	Rating    int       `gorm:"not null" json:"rating"`
+	Title     string    `gorm:"type:varchar(200);not null" json:"title"`
+	Body      string    `gorm:"type:text" json:"body"`
+	Hidden    bool      `gorm:"not null;default:false" json:"hidden"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	Product   Product   `gorm:"foreignKey:ProductID" json:"-"`
+}
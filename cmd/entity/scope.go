@@ -0,0 +1,22 @@
+package entity
+
+// Scopes limit API keys and OAuth tokens, each route that accepts them needs one of the scopes
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeImportsRead   = "imports:read"
+	ScopeImportsWrite  = "imports:write"
+	ScopeReviewsRead   = "reviews:read"
+	ScopeCouponsRead   = "coupons:read"
+	ScopeCouponsWrite  = "coupons:write"
+	ScopeRatesRead     = "rates:read"
+	ScopeRatesWrite    = "rates:write"
+	ScopeUsersRead     = "users:read"
+	ScopeProfileRead   = "profile:read"
+)
+
+// Scopes lists every scope in the order they are documented
+var Scopes = []string{
+	ScopeProductsRead, ScopeProductsWrite, ScopeImportsRead, ScopeImportsWrite, ScopeReviewsRead,
+	ScopeCouponsRead, ScopeCouponsWrite, ScopeRatesRead, ScopeRatesWrite, ScopeUsersRead, ScopeProfileRead,
+}
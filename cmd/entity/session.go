@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Session is a login of a user on a device, its ID is the sid claim of the tokens issued for it. A session ends
+// when it is revoked, expires or the token version of the user is raised past TokenVersion. TwoFactor tells the
+// user passed a second factor for it, its tokens carry that in the twoFactor claim.
+type Session struct {
+	ID           string     `gorm:"type:char(32);primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	UserAgent    string     `gorm:"type:varchar(512)" json:"user_agent"`
+	IP           string     `gorm:"type:varchar(45)" json:"ip"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	LastSeenIP   string     `gorm:"type:varchar(45)" json:"last_seen_ip"`
+	TokenVersion uint       `gorm:"not null;default:0" json:"-"`
+	TwoFactor    bool       `gorm:"not null;default:false" json:"-"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+}
@@ -1,6 +1,8 @@
 package entity
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -12,4 +14,15 @@ type User struct {
 	Password  string    `gorm:"not null" json:"password"`
 	Role      string    `json:"role"`
 	Products  []Product `gorm:"many2many:enrollments;" json:"products"`
+
+	// EmailVerifiedAt is set once the user followed the link of the verification mail
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TokenVersion is raised to revoke every token issued before, DeactivatedAt blocks the user until reactivated
+	TokenVersion  uint       `gorm:"not null;default:0" json:"-"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+
+	// TOTPSecret is set by the 2FA setup and only trusted once TwoFactorEnabled, TOTPLastStep stops code replays
+	TOTPSecret       string `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
+	TOTPLastStep     int64  `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+	TwoFactorEnabled bool   `gorm:"not null;default:false" json:"two_factor_enabled"`
 }
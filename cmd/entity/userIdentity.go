@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// UserIdentity links a user to the account of an OpenID Connect provider, Subject is the sub claim which,
+// unlike the email, never changes
+type UserIdentity struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_identity_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identity_subject" json:"subject"`
+	Email       string     `gorm:"type:varchar(320)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// UserToken tracks a mailed token by its JWT ID so it can be used only once
+type UserToken struct {
+	ID        string     `gorm:"type:char(32);primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(32);not null" json:"purpose"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
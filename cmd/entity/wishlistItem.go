@@ -0,0 +1,13 @@
+package entity
+
+import (
+	"time"
+)
+
+type WishlistItem struct {
+	UserID    uint      `gorm:"primaryKey;column:user_id" json:"user_id"`
+	ProductID uint      `gorm:"primaryKey;column:product_id;index" json:"product_id"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	Product   Product   `gorm:"foreignKey:ProductID" json:"-"`
+}
@@ -0,0 +1,192 @@
+package migration
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"gorm.io/gorm"
+)
+
+// joinTables lists many2many tables without their own entity
+var joinTables = []interface{}{"coupon_products"}
+
+// models lists every table managed by the application
+func models() []interface{} {
+	return []interface{}{
+		&entity.User{},
+		&entity.Product{},
+		&entity.Enrollment{},
+		&entity.ExchangeRate{},
+		&entity.Coupon{},
+		&entity.CouponRedemption{},
+		&entity.Review{},
+		&entity.WishlistItem{},
+		&entity.ImportJob{},
+		&entity.LoginAttempt{},
+		&entity.AuditLog{},
+		&entity.RecoveryCode{},
+		&entity.UserToken{},
+		&entity.DataExport{},
+		&entity.ErasureRecord{},
+		&entity.APIKey{},
+		&entity.OAuthClient{},
+		&entity.OAuthCode{},
+		&entity.OAuthGrant{},
+		&entity.UserIdentity{},
+		&entity.Session{},
+	}
+}
+
+// Run prepares the database schema and migrates existing data
+func Run(db *gorm.DB, cfg *config.Config) error {
+	if cfg.Reset {
+		slog.Warn("DB_RESET is enabled, dropping existing tables")
+		if err := db.Migrator().DropTable(append(joinTables, models()...)...); err != nil {
+			return fmt.Errorf("failed to drop tables: %v", err)
+		}
+	}
+
+	// Both sides of the many2many relation share the custom enrollments join table
+	if err := db.SetupJoinTable(&entity.User{}, "Products", &entity.Enrollment{}); err != nil {
+		return fmt.Errorf("failed to setup join table: %v", err)
+	}
+	if err := db.SetupJoinTable(&entity.Product{}, "Users", &entity.Enrollment{}); err != nil {
+		return fmt.Errorf("failed to setup join table: %v", err)
+	}
+
+	if err := sealAuditLogs(db); err != nil {
+		return err
+	}
+	if err := markTwoFactorSessions(db); err != nil {
+		return err
+	}
+	if err := recordErasedTargets(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(models()...); err != nil {
+		return fmt.Errorf("failed to run migrations: %v", err)
+	}
+
+	return migrateProductPrices(db, cfg.DefaultCurrency)
+}
+
+// migrateProductPrices moves the legacy float `price` column into integer minor units
+func migrateProductPrices(db *gorm.DB, currency string) error {
+	if !db.Migrator().HasColumn(&entity.Product{}, "price") {
+		return nil
+	}
+
+	exp, ok := money.Exponent(currency)
+	if !ok {
+		return fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	slog.Info("Migrating product prices to minor units", "currency", currency)
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("UPDATE products SET price_amount = ROUND(price * ?), price_currency = ? WHERE price_currency = ''",
+			int64(math.Pow10(exp)), currency).Error
+		if err != nil {
+			return fmt.Errorf("failed to migrate product prices: %v", err)
+		}
+		return tx.Migrator().DropColumn(&entity.Product{}, "price")
+	})
+}
+
+// markTwoFactorSessions adds the two_factor column to existing sessions. Their tokens carried the 2FA setting of
+// the user, so the sessions of users with 2FA keep counting as passed a second factor.
+func markTwoFactorSessions(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&entity.Session{}) || migrator.HasColumn(&entity.Session{}, "TwoFactor") {
+		return nil
+	}
+
+	if err := migrator.AddColumn(&entity.Session{}, "TwoFactor"); err != nil {
+		return fmt.Errorf("failed to add session column two_factor: %v", err)
+	}
+	err := db.Model(&entity.Session{}).
+		Where("user_id IN (?)", db.Model(&entity.User{}).Select("id").Where("two_factor_enabled = ?", true)).
+		Update("two_factor", true).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark 2FA sessions: %v", err)
+	}
+	return nil
+}
+
+// recordErasedTargets adds the target_hashes column to existing erasure records and fills it with the target
+// hashes of the audit entries erased to their subject, so verifying the chain keeps accepting those entries.
+func recordErasedTargets(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&entity.ErasureRecord{}) || migrator.HasColumn(&entity.ErasureRecord{}, "TargetHashes") {
+		return nil
+	}
+
+	if err := migrator.AddColumn(&entity.ErasureRecord{}, "TargetHashes"); err != nil {
+		return fmt.Errorf("failed to add erasure record column target_hashes: %v", err)
+	}
+	var records []entity.ErasureRecord
+	if err := db.Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to read erasure records: %v", err)
+	}
+	for _, record := range records {
+		var hashes []string
+		err := db.Model(&entity.AuditLog{}).Where("target_id = ?", entity.AuditErasedPrefix+record.Subject).
+			Distinct().Pluck("target_hash", &hashes).Error
+		if err != nil {
+			return fmt.Errorf("failed to read the audit entries of erasure record %d: %v", record.ID, err)
+		}
+		// UpdateColumn skips the hook that refuses changes to erasure records
+		err = db.Model(&record).UpdateColumn("target_hashes", strings.Join(hashes, " ")).Error
+		if err != nil {
+			return fmt.Errorf("failed to record the erased targets of erasure record %d: %v", record.ID, err)
+		}
+	}
+	return nil
+}
+
+// sealAuditLogs chains the audit entries written before they were sealed. It runs before the unique indexes on
+// the hashes are created, which the unsealed entries would violate, and until they are: a failed run starts over.
+func sealAuditLogs(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&entity.AuditLog{}) || migrator.HasIndex(&entity.AuditLog{}, "idx_audit_logs_hash") {
+		return nil
+	}
+
+	slog.Info("Sealing existing audit entries")
+	for _, field := range []string{"RequestID", "Changes", "TargetHash", "PrevHash", "Hash"} {
+		if migrator.HasColumn(&entity.AuditLog{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&entity.AuditLog{}, field); err != nil {
+			return fmt.Errorf("failed to add audit column %s: %v", field, err)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var (
+			batch []entity.AuditLog
+			prev  string
+		)
+		return tx.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				entry.Seal(prev)
+				// UpdateColumns skips the hook that refuses changes to audit entries
+				err := tx.Model(&entry).UpdateColumns(map[string]interface{}{
+					"target_hash": entry.TargetHash,
+					"prev_hash":   entry.PrevHash,
+					"hash":        entry.Hash,
+				}).Error
+				if err != nil {
+					return fmt.Errorf("failed to seal audit entry %d: %v", entry.ID, err)
+				}
+				prev = entry.Hash
+			}
+			return nil
+		}).Error
+	})
+}
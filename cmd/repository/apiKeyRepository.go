@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, payload entity.APIKey) (entity.APIKey, error)
+	FindByID(ctx context.Context, id uint) (entity.APIKey, error)
+	FindByPrefix(ctx context.Context, prefix string) (entity.APIKey, error)
+	// FindByUser returns the keys of the user, newest first
+	FindByUser(ctx context.Context, userID uint) ([]entity.APIKey, error)
+	// Revoke marks the key revoked, revoking it again keeps the first revocation time
+	Revoke(ctx context.Context, id uint) error
+	// Touch records a use of the key, at most once per interval
+	Touch(ctx context.Context, id uint, ip string, interval time.Duration) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// Create implements APIKeyRepository.
+func (a *apiKeyRepository) Create(ctx context.Context, payload entity.APIKey) (entity.APIKey, error) {
+	err := a.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// FindByID implements APIKeyRepository.
+func (a *apiKeyRepository) FindByID(ctx context.Context, id uint) (entity.APIKey, error) {
+	var key entity.APIKey
+	err := a.db.WithContext(ctx).First(&key, id).Error
+	return key, translate(err, ErrAPIKeyNotFound, nil)
+}
+
+// FindByPrefix implements APIKeyRepository.
+func (a *apiKeyRepository) FindByPrefix(ctx context.Context, prefix string) (entity.APIKey, error) {
+	var key entity.APIKey
+	err := a.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	return key, translate(err, ErrAPIKeyNotFound, nil)
+}
+
+// FindByUser implements APIKeyRepository.
+func (a *apiKeyRepository) FindByUser(ctx context.Context, userID uint) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	err := a.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke implements APIKeyRepository.
+func (a *apiKeyRepository) Revoke(ctx context.Context, id uint) error {
+	return a.db.WithContext(ctx).Model(&entity.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Touch implements APIKeyRepository.
+func (a *apiKeyRepository) Touch(ctx context.Context, id uint, ip string, interval time.Duration) error {
+	now := time.Now()
+	return a.db.WithContext(ctx).Model(&entity.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ? OR last_used_ip <> ?)", id, now.Add(-interval), ip).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"gorm.io/gorm"
+)
+
+// maxAuditAppends bounds the retries of an entry that lost the race for the end of the chain
+const maxAuditAppends = 5
+
+// errChainBroken stops the walk of Verify at the first entry that does not follow the one before
+var errChainBroken = errors.New("audit chain broken")
+
+type AuditLogRepository interface {
+	// Create appends the entry to the end of the chain
+	Create(ctx context.Context, payload entity.AuditLog) (entity.AuditLog, error)
+	FindAll(ctx context.Context, spec query.Spec) ([]entity.AuditLog, model.Paging, error)
+	// Verify walks the chain from the first entry, it returns the number of intact entries and the ID of the
+	// first entry that was changed or follows a removed one
+	Verify(ctx context.Context) (int64, *uint, error)
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+// auditLogListing knows the fields audit entries can be filtered and sorted by
+var auditLogListing = listing{
+	columns: map[string]string{
+		"id":          "audit_logs.id",
+		"created_at":  "audit_logs.created_at",
+		"actor_id":    "audit_logs.actor_id",
+		"action":      "audit_logs.action",
+		"target_type": "audit_logs.target_type",
+		"target_id":   "audit_logs.target_id",
+		"request_id":  "audit_logs.request_id",
+	},
+	tiebreak: "audit_logs.id",
+}
+
+// Create implements AuditLogRepository. Concurrent entries may read the same end of the chain, the unique
+// previous hash lets only one of them follow it and the others retry with the new end.
+func (a *auditLogRepository) Create(ctx context.Context, payload entity.AuditLog) (entity.AuditLog, error) {
+	db := a.db.WithContext(ctx)
+	// Hashed as the database stores it
+	payload.CreatedAt = time.Now().Truncate(time.Millisecond)
+
+	for attempt := 1; ; attempt++ {
+		var last entity.AuditLog
+		if err := db.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return entity.AuditLog{}, err
+		}
+
+		payload.ID = 0
+		payload.Seal(last.Hash)
+		err := db.Create(&payload).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) && attempt < maxAuditAppends {
+			continue
+		}
+		return payload, err
+	}
+}
+
+// FindAll implements AuditLogRepository.
+func (a *auditLogRepository) FindAll(ctx context.Context, spec query.Spec) ([]entity.AuditLog, model.Paging, error) {
+	var entries []entity.AuditLog
+	paging, err := auditLogListing.page(a.db.WithContext(ctx).Model(&entity.AuditLog{}), spec, &entries)
+	if err != nil {
+		slog.ErrorContext(ctx, "auditLogRepository.FindAll", "err", err)
+		return nil, model.Paging{}, err
+	}
+	return entries, paging, nil
+}
+
+// Verify implements AuditLogRepository.
+func (a *auditLogRepository) Verify(ctx context.Context) (int64, *uint, error) {
+	var (
+		batch   []entity.AuditLog
+		checked int64
+		broken  *uint
+		prev    string
+	)
+	err := a.db.WithContext(ctx).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		erasures, err := a.erasures(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			if !entry.Intact(prev, erasures) {
+				id := entry.ID
+				broken = &id
+				return errChainBroken
+			}
+			prev = entry.Hash
+			checked++
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return 0, nil, err
+	}
+	return checked, broken, nil
+}
+
+// erasures returns the erasure records of the subjects the entries were erased to, by subject
+func (a *auditLogRepository) erasures(ctx context.Context, entries []entity.AuditLog) (map[string][]entity.ErasureRecord, error) {
+	var subjects []string
+	for _, entry := range entries {
+		if subject, ok := strings.CutPrefix(entry.TargetID, entity.AuditErasedPrefix); ok {
+			subjects = append(subjects, subject)
+		}
+	}
+	if len(subjects) == 0 {
+		return nil, nil
+	}
+
+	var records []entity.ErasureRecord
+	if err := a.db.WithContext(ctx).Where("subject IN ?", subjects).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	erasures := make(map[string][]entity.ErasureRecord, len(records))
+	for _, record := range records {
+		erasures[record.Subject] = append(erasures[record.Subject], record)
+	}
+	return erasures, nil
+}
+
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
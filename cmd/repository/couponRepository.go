@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"math"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"gorm.io/gorm"
+)
+
+type CouponRepository interface {
+	Create(ctx context.Context, payload entity.Coupon, productIDs []uint) (entity.Coupon, error)
+	FindByID(ctx context.Context, id uint) (entity.Coupon, error)
+	FindByCode(ctx context.Context, code string) (entity.Coupon, error)
+	FindAll(ctx context.Context, page, size int) ([]entity.Coupon, model.Paging, error)
+	UpdateByID(ctx context.Context, id uint, payload entity.Coupon, productIDs []uint) (entity.Coupon, error)
+	DeleteByID(ctx context.Context, id uint) error
+	CountRedemptions(ctx context.Context, couponID, userID uint) (int64, error)
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+// Create implements CouponRepository.
+func (c *couponRepository) Create(ctx context.Context, payload entity.Coupon, productIDs []uint) (entity.Coupon, error) {
+	db := c.db.WithContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		products, err := findProductsByIDs(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		payload.Products = products
+		return tx.Create(&payload).Error
+	})
+	if err != nil {
+		return entity.Coupon{}, translate(err, nil, ErrCouponCodeTaken)
+	}
+
+	var coupon entity.Coupon
+	err = db.Preload("Products").First(&coupon, payload.ID).Error
+	return coupon, err
+}
+
+// FindByID implements CouponRepository.
+func (c *couponRepository) FindByID(ctx context.Context, id uint) (entity.Coupon, error) {
+	var coupon entity.Coupon
+	err := c.db.WithContext(ctx).Preload("Products").First(&coupon, id).Error
+	return coupon, translate(err, ErrCouponNotFound, nil)
+}
+
+// FindByCode implements CouponRepository.
+func (c *couponRepository) FindByCode(ctx context.Context, code string) (entity.Coupon, error) {
+	var coupon entity.Coupon
+	err := c.db.WithContext(ctx).Preload("Products").Where("code = ?", code).First(&coupon).Error
+	return coupon, translate(err, ErrCouponNotFound, nil)
+}
+
+// FindAll implements CouponRepository.
+func (c *couponRepository) FindAll(ctx context.Context, page, size int) ([]entity.Coupon, model.Paging, error) {
+	db := c.db.WithContext(ctx)
+	offset := (page - 1) * size
+
+	var totalCoupons int64
+	if err := db.Model(&entity.Coupon{}).Count(&totalCoupons).Error; err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	var coupons []entity.Coupon
+	if err := db.Limit(size).Offset(offset).Preload("Products").Order("id DESC").Find(&coupons).Error; err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	paging := model.Paging{
+		Page:        page,
+		RowsPerPage: size,
+		TotalRows:   int(totalCoupons),
+		TotalPages:  int(math.Ceil(float64(totalCoupons) / float64(size))),
+	}
+
+	return coupons, paging, nil
+}
+
+// UpdateByID implements CouponRepository.
+func (c *couponRepository) UpdateByID(ctx context.Context, id uint, payload entity.Coupon, productIDs []uint) (entity.Coupon, error) {
+	db := c.db.WithContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var coupon entity.Coupon
+		if err := tx.First(&coupon, id).Error; err != nil {
+			return err
+		}
+
+		// Every field is replaced, but the usage counter is owned by redemptions
+		err := tx.Model(&coupon).Select("*").Omit("id", "created_at", "deleted_at", "used_count", "Products").Updates(&payload).Error
+		if err != nil {
+			return err
+		}
+
+		products, err := findProductsByIDs(tx, productIDs)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&coupon).Association("Products").Replace(products)
+	})
+	if err != nil {
+		return entity.Coupon{}, translate(err, ErrCouponNotFound, ErrCouponCodeTaken)
+	}
+
+	var coupon entity.Coupon
+	err = db.Preload("Products").First(&coupon, id).Error
+	return coupon, err
+}
+
+// DeleteByID implements CouponRepository.
+func (c *couponRepository) DeleteByID(ctx context.Context, id uint) error {
+	res := c.db.WithContext(ctx).Delete(&entity.Coupon{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrCouponNotFound
+	}
+	return res.Error
+}
+
+// CountRedemptions implements CouponRepository.
+func (c *couponRepository) CountRedemptions(ctx context.Context, couponID, userID uint) (int64, error) {
+	var count int64
+	err := c.db.WithContext(ctx).Model(&entity.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", couponID, userID).Count(&count).Error
+	return count, err
+}
+
+func findProductsByIDs(tx *gorm.DB, ids []uint) ([]entity.Product, error) {
+	products := []entity.Product{}
+	if len(ids) == 0 {
+		return products, nil
+	}
+	if err := tx.Find(&products, ids).Error; err != nil {
+		return nil, err
+	}
+	if len(products) != len(ids) {
+		return nil, ErrProductNotFound
+	}
+	return products, nil
+}
+
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
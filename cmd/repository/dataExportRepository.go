@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"gorm.io/gorm"
+)
+
+type DataExportRepository interface {
+	Create(ctx context.Context, payload entity.DataExport) (entity.DataExport, error)
+	FindByID(ctx context.Context, id string) (entity.DataExport, error)
+	// FindByUser returns the exports of the user, newest first
+	FindByUser(ctx context.Context, userID uint) ([]entity.DataExport, error)
+	// FindExpired returns the exports that expired before now
+	FindExpired(ctx context.Context, now time.Time) ([]entity.DataExport, error)
+	// Finish stores the outcome of the export, it fails with ErrDataExportNotFound once the export was removed
+	Finish(ctx context.Context, payload entity.DataExport) error
+	DeleteByID(ctx context.Context, id string) error
+	// FindPersonalData collects the records of the user for an export, without the profile
+	FindPersonalData(ctx context.Context, userID uint, email string) (dto.PersonalDataDto, error)
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+// Create implements DataExportRepository.
+func (d *dataExportRepository) Create(ctx context.Context, payload entity.DataExport) (entity.DataExport, error) {
+	err := d.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// FindByID implements DataExportRepository.
+func (d *dataExportRepository) FindByID(ctx context.Context, id string) (entity.DataExport, error) {
+	var export entity.DataExport
+	err := d.db.WithContext(ctx).Where("id = ?", id).First(&export).Error
+	return export, translate(err, ErrDataExportNotFound, nil)
+}
+
+// FindByUser implements DataExportRepository.
+func (d *dataExportRepository) FindByUser(ctx context.Context, userID uint) ([]entity.DataExport, error) {
+	var exports []entity.DataExport
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+// FindExpired implements DataExportRepository.
+func (d *dataExportRepository) FindExpired(ctx context.Context, now time.Time) ([]entity.DataExport, error) {
+	var exports []entity.DataExport
+	err := d.db.WithContext(ctx).Where("expires_at < ?", now).Find(&exports).Error
+	return exports, err
+}
+
+// Finish implements DataExportRepository.
+func (d *dataExportRepository) Finish(ctx context.Context, payload entity.DataExport) error {
+	result := d.db.WithContext(ctx).Model(&entity.DataExport{}).Where("id = ?", payload.ID).
+		Select("status", "size", "error", "finished_at").Updates(payload)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDataExportNotFound
+	}
+	return nil
+}
+
+// DeleteByID implements DataExportRepository.
+func (d *dataExportRepository) DeleteByID(ctx context.Context, id string) error {
+	return d.db.WithContext(ctx).Where("id = ?", id).Delete(&entity.DataExport{}).Error
+}
+
+// FindPersonalData implements DataExportRepository. Audit entries are those the user caused and those
+// about them, lockouts name the email instead of the user ID.
+func (d *dataExportRepository) FindPersonalData(ctx context.Context, userID uint, email string) (dto.PersonalDataDto, error) {
+	db := d.db.WithContext(ctx)
+	var data dto.PersonalDataDto
+
+	var enrollments []entity.Enrollment
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&enrollments).Error; err != nil {
+		return dto.PersonalDataDto{}, err
+	}
+	data.Enrollments = make([]dto.EnrollmentResponseDto, len(enrollments))
+	for i, enrollment := range enrollments {
+		data.Enrollments[i] = dto.ConvertEnrollmentToResponse(enrollment)
+	}
+
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.CouponRedemptions).Error; err != nil {
+		return dto.PersonalDataDto{}, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.Reviews).Error; err != nil {
+		return dto.PersonalDataDto{}, err
+	}
+
+	var wishlist []entity.WishlistItem
+	if err := db.Preload("Product").Where("user_id = ?", userID).Order("created_at").Find(&wishlist).Error; err != nil {
+		return dto.PersonalDataDto{}, err
+	}
+	data.Wishlist = make([]dto.WishlistItemResponseDto, len(wishlist))
+	for i, item := range wishlist {
+		data.Wishlist[i] = dto.ConvertWishlistItemToResponse(item)
+	}
+
+	err := db.Where("actor_id = ?", userID).
+		Or("target_type = ? AND target_id = ?", "user", strconv.FormatUint(uint64(userID), 10)).
+		Or("LOWER(target_id) = ?", strings.ToLower(email)).
+		Order("id").Find(&data.AuditLogs).Error
+	if err != nil {
+		return dto.PersonalDataDto{}, err
+	}
+	return data, nil
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
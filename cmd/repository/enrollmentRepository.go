@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CouponRule computes the discount of a locked coupon for a product, redeemed is the
+// number of times the enrolling user has already used the coupon
+type CouponRule func(coupon entity.Coupon, product entity.Product, redeemed int64) (money.Money, error)
+
+type EnrollmentRepository interface {
+	Enroll(ctx context.Context, userID, productID uint, couponCode string, rule CouponRule) (entity.Enrollment, error)
+}
+
+type enrollmentRepository struct {
+	db *gorm.DB
+}
+
+// Enroll implements EnrollmentRepository.
+func (e *enrollmentRepository) Enroll(ctx context.Context, userID, productID uint, couponCode string, rule CouponRule) (entity.Enrollment, error) {
+	var enrollment entity.Enrollment
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product entity.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		if product.Stock <= 0 {
+			return ErrOutOfStock
+		}
+
+		var enrolled int64
+		if err := tx.Model(&entity.Enrollment{}).Where("user_id = ? AND product_id = ?", userID, productID).Count(&enrolled).Error; err != nil {
+			return err
+		}
+		if enrolled > 0 {
+			return ErrAlreadyEnrolled
+		}
+
+		enrollment = entity.Enrollment{
+			UserID:     userID,
+			ProductID:  productID,
+			AmountPaid: product.Price,
+			Discount:   money.New(0, product.Price.Currency),
+		}
+
+		if couponCode != "" {
+			discount, couponID, err := redeemCoupon(tx, userID, product, couponCode, rule)
+			if err != nil {
+				return err
+			}
+			enrollment.Discount = discount
+			enrollment.AmountPaid = money.New(product.Price.Amount-discount.Amount, product.Price.Currency)
+			enrollment.CouponID = &couponID
+		}
+
+		res := tx.Model(&entity.Product{}).Where("id = ? AND stock > 0", productID).
+			UpdateColumn("stock", gorm.Expr("stock - 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrOutOfStock
+		}
+
+		return tx.Omit(clause.Associations).Create(&enrollment).Error
+	})
+	if err != nil {
+		return entity.Enrollment{}, err
+	}
+	return enrollment, nil
+}
+
+// redeemCoupon locks the coupon row so usage limits are checked and counted atomically
+func redeemCoupon(tx *gorm.DB, userID uint, product entity.Product, code string, rule CouponRule) (money.Money, uint, error) {
+	var coupon entity.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return money.Money{}, 0, ErrCouponNotFound
+		}
+		return money.Money{}, 0, err
+	}
+	if err := tx.Model(&coupon).Association("Products").Find(&coupon.Products); err != nil {
+		return money.Money{}, 0, err
+	}
+
+	var redeemed int64
+	if err := tx.Model(&entity.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, userID).Count(&redeemed).Error; err != nil {
+		return money.Money{}, 0, err
+	}
+
+	discount, err := rule(coupon, product, redeemed)
+	if err != nil {
+		return money.Money{}, 0, err
+	}
+
+	res := tx.Model(&entity.Coupon{}).Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", coupon.ID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if res.Error != nil {
+		return money.Money{}, 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return money.Money{}, 0, ErrCouponExhausted
+	}
+
+	redemption := entity.CouponRedemption{
+		CouponID:  coupon.ID,
+		UserID:    userID,
+		ProductID: product.ID,
+		Discount:  discount,
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return money.Money{}, 0, err
+	}
+
+	return discount, coupon.ID, nil
+}
+
+func NewEnrollmentRepository(db *gorm.DB) EnrollmentRepository {
+	return &enrollmentRepository{db: db}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound         = apperror.NotFound("user not found")
+	ErrEmailTaken           = apperror.Conflict("email is already registered")
+	ErrProductNotFound      = apperror.NotFound("product not found")
+	ErrSKUTaken             = apperror.Conflict("SKU is already used by another product")
+	ErrOutOfStock           = apperror.Conflict("product is out of stock")
+	ErrAlreadyEnrolled      = apperror.Conflict("user is already enrolled in this product")
+	ErrCouponNotFound       = apperror.NotFound("coupon not found")
+	ErrCouponCodeTaken      = apperror.Conflict("coupon code already exists")
+	ErrCouponExhausted      = apperror.Conflict("coupon usage limit has been reached")
+	ErrNotEnrolled          = apperror.Forbidden("only customers enrolled in this product can review it")
+	ErrAlreadyReviewed      = apperror.Conflict("user has already reviewed this product")
+	ErrReviewNotFound       = apperror.NotFound("review not found")
+	ErrWishlistItemNotFound = apperror.NotFound("product is not in the wishlist")
+	ErrImportJobNotFound    = apperror.NotFound("import not found")
+	ErrDataExportNotFound   = apperror.NotFound("data export not found")
+	ErrAPIKeyNotFound       = apperror.NotFound("API key not found")
+	ErrOAuthClientNotFound  = apperror.NotFound("OAuth client not found")
+	ErrOAuthCodeNotFound    = apperror.NotFound("authorization code not found or already used")
+	ErrOAuthGrantNotFound   = apperror.NotFound("OAuth grant not found or revoked")
+	ErrIdentityNotFound     = apperror.NotFound("identity not found")
+	ErrIdentityTaken        = apperror.Conflict("identity is already linked to a user")
+	ErrSessionNotFound      = apperror.NotFound("session not found")
+)
+
+// translate replaces the gorm errors a caller can act on with domain errors, nil leaves that case untouched
+func translate(err, notFound, duplicate error) error {
+	switch {
+	case notFound != nil && errors.Is(err, gorm.ErrRecordNotFound):
+		return notFound
+	case duplicate != nil && errors.Is(err, gorm.ErrDuplicatedKey):
+		return duplicate
+	default:
+		return err
+	}
+}
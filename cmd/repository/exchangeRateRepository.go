@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepository interface {
+	Upsert(ctx context.Context, payload entity.ExchangeRate) (entity.ExchangeRate, error)
+	FindAll(ctx context.Context) ([]entity.ExchangeRate, error)
+	FindByPair(ctx context.Context, base, quote string) (entity.ExchangeRate, error)
+}
+
+type exchangeRateRepository struct {
+	db *gorm.DB
+}
+
+// Upsert implements ExchangeRateRepository.
+func (e *exchangeRateRepository) Upsert(ctx context.Context, payload entity.ExchangeRate) (entity.ExchangeRate, error) {
+	db := e.db.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&payload).Error
+	if err != nil {
+		return entity.ExchangeRate{}, err
+	}
+
+	var rate entity.ExchangeRate
+	err = db.Where("base_currency = ? AND quote_currency = ?", payload.BaseCurrency, payload.QuoteCurrency).First(&rate).Error
+	return rate, err
+}
+
+// FindAll implements ExchangeRateRepository.
+func (e *exchangeRateRepository) FindAll(ctx context.Context) ([]entity.ExchangeRate, error) {
+	var rates []entity.ExchangeRate
+	err := e.db.WithContext(ctx).Order("base_currency, quote_currency").Find(&rates).Error
+	return rates, err
+}
+
+// FindByPair implements ExchangeRateRepository.
+func (e *exchangeRateRepository) FindByPair(ctx context.Context, base, quote string) (entity.ExchangeRate, error) {
+	var rate entity.ExchangeRate
+	err := e.db.WithContext(ctx).Where("base_currency = ? AND quote_currency = ?", base, quote).First(&rate).Error
+	return rate, err
+}
+
+func NewExchangeRateRepository(db *gorm.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	Create(ctx context.Context, payload entity.ImportJob) (entity.ImportJob, error)
+	FindByID(ctx context.Context, id uint) (entity.ImportJob, error)
+	Save(ctx context.Context, payload entity.ImportJob) error
+}
+
+type importJobRepository struct {
+	db *gorm.DB
+}
+
+// Create implements ImportJobRepository.
+func (i *importJobRepository) Create(ctx context.Context, payload entity.ImportJob) (entity.ImportJob, error) {
+	err := i.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// FindByID implements ImportJobRepository.
+func (i *importJobRepository) FindByID(ctx context.Context, id uint) (entity.ImportJob, error) {
+	var job entity.ImportJob
+	err := i.db.WithContext(ctx).First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrImportJobNotFound
+	}
+	return job, err
+}
+
+// Save implements ImportJobRepository.
+func (i *importJobRepository) Save(ctx context.Context, payload entity.ImportJob) error {
+	return i.db.WithContext(ctx).Save(&payload).Error
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{db: db}
+}
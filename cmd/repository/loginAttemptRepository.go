@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepository interface {
+	FindByKeys(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error)
+	// Update locks the attempt row of key, creating it when missing, and saves what fn changed
+	Update(ctx context.Context, key string, fn func(attempt *entity.LoginAttempt)) (entity.LoginAttempt, error)
+	DeleteByKey(ctx context.Context, key string) (bool, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// FindByKeys implements LoginAttemptRepository.
+func (l *loginAttemptRepository) FindByKeys(ctx context.Context, keys ...string) ([]entity.LoginAttempt, error) {
+	var attempts []entity.LoginAttempt
+	err := l.db.WithContext(ctx).Where(map[string]interface{}{"key": keys}).Find(&attempts).Error
+	return attempts, err
+}
+
+// Update implements LoginAttemptRepository.
+func (l *loginAttemptRepository) Update(ctx context.Context, key string, fn func(attempt *entity.LoginAttempt)) (entity.LoginAttempt, error) {
+	var attempt entity.LoginAttempt
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Concurrent first failures race on the insert, the loser just locks the winner's row
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.LoginAttempt{Key: key}).Error
+		if err != nil && !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(map[string]interface{}{"key": key}).First(&attempt).Error; err != nil {
+			return err
+		}
+
+		fn(&attempt)
+		return tx.Save(&attempt).Error
+	})
+	return attempt, err
+}
+
+// DeleteByKey implements LoginAttemptRepository.
+func (l *loginAttemptRepository) DeleteByKey(ctx context.Context, key string) (bool, error) {
+	result := l.db.WithContext(ctx).Where(map[string]interface{}{"key": key}).Delete(&entity.LoginAttempt{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
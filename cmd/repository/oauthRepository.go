@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, payload entity.OAuthClient) (entity.OAuthClient, error)
+	FindClientByID(ctx context.Context, id string) (entity.OAuthClient, error)
+	// FindClientsByUser returns the clients registered by the user, newest first
+	FindClientsByUser(ctx context.Context, userID uint) ([]entity.OAuthClient, error)
+	// RevokeClient marks the client revoked together with every grant issued to it
+	RevokeClient(ctx context.Context, id string) error
+	CreateCode(ctx context.Context, payload entity.OAuthCode) error
+	// UseCode marks the code used and returns it, codes already used fail with ErrOAuthCodeNotFound
+	UseCode(ctx context.Context, id string) (entity.OAuthCode, error)
+	CreateGrant(ctx context.Context, payload entity.OAuthGrant) error
+	FindGrantByID(ctx context.Context, id string) (entity.OAuthGrant, error)
+	FindGrantByRefreshHash(ctx context.Context, hash string) (entity.OAuthGrant, error)
+	// RotateGrant revokes the grant id and creates next in its place, a grant revoked meanwhile fails with
+	// ErrOAuthGrantNotFound so a refresh token is exchanged only once
+	RotateGrant(ctx context.Context, id string, next entity.OAuthGrant) error
+	// RevokeGrant marks the grant revoked, revoking it again keeps the first revocation time
+	RevokeGrant(ctx context.Context, id string) error
+}
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+// CreateClient implements OAuthRepository.
+func (o *oauthRepository) CreateClient(ctx context.Context, payload entity.OAuthClient) (entity.OAuthClient, error) {
+	err := o.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// FindClientByID implements OAuthRepository.
+func (o *oauthRepository) FindClientByID(ctx context.Context, id string) (entity.OAuthClient, error) {
+	var client entity.OAuthClient
+	err := o.db.WithContext(ctx).Where("id = ?", id).First(&client).Error
+	return client, translate(err, ErrOAuthClientNotFound, nil)
+}
+
+// FindClientsByUser implements OAuthRepository.
+func (o *oauthRepository) FindClientsByUser(ctx context.Context, userID uint) ([]entity.OAuthClient, error) {
+	var clients []entity.OAuthClient
+	err := o.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&clients).Error
+	return clients, err
+}
+
+// RevokeClient implements OAuthRepository.
+func (o *oauthRepository) RevokeClient(ctx context.Context, id string) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&entity.OAuthClient{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&entity.OAuthGrant{}).Where("client_id = ? AND revoked_at IS NULL", id).Update("revoked_at", now).Error
+	})
+}
+
+// CreateCode implements OAuthRepository.
+func (o *oauthRepository) CreateCode(ctx context.Context, payload entity.OAuthCode) error {
+	return o.db.WithContext(ctx).Create(&payload).Error
+}
+
+// UseCode implements OAuthRepository.
+func (o *oauthRepository) UseCode(ctx context.Context, id string) (entity.OAuthCode, error) {
+	var code entity.OAuthCode
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).First(&code).Error; err != nil {
+			return translate(err, ErrOAuthCodeNotFound, nil)
+		}
+
+		result := tx.Model(&entity.OAuthCode{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOAuthCodeNotFound
+		}
+		return nil
+	})
+	return code, err
+}
+
+// CreateGrant implements OAuthRepository.
+func (o *oauthRepository) CreateGrant(ctx context.Context, payload entity.OAuthGrant) error {
+	return o.db.WithContext(ctx).Create(&payload).Error
+}
+
+// FindGrantByID implements OAuthRepository.
+func (o *oauthRepository) FindGrantByID(ctx context.Context, id string) (entity.OAuthGrant, error) {
+	var grant entity.OAuthGrant
+	err := o.db.WithContext(ctx).Where("id = ?", id).First(&grant).Error
+	return grant, translate(err, ErrOAuthGrantNotFound, nil)
+}
+
+// FindGrantByRefreshHash implements OAuthRepository.
+func (o *oauthRepository) FindGrantByRefreshHash(ctx context.Context, hash string) (entity.OAuthGrant, error) {
+	var grant entity.OAuthGrant
+	err := o.db.WithContext(ctx).Where("refresh_hash = ?", hash).First(&grant).Error
+	return grant, translate(err, ErrOAuthGrantNotFound, nil)
+}
+
+// RotateGrant implements OAuthRepository.
+func (o *oauthRepository) RotateGrant(ctx context.Context, id string, next entity.OAuthGrant) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.OAuthGrant{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOAuthGrantNotFound
+		}
+		return tx.Create(&next).Error
+	})
+}
+
+// RevokeGrant implements OAuthRepository.
+func (o *oauthRepository) RevokeGrant(ctx context.Context, id string) error {
+	return o.db.WithContext(ctx).Model(&entity.OAuthGrant{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{db: db}
+}
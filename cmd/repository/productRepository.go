@@ -1,51 +1,69 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
 
 	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
 	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository interface {
-	Create(payload entity.Product) (dto.ProductWithUsers, error)
-	FindByID(id uint) (dto.ProductWithUsers, error)
-	FindAll(page, size int) ([]dto.ProductWithUsers, model.Paging, error)
-	FindByStock(stock int) ([]dto.ProductWithUsers, error)
-	UpdateByID(id uint, payload entity.Product) (dto.ProductWithUsers, error)
-	DeleteByID(id uint) error
-	ProductExists(id uint) (bool, error)
+	Create(ctx context.Context, payload entity.Product) (dto.ProductWithUsers, error)
+	FindByID(ctx context.Context, id uint) (dto.ProductWithUsers, error)
+	FindAll(ctx context.Context, spec query.Spec) ([]dto.ProductWithUsers, model.Paging, error)
+	FindByStock(ctx context.Context, stock int) ([]dto.ProductWithUsers, error)
+	UpdateByID(ctx context.Context, id uint, changes map[string]interface{}) (dto.ProductWithUsers, error)
+	DeleteByID(ctx context.Context, id uint) error
+	ProductExists(ctx context.Context, id uint) (bool, error)
+	UpsertBatch(ctx context.Context, payloads []entity.Product) ([]UpsertResult, error)
+	Each(ctx context.Context, spec query.Spec, fn func(product entity.Product) error) error
 }
 
-type productRepository struct {
-	db *gorm.DB
+// UpsertResult reports what UpsertBatch did with one product, Before is the zero Product for a created one
+type UpsertResult struct {
+	ID      uint
+	Created bool
+	Before  entity.Product
+	After   entity.Product
 }
 
-// FindByStock implements ProductRepository.
-func (p *productRepository) FindByStock(stock int) ([]dto.ProductWithUsers, error) {
-	type result struct {
-		products []entity.Product
-		err      error
-	}
+// UpsertError is returned by UpsertBatch when writing one of the products rolled back the batch, Index is the
+// position of that product in the batch
+type UpsertError struct {
+	Index int
+	Err   error
+}
 
-	resultChan := make(chan result)
-	go func() {
-		var products []entity.Product
-		err := p.db.Where("stock = ?", stock).Preload("Users").Find(&products).Error
-		resultChan <- result{products, err}
-	}()
+func (e *UpsertError) Error() string {
+	return fmt.Sprintf("product %d of the batch: %v", e.Index, e.Err)
+}
+
+func (e *UpsertError) Unwrap() error {
+	return e.Err
+}
 
-	res := <-resultChan
-	if res.err != nil {
-		return nil, res.err
+type productRepository struct {
+	db            *gorm.DB
+	notifications service.NotificationService
+}
+
+// FindByStock implements ProductRepository.
+func (p *productRepository) FindByStock(ctx context.Context, stock int) ([]dto.ProductWithUsers, error) {
+	var products []entity.Product
+	if err := p.db.WithContext(ctx).Where("stock = ?", stock).Preload("Users").Find(&products).Error; err != nil {
+		return nil, err
 	}
 
-	responseProducts := make([]dto.ProductWithUsers, len(res.products))
-	for i, product := range res.products {
+	responseProducts := make([]dto.ProductWithUsers, len(products))
+	for i, product := range products {
 		responseProducts[i] = dto.ConvertProductToResponse(product)
 	}
 
@@ -53,173 +71,226 @@ func (p *productRepository) FindByStock(stock int) ([]dto.ProductWithUsers, erro
 }
 
 // Create implements ProductRepository.
-func (p *productRepository) Create(payload entity.Product) (dto.ProductWithUsers, error) {
-	type result struct {
-		product entity.Product
-		err     error
+func (p *productRepository) Create(ctx context.Context, payload entity.Product) (dto.ProductWithUsers, error) {
+	db := p.db.WithContext(ctx)
+	if err := db.Create(&payload).Error; err != nil {
+		return dto.ProductWithUsers{}, translate(err, nil, ErrSKUTaken)
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		if err := p.db.Create(&payload).Error; err != nil {
-			resultChan <- result{entity.Product{}, err}
-			return
-		}
-
-		var product entity.Product
-		err := p.db.Preload("Users").First(&product, payload.ID).Error
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		return dto.ProductWithUsers{}, res.err
+	var product entity.Product
+	if err := db.Preload("Users").First(&product, payload.ID).Error; err != nil {
+		return dto.ProductWithUsers{}, err
 	}
 
-	return dto.ConvertProductToResponse(res.product), nil
+	return dto.ConvertProductToResponse(product), nil
 }
 
 // DeleteByID implements ProductRepository.
-func (p *productRepository) DeleteByID(id uint) error {
-	type result struct {
-		err error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		err := p.db.Delete(&entity.Product{}, id).Error
-		resultChan <- result{err}
-	}()
+func (p *productRepository) DeleteByID(ctx context.Context, id uint) error {
+	return p.db.WithContext(ctx).Delete(&entity.Product{}, id).Error
+}
 
-	res := <-resultChan
-	return res.err
+// productListing knows the fields products can be filtered and sorted by
+var productListing = listing{
+	columns: map[string]string{
+		"id":         "products.id",
+		"sku":        "products.sku",
+		"name":       "products.name",
+		"category":   "products.category",
+		"stock":      "products.stock",
+		"created_at": "products.created_at",
+	},
+	search:   []string{"products.name", "products.sku", "products.description"},
+	tiebreak: "products.id",
 }
 
 // FindAll implements ProductRepository.
-func (p *productRepository) FindAll(page int, size int) ([]dto.ProductWithUsers, model.Paging, error) {
-	type result struct {
-		totalProducts int64
-		products      []entity.Product
-		err           error
+func (p *productRepository) FindAll(ctx context.Context, spec query.Spec) ([]dto.ProductWithUsers, model.Paging, error) {
+	var products []entity.Product
+	paging, err := productListing.page(p.db.WithContext(ctx).Model(&entity.Product{}), spec, &products, "Users")
+	if err != nil {
+		slog.ErrorContext(ctx, "productRepository.FindAll", "err", err)
+		return nil, model.Paging{}, err
+	}
+
+	responseProducts := make([]dto.ProductWithUsers, len(products))
+	for i, product := range products {
+		responseProducts[i] = dto.ConvertProductToResponse(product)
+	}
+	return responseProducts, paging, nil
+}
+
+// FindByID implements ProductRepository.
+func (p *productRepository) FindByID(ctx context.Context, id uint) (dto.ProductWithUsers, error) {
+	var product entity.Product
+	if err := p.db.WithContext(ctx).Preload("Users").First(&product, id).Error; err != nil {
+		return dto.ProductWithUsers{}, translate(err, ErrProductNotFound, nil)
 	}
 
-	offset := (page - 1) * size
-	resultChan := make(chan result)
+	return dto.ConvertProductToResponse(product), nil
+}
 
-	go func() {
-		var totalProducts int64
-		if err := p.db.Model(&entity.Product{}).Count(&totalProducts).Error; err != nil {
-			resultChan <- result{0, nil, err}
-			return
+// UpdateByID implements ProductRepository.
+func (p *productRepository) UpdateByID(ctx context.Context, id uint, changes map[string]interface{}) (dto.ProductWithUsers, error) {
+	db := p.db.WithContext(ctx)
+
+	var product entity.Product
+	var previousStock int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error; err != nil {
+			return err
 		}
-
-		var products []entity.Product
-		if err := p.db.Limit(size).Offset(offset).Preload("Users").Find(&products).Error; err != nil {
-			resultChan <- result{totalProducts, nil, err}
-			return
+		previousStock = product.Stock
+		if len(changes) == 0 {
+			return nil
 		}
+		return tx.Model(&product).Updates(changes).Error
+	})
+	if err != nil {
+		return dto.ProductWithUsers{}, translate(err, ErrProductNotFound, ErrSKUTaken)
+	}
 
-		resultChan <- result{totalProducts, products, nil}
-	}()
+	db.Preload("Users").First(&product, id)
 
-	res := <-resultChan
-	if res.err != nil {
-		log.Printf("productRepository.FindAll: Error: %v \n", res.err)
-		return nil, model.Paging{}, res.err
+	// A transition from sold out to available notifies everyone waiting for it
+	if previousStock <= 0 && product.Stock > 0 {
+		p.notifyBackInStock(context.WithoutCancel(ctx), product)
 	}
 
-	responseProducts := make([]dto.ProductWithUsers, len(res.products))
-	for i, product := range res.products {
-		responseProducts[i] = dto.ConvertProductToResponse(product)
-	}
+	return dto.ConvertProductToResponse(product), nil
+}
 
-	paging := model.Paging{
-		Page:        page,
-		RowsPerPage: size,
-		TotalRows:   int(res.totalProducts),
-		TotalPages:  int(math.Ceil(float64(res.totalProducts) / float64(size))),
+// notifyBackInStock enqueues one notification per wishlist subscriber of the product
+func (p *productRepository) notifyBackInStock(ctx context.Context, product entity.Product) {
+	var userIDs []uint
+	err := p.db.WithContext(ctx).Model(&entity.WishlistItem{}).Where("product_id = ?", product.ID).Pluck("user_id", &userIDs).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "productRepository.notifyBackInStock", "err", err)
+		return
 	}
 
-	return responseProducts, paging, nil
+	for _, userID := range userIDs {
+		p.notifications.Enqueue(service.Notification{
+			Key:     fmt.Sprintf("%s:%d:%d", service.NotificationBackInStock, product.ID, userID),
+			Kind:    service.NotificationBackInStock,
+			UserID:  userID,
+			Message: fmt.Sprintf("%s is back in stock", product.Name),
+			Data: map[string]interface{}{
+				"product_id": product.ID,
+				"stock":      product.Stock,
+			},
+		})
+	}
 }
 
-// FindByID implements ProductRepository.
-func (p *productRepository) FindByID(id uint) (dto.ProductWithUsers, error) {
-	type result struct {
-		product entity.Product
-		err     error
+func (p *productRepository) ProductExists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	if err := p.db.WithContext(ctx).Model(&entity.Product{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check if product exists: %w", err)
 	}
+	return count > 0, nil
+}
 
-	resultChan := make(chan result)
-	go func() {
-		var product entity.Product
-		err := p.db.Preload("Users").First(&product, id).Error
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		return dto.ProductWithUsers{}, res.err
+// UpsertBatch implements ProductRepository, products are matched by SKU, or by name when the row has no SKU,
+// and the whole batch is written in one transaction. A product that fails rolls back the batch with an
+// *UpsertError naming it.
+func (p *productRepository) UpsertBatch(ctx context.Context, payloads []entity.Product) ([]UpsertResult, error) {
+	var results []UpsertResult
+	var restocked []entity.Product
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		results = make([]UpsertResult, len(payloads))
+		restocked = nil
+		for i := range payloads {
+			result, err := p.upsert(tx, payloads[i], &restocked)
+			if err != nil {
+				return &UpsertError{Index: i, Err: translate(err, nil, ErrSKUTaken)}
+			}
+			results[i] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return dto.ConvertProductToResponse(res.product), nil
+	for _, product := range restocked {
+		p.notifyBackInStock(context.WithoutCancel(ctx), product)
+	}
+	return results, nil
 }
 
-// UpdateByID implements ProductRepository.
-func (p *productRepository) UpdateByID(id uint, payload entity.Product) (dto.ProductWithUsers, error) {
-	type result struct {
-		product entity.Product
-		err     error
+// upsert creates payload or updates the product it matches, restocked collects the updates that bring a product
+// back in stock
+func (p *productRepository) upsert(tx *gorm.DB, payload entity.Product, restocked *[]entity.Product) (UpsertResult, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if payload.SKU != nil {
+		query = query.Where("sku = ?", *payload.SKU)
+	} else {
+		query = query.Where("name = ?", payload.Name)
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		var product entity.Product
-		if err := p.db.First(&product, id).Error; err != nil {
-			resultChan <- result{entity.Product{}, err}
-			return
+	var existing entity.Product
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := tx.Omit(clause.Associations).Create(&payload).Error; err != nil {
+			return UpsertResult{}, err
 		}
-		if err := p.db.Model(&product).Updates(payload).Error; err != nil {
-			resultChan <- result{entity.Product{}, err}
-			return
-		}
-
-		p.db.Preload("Users").First(&product, id)
-		resultChan <- result{product, nil}
-	}()
-
-	res := <-resultChan
-	if res.err != nil {
-		return dto.ProductWithUsers{}, res.err
+		return UpsertResult{ID: payload.ID, Created: true, After: payload}, nil
+	}
+	if err != nil {
+		return UpsertResult{}, err
 	}
 
-	return dto.ConvertProductToResponse(res.product), nil
+	// Select writes zero values too, so a row can set the stock to 0
+	columns := []string{"name", "description", "category", "stock", "price_amount", "price_currency"}
+	if payload.SKU != nil {
+		columns = append(columns, "sku")
+	}
+	before, after := existing, existing
+	after.Name, after.Description, after.Category, after.Stock, after.Price = payload.Name, payload.Description, payload.Category, payload.Stock, payload.Price
+	if payload.SKU != nil {
+		after.SKU = payload.SKU
+	}
+	if err := tx.Model(&existing).Select(columns).Updates(&payload).Error; err != nil {
+		return UpsertResult{}, err
+	}
+	if before.Stock <= 0 && payload.Stock > 0 {
+		payload.ID = existing.ID
+		*restocked = append(*restocked, payload)
+	}
+	return UpsertResult{ID: existing.ID, Before: before, After: after}, nil
 }
 
-func (p *productRepository) ProductExists(id uint) (bool, error) {
-	// Channels for signaling completion and errors
-	existsCh := make(chan bool)
-	errCh := make(chan error, 1)
+// Each implements ProductRepository, it walks the products matching spec in its order with a database cursor so
+// only one row is held at a time. The page of spec is ignored.
+func (p *productRepository) Each(ctx context.Context, spec query.Spec, fn func(product entity.Product) error) error {
+	db := p.db.WithContext(ctx)
+	filtered, err := productListing.filter(db.Model(&entity.Product{}), spec)
+	if err != nil {
+		return err
+	}
+	ordered, err := productListing.order(filtered, spec)
+	if err != nil {
+		return err
+	}
+	rows, err := ordered.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	go func() {
-		var count int64
-		err := p.db.Model(&entity.Product{}).Where("id = ?", id).Count(&count).Error
-		if err != nil {
-			errCh <- err
-			return
+	for rows.Next() {
+		var product entity.Product
+		if err := db.ScanRows(rows, &product); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
 		}
-		existsCh <- count > 0
-	}()
-
-	// Wait for results or errors
-	select {
-	case exists := <-existsCh:
-		return exists, nil
-	case err := <-errCh:
-		return false, fmt.Errorf("failed to check if product exists: %v", err)
 	}
+	return rows.Err()
 }
 
-func NewProductRepository(db *gorm.DB) ProductRepository {
-	return &productRepository{db: db}
+func NewProductRepository(db *gorm.DB, notifications service.NotificationService) ProductRepository {
+	return &productRepository{db: db, notifications: notifications}
 }
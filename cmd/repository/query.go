@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"gorm.io/gorm"
+)
+
+// listing translates the fields of a query.Spec for one table. Only the fields listed here reach SQL:
+// columns can be filtered and sorted, scopes are filters that need more than a column comparison.
+type listing struct {
+	columns map[string]string
+	scopes  map[string]func(db *gorm.DB, condition query.Condition) *gorm.DB
+	// search lists the columns matched by Spec.Search
+	search []string
+	// tiebreak keeps the order of pages stable
+	tiebreak string
+}
+
+// filter applies the search and the conditions of spec
+func (l listing) filter(db *gorm.DB, spec query.Spec) (*gorm.DB, error) {
+	if search := strings.TrimSpace(spec.Search); search != "" && len(l.search) > 0 {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		clauses := make([]string, len(l.search))
+		args := make([]interface{}, len(l.search))
+		for i, column := range l.search {
+			clauses[i] = "LOWER(" + column + ") LIKE ? ESCAPE '!'"
+			args[i] = pattern
+		}
+		db = db.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+
+	for _, condition := range spec.Conditions {
+		if scope, ok := l.scopes[condition.Field]; ok {
+			db = scope(db, condition)
+			continue
+		}
+		column, ok := l.columns[condition.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter field %q", condition.Field)
+		}
+
+		switch condition.Op {
+		case query.Eq:
+			db = db.Where(column+" = ?", condition.Value)
+		case query.In:
+			db = db.Where(column+" IN ?", condition.Value)
+		case query.Gt:
+			db = db.Where(column+" > ?", condition.Value)
+		case query.Gte:
+			db = db.Where(column+" >= ?", condition.Value)
+		case query.Lt:
+			db = db.Where(column+" < ?", condition.Value)
+		case query.Lte:
+			db = db.Where(column+" <= ?", condition.Value)
+		case query.Null:
+			if isNull, _ := condition.Value.(bool); isNull {
+				db = db.Where(column + " IS NULL")
+			} else {
+				db = db.Where(column + " IS NOT NULL")
+			}
+		default:
+			return nil, fmt.Errorf("unknown filter operator %q", condition.Op)
+		}
+	}
+	return db, nil
+}
+
+// order applies the orders of spec followed by the tiebreak
+func (l listing) order(db *gorm.DB, spec query.Spec) (*gorm.DB, error) {
+	for _, order := range spec.Orders {
+		column, ok := l.columns[order.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", order.Field)
+		}
+		if order.Desc {
+			column += " DESC"
+		}
+		db = db.Order(column)
+	}
+	if l.tiebreak != "" {
+		db = db.Order(l.tiebreak)
+	}
+	return db, nil
+}
+
+// page counts the rows matching spec and loads its page into dest, preloading the given associations
+func (l listing) page(db *gorm.DB, spec query.Spec, dest interface{}, preloads ...string) (model.Paging, error) {
+	filtered, err := l.filter(db, spec)
+	if err != nil {
+		return model.Paging{}, err
+	}
+
+	// A new session lets the count and the page query share the filters without leaking into each other
+	filtered = filtered.Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Count(&total).Error; err != nil {
+		return model.Paging{}, err
+	}
+
+	ordered, err := l.order(filtered, spec)
+	if err != nil {
+		return model.Paging{}, err
+	}
+	for _, preload := range preloads {
+		ordered = ordered.Preload(preload)
+	}
+	if err := ordered.Limit(spec.Size).Offset(spec.Offset()).Find(dest).Error; err != nil {
+		return model.Paging{}, err
+	}
+
+	return model.Paging{
+		Page:        spec.Page,
+		RowsPerPage: spec.Size,
+		TotalRows:   int(total),
+		TotalPages:  int(math.Ceil(float64(total) / float64(spec.Size))),
+	}, nil
+}
+
+// escapeLike makes the LIKE wildcards of value match literally, '!' is the escape character
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	// Replace drops every recovery code of the user and stores the new hashes
+	Replace(ctx context.Context, userID uint, hashes []string) error
+	// Use marks the unused code with hash as used, false means there is no such code left
+	Use(ctx context.Context, userID uint, hash string) (bool, error)
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// Replace implements RecoveryCodeRepository.
+func (r *recoveryCodeRepository) Replace(ctx context.Context, userID uint, hashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		codes := make([]entity.RecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = entity.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Use implements RecoveryCodeRepository, the used_at condition lets only one of two concurrent uses win.
+func (r *recoveryCodeRepository) Use(ctx context.Context, userID uint, hash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, payload entity.Review) (entity.Review, error)
+	FindByID(ctx context.Context, id uint) (entity.Review, error)
+	FindByProduct(ctx context.Context, productID uint, includeHidden bool, page, size int) ([]entity.Review, model.Paging, error)
+	UpdateByID(ctx context.Context, id uint, payload entity.Review) (entity.Review, error)
+	DeleteByID(ctx context.Context, id uint) error
+	SetHidden(ctx context.Context, id uint, hidden bool) (entity.Review, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+// Create implements ReviewRepository.
+func (r *reviewRepository) Create(ctx context.Context, payload entity.Review) (entity.Review, error) {
+	db := r.db.WithContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrolled int64
+		err := tx.Model(&entity.Enrollment{}).Where("user_id = ? AND product_id = ?", payload.UserID, payload.ProductID).Count(&enrolled).Error
+		if err != nil {
+			return err
+		}
+		if enrolled == 0 {
+			return ErrNotEnrolled
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&payload).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyReviewed
+			}
+			return err
+		}
+		return adjustProductRating(tx, payload.ProductID, 1, payload.Rating)
+	})
+	if err != nil {
+		return entity.Review{}, err
+	}
+
+	var review entity.Review
+	err = db.Preload("User").First(&review, payload.ID).Error
+	return review, err
+}
+
+// FindByID implements ReviewRepository.
+func (r *reviewRepository) FindByID(ctx context.Context, id uint) (entity.Review, error) {
+	var review entity.Review
+	err := r.db.WithContext(ctx).Preload("User").First(&review, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = ErrReviewNotFound
+	}
+	return review, err
+}
+
+// FindByProduct implements ReviewRepository.
+func (r *reviewRepository) FindByProduct(ctx context.Context, productID uint, includeHidden bool, page, size int) ([]entity.Review, model.Paging, error) {
+	offset := (page - 1) * size
+
+	query := r.db.WithContext(ctx).Model(&entity.Review{}).Where("product_id = ?", productID)
+	if !includeHidden {
+		query = query.Where("hidden = ?", false)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var totalReviews int64
+	if err := query.Count(&totalReviews).Error; err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	var reviews []entity.Review
+	if err := query.Preload("User").Order("created_at DESC").Limit(size).Offset(offset).Find(&reviews).Error; err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	paging := model.Paging{
+		Page:        page,
+		RowsPerPage: size,
+		TotalRows:   int(totalReviews),
+		TotalPages:  int(math.Ceil(float64(totalReviews) / float64(size))),
+	}
+
+	return reviews, paging, nil
+}
+
+// UpdateByID implements ReviewRepository.
+func (r *reviewRepository) UpdateByID(ctx context.Context, id uint, payload entity.Review) (entity.Review, error) {
+	db := r.db.WithContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, id)
+		if err != nil {
+			return err
+		}
+		delta := payload.Rating - review.Rating
+
+		err = tx.Model(&review).Updates(map[string]interface{}{
+			"rating": payload.Rating,
+			"title":  payload.Title,
+			"body":   payload.Body,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if review.Hidden {
+			return nil
+		}
+		return adjustProductRating(tx, review.ProductID, 0, delta)
+	})
+	if err != nil {
+		return entity.Review{}, err
+	}
+
+	var review entity.Review
+	err = db.Preload("User").First(&review, id).Error
+	return review, err
+}
+
+// DeleteByID implements ReviewRepository.
+func (r *reviewRepository) DeleteByID(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&review).Error; err != nil {
+			return err
+		}
+
+		if review.Hidden {
+			return nil
+		}
+		return adjustProductRating(tx, review.ProductID, -1, -review.Rating)
+	})
+}
+
+// SetHidden implements ReviewRepository.
+func (r *reviewRepository) SetHidden(ctx context.Context, id uint, hidden bool) (entity.Review, error) {
+	db := r.db.WithContext(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, id)
+		if err != nil {
+			return err
+		}
+		if review.Hidden == hidden {
+			return nil
+		}
+
+		if err := tx.Model(&review).Update("hidden", hidden).Error; err != nil {
+			return err
+		}
+
+		// Hidden reviews do not count towards the product rating
+		if hidden {
+			return adjustProductRating(tx, review.ProductID, -1, -review.Rating)
+		}
+		return adjustProductRating(tx, review.ProductID, 1, review.Rating)
+	})
+	if err != nil {
+		return entity.Review{}, err
+	}
+
+	var review entity.Review
+	err = db.Preload("User").First(&review, id).Error
+	return review, err
+}
+
+func lockReview(tx *gorm.DB, id uint) (entity.Review, error) {
+	var review entity.Review
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Review{}, ErrReviewNotFound
+		}
+		return entity.Review{}, err
+	}
+	return review, nil
+}
+
+// adjustProductRating keeps the denormalized rating counters of a product in sync
+func adjustProductRating(tx *gorm.DB, productID uint, count, rating int) error {
+	if count == 0 && rating == 0 {
+		return nil
+	}
+	return tx.Model(&entity.Product{}).Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"review_count": gorm.Expr("review_count + ?", count),
+		"rating_total": gorm.Expr("rating_total + ?", rating),
+	}).Error
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
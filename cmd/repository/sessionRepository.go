@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, payload entity.Session) (entity.Session, error)
+	FindByID(ctx context.Context, id string) (entity.Session, error)
+	// FindActiveByUser returns the unrevoked, unexpired sessions of the current token version of the user, the
+	// most recently seen first
+	FindActiveByUser(ctx context.Context, userID uint) ([]entity.Session, error)
+	// Renew moves an unrevoked session to a new token version and expiry, and marks it as passed a second factor
+	// when twoFactor is set. False means it was revoked meanwhile.
+	Renew(ctx context.Context, id string, version uint, expiresAt time.Time, twoFactor bool) (bool, error)
+	// Touch records a request of the session unless one was recorded after notBefore, so a busy session is
+	// written at most once per interval
+	Touch(ctx context.Context, id, ip string, notBefore time.Time) error
+	// Revoke marks the unrevoked session revoked, false means there was none
+	Revoke(ctx context.Context, id string) (bool, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// Create implements SessionRepository.
+func (s *sessionRepository) Create(ctx context.Context, payload entity.Session) (entity.Session, error) {
+	err := s.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// FindByID implements SessionRepository.
+func (s *sessionRepository) FindByID(ctx context.Context, id string) (entity.Session, error) {
+	var session entity.Session
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&session).Error
+	return session, translate(err, ErrSessionNotFound, nil)
+}
+
+// FindActiveByUser implements SessionRepository.
+func (s *sessionRepository) FindActiveByUser(ctx context.Context, userID uint) ([]entity.Session, error) {
+	var sessions []entity.Session
+	db := s.db.WithContext(ctx)
+	version := db.Model(&entity.User{}).Select("token_version").Where("id = ?", userID)
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Where("token_version = (?)", version).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Renew implements SessionRepository.
+func (s *sessionRepository) Renew(ctx context.Context, id string, version uint, expiresAt time.Time, twoFactor bool) (bool, error) {
+	updates := map[string]interface{}{"token_version": version, "expires_at": expiresAt}
+	if twoFactor {
+		updates["two_factor"] = true
+	}
+	result := s.db.WithContext(ctx).Model(&entity.Session{}).Where("id = ? AND revoked_at IS NULL", id).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Touch implements SessionRepository.
+func (s *sessionRepository) Touch(ctx context.Context, id, ip string, notBefore time.Time) error {
+	return s.db.WithContext(ctx).Model(&entity.Session{}).Where("id = ? AND last_seen_at < ?", id, notBefore).
+		Updates(map[string]interface{}{"last_seen_at": time.Now(), "last_seen_ip": ip}).Error
+}
+
+// Revoke implements SessionRepository.
+func (s *sessionRepository) Revoke(ctx context.Context, id string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&entity.Session{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type UserIdentityRepository interface {
+	// Create links the identity, an identity already linked fails with ErrIdentityTaken
+	Create(ctx context.Context, payload entity.UserIdentity) (entity.UserIdentity, error)
+	FindBySubject(ctx context.Context, provider, subject string) (entity.UserIdentity, error)
+	// Touch records a login with the identity and the email the provider sent along
+	Touch(ctx context.Context, id uint, email string) error
+}
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+// Create implements UserIdentityRepository.
+func (u *userIdentityRepository) Create(ctx context.Context, payload entity.UserIdentity) (entity.UserIdentity, error) {
+	err := u.db.WithContext(ctx).Create(&payload).Error
+	return payload, translate(err, nil, ErrIdentityTaken)
+}
+
+// FindBySubject implements UserIdentityRepository.
+func (u *userIdentityRepository) FindBySubject(ctx context.Context, provider, subject string) (entity.UserIdentity, error) {
+	var identity entity.UserIdentity
+	err := u.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return identity, translate(err, ErrIdentityNotFound, nil)
+}
+
+// Touch implements UserIdentityRepository.
+func (u *userIdentityRepository) Touch(ctx context.Context, id uint, email string) error {
+	return u.db.WithContext(ctx).Model(&entity.UserIdentity{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"last_login_at": time.Now(), "email": email}).Error
+}
+
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
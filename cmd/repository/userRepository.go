@@ -1,56 +1,83 @@
 package repository
 
 import (
-	"log"
-	"math"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
 	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
-	Create(payload entity.User) (dto.UserWithProducts, error)
-	FindByID(id uint) (dto.UserWithProducts, error)
-	FindByEmail(email string) (dto.UserWithProducts, error)
-	FindAll(page, size int) ([]dto.UserWithProducts, model.Paging, error)
+	Create(ctx context.Context, payload entity.User) (dto.UserWithProducts, error)
+	FindByID(ctx context.Context, id uint) (dto.UserWithProducts, error)
+	FindByEmail(ctx context.Context, email string) (dto.UserWithProducts, error)
+	FindAll(ctx context.Context, spec query.Spec) ([]dto.UserWithProducts, model.Paging, error)
+	// CountMatching counts the users matching spec, leaving out exceptID
+	CountMatching(ctx context.Context, spec query.Spec, exceptID uint) (int64, error)
+	// UpdateMatching applies changes to the users matching spec except exceptID and revokes their tokens
+	UpdateMatching(ctx context.Context, spec query.Spec, exceptID uint, changes map[string]interface{}) (int64, error)
+	Each(ctx context.Context, spec query.Spec, fn func(user entity.User) error) error
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	UpdateTOTPSecret(ctx context.Context, id uint, secret string) error
+	// EnableTwoFactor turns 2FA on and records step as the last used TOTP step
+	EnableTwoFactor(ctx context.Context, id uint, step int64) error
+	// UseTOTPStep records step as used, false means a code of that step or a later one was already used
+	UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	// MarkEmailVerified verifies email for the user, it fails with ErrUserNotFound when the user has another email by now
+	MarkEmailVerified(ctx context.Context, id uint, email string) error
+	// UpdateByID applies changes keyed by column and returns the updated user
+	UpdateByID(ctx context.Context, id uint, changes map[string]interface{}) (dto.UserWithProducts, error)
+	// RevokeTokens raises the token version so every token issued before is rejected
+	RevokeTokens(ctx context.Context, id uint) error
+	// FindTokenState loads only what is needed to accept a token of the user
+	FindTokenState(ctx context.Context, id uint) (entity.User, error)
+	// Anonymize replaces the personal data of the user, drops their credentials and soft deletes them, the
+	// erasure record is stored in the same transaction
+	Anonymize(ctx context.Context, id uint, record entity.ErasureRecord) error
 }
 
 type userRepository struct {
 	db *gorm.DB
 }
 
+// userListing knows the fields users can be filtered and sorted by
+var userListing = listing{
+	columns: map[string]string{
+		"id":                "users.id",
+		"email":             "users.email",
+		"firstname":         "users.firstname",
+		"lastname":          "users.lastname",
+		"role":              "users.role",
+		"created_at":        "users.created_at",
+		"deactivated_at":    "users.deactivated_at",
+		"email_verified_at": "users.email_verified_at",
+	},
+	scopes: map[string]func(db *gorm.DB, condition query.Condition) *gorm.DB{
+		"has_products": func(db *gorm.DB, condition query.Condition) *gorm.DB {
+			exists := "EXISTS (SELECT 1 FROM enrollments WHERE enrollments.user_id = users.id)"
+			if has, _ := condition.Value.(bool); !has {
+				exists = "NOT " + exists
+			}
+			return db.Where(exists)
+		},
+	},
+	search:   []string{"users.email", "users.firstname", "users.lastname"},
+	tiebreak: "users.id",
+}
+
 // FindAll implements UserRepository.
-func (u *userRepository) FindAll(page, size int) ([]dto.UserWithProducts, model.Paging, error) {
+func (u *userRepository) FindAll(ctx context.Context, spec query.Spec) ([]dto.UserWithProducts, model.Paging, error) {
 	var users []entity.User
-	offset := (page - 1) * size
-
-	type result struct {
-		totalUsers int64
-		err        error
-	}
-
-	resultChan := make(chan result)
-
-	go func() {
-		// Retrieve total count of users
-		var totalUsers int64
-		err := u.db.Model(&entity.User{}).Count(&totalUsers).Error
-		resultChan <- result{totalUsers, err}
-	}()
-
-	var totalUsers int64
-	res := <-resultChan
-	if res.err != nil {
-		log.Printf("userRepository.FindAll: Error counting users: %v \n", res.err)
-		return nil, model.Paging{}, res.err
-	}
-	totalUsers = res.totalUsers
-
-	// Retrieve paginated users
-	if err := u.db.Limit(size).Offset(offset).Preload("Products").Find(&users).Error; err != nil {
-		log.Printf("userRepository.FindAll: Error fetching users: %v \n", err)
+	paging, err := userListing.page(u.db.WithContext(ctx).Model(&entity.User{}), spec, &users, "Products")
+	if err != nil {
+		slog.ErrorContext(ctx, "userRepository.FindAll", "err", err)
 		return nil, model.Paging{}, err
 	}
 
@@ -58,77 +85,230 @@ func (u *userRepository) FindAll(page, size int) ([]dto.UserWithProducts, model.
 	for i, user := range users {
 		responseUsers[i] = dto.ConvertUserToResponse(user)
 	}
+	return responseUsers, paging, nil
+}
 
-	paging := model.Paging{
-		Page:        page,
-		RowsPerPage: size,
-		TotalRows:   int(totalUsers),
-		TotalPages:  int(math.Ceil(float64(totalUsers) / float64(size))),
+// CountMatching implements UserRepository.
+func (u *userRepository) CountMatching(ctx context.Context, spec query.Spec, exceptID uint) (int64, error) {
+	filtered, err := userListing.filter(u.db.WithContext(ctx).Model(&entity.User{}), spec)
+	if err != nil {
+		return 0, err
 	}
 
-	return responseUsers, paging, nil
+	var total int64
+	err = filtered.Where("users.id <> ?", exceptID).Count(&total).Error
+	return total, err
 }
 
-func (u *userRepository) Create(payload entity.User) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+// UpdateMatching implements UserRepository.
+func (u *userRepository) UpdateMatching(ctx context.Context, spec query.Spec, exceptID uint, changes map[string]interface{}) (int64, error) {
+	filtered, err := userListing.filter(u.db.WithContext(ctx).Model(&entity.User{}), spec)
+	if err != nil {
+		return 0, err
 	}
 
-	resultChan := make(chan result)
+	columns := map[string]interface{}{"token_version": gorm.Expr("token_version + 1")}
+	for column, value := range changes {
+		columns[column] = value
+	}
+	result := filtered.Where("users.id <> ?", exceptID).Updates(columns)
+	return result.RowsAffected, result.Error
+}
 
-	go func() {
-		if err := u.db.Create(&payload).Error; err != nil {
-			resultChan <- result{dto.UserWithProducts{}, err}
-			return
-		}
-		resultChan <- result{dto.ConvertUserToResponse(payload), nil}
-	}()
+func (u *userRepository) Create(ctx context.Context, payload entity.User) (dto.UserWithProducts, error) {
+	if err := u.db.WithContext(ctx).Create(&payload).Error; err != nil {
+		return dto.UserWithProducts{}, translate(err, nil, ErrEmailTaken)
+	}
+	return dto.ConvertUserToResponse(payload), nil
+}
 
-	res := <-resultChan
-	return res.user, res.err
+func (u *userRepository) FindByID(ctx context.Context, id uint) (dto.UserWithProducts, error) {
+	var user entity.User
+	if err := u.db.WithContext(ctx).Preload("Products").First(&user, id).Error; err != nil {
+		return dto.UserWithProducts{}, translate(err, ErrUserNotFound, nil)
+	}
+	return dto.ConvertUserToResponse(user), nil
 }
 
-func (u *userRepository) FindByID(id uint) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+func (u *userRepository) FindByEmail(ctx context.Context, email string) (dto.UserWithProducts, error) {
+	var user entity.User
+	if err := u.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return dto.UserWithProducts{}, translate(err, ErrUserNotFound, nil)
 	}
+	return dto.ConvertUserToResponse(user), nil
+}
 
-	resultChan := make(chan result)
+// Each implements UserRepository, it walks the users matching spec in its order with a database cursor and never
+// selects the password column. The page of spec is ignored.
+func (u *userRepository) Each(ctx context.Context, spec query.Spec, fn func(user entity.User) error) error {
+	db := u.db.WithContext(ctx)
+	filtered, err := userListing.filter(db.Model(&entity.User{}).Omit("password"), spec)
+	if err != nil {
+		return err
+	}
+	ordered, err := userListing.order(filtered, spec)
+	if err != nil {
+		return err
+	}
+	rows, err := ordered.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	go func() {
+	for rows.Next() {
 		var user entity.User
-		if err := u.db.Preload("Products").First(&user, id).Error; err != nil {
-			resultChan <- result{dto.UserWithProducts{}, err}
-			return
+		if err := db.ScanRows(rows, &user); err != nil {
+			return err
 		}
-		resultChan <- result{dto.ConvertUserToResponse(user), nil}
-	}()
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// UpdatePassword implements UserRepository.
+func (u *userRepository) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	result := u.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Update("password", hash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
 
-	res := <-resultChan
-	return res.user, res.err
+// UpdateTOTPSecret implements UserRepository.
+func (u *userRepository) UpdateTOTPSecret(ctx context.Context, id uint, secret string) error {
+	return u.updateColumns(ctx, id, map[string]interface{}{"totp_secret": secret})
 }
 
-func (u *userRepository) FindByEmail(email string) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+// EnableTwoFactor implements UserRepository.
+func (u *userRepository) EnableTwoFactor(ctx context.Context, id uint, step int64) error {
+	return u.updateColumns(ctx, id, map[string]interface{}{"two_factor_enabled": true, "totp_last_step": step})
+}
+
+// UseTOTPStep implements UserRepository, the condition on the last step makes concurrent replays fail too.
+func (u *userRepository) UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := u.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkEmailVerified implements UserRepository, an email verified before keeps its original time.
+func (u *userRepository) MarkEmailVerified(ctx context.Context, id uint, email string) error {
+	var user entity.User
+	err := u.db.WithContext(ctx).Select("id", "email_verified_at").Where("id = ? AND email = ?", id, email).First(&user).Error
+	if err != nil {
+		return translate(err, ErrUserNotFound, nil)
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return u.updateColumns(ctx, id, map[string]interface{}{"email_verified_at": time.Now()})
+}
+
+// UpdateByID implements UserRepository.
+func (u *userRepository) UpdateByID(ctx context.Context, id uint, changes map[string]interface{}) (dto.UserWithProducts, error) {
+	if len(changes) > 0 {
+		err := u.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(changes).Error
+		if err != nil {
+			return dto.UserWithProducts{}, translate(err, nil, ErrEmailTaken)
+		}
 	}
+	return u.FindByID(ctx, id)
+}
 
-	resultChan := make(chan result)
+// RevokeTokens implements UserRepository.
+func (u *userRepository) RevokeTokens(ctx context.Context, id uint) error {
+	return u.updateColumns(ctx, id, map[string]interface{}{"token_version": gorm.Expr("token_version + 1")})
+}
 
-	go func() {
+// FindTokenState implements UserRepository.
+func (u *userRepository) FindTokenState(ctx context.Context, id uint) (entity.User, error) {
+	var user entity.User
+	err := u.db.WithContext(ctx).Select("id", "token_version", "deactivated_at").First(&user, id).Error
+	return user, translate(err, ErrUserNotFound, nil)
+}
+
+// Anonymize implements UserRepository. Orders, coupon redemptions and reviews stay for the records, they now
+// point to an anonymous user; the wishlist, recovery codes, mailed tokens, data exports, API keys, OAuth clients
+// and grants, linked identities and sessions are removed. Audit entries naming the email name the subject of the
+// erasure record instead.
+func (u *userRepository) Anonymize(ctx context.Context, id uint, record entity.ErasureRecord) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var user entity.User
-		if err := u.db.Where("email = ?", email).First(&user).Error; err != nil {
-			resultChan <- result{dto.UserWithProducts{}, err}
-			return
+		if err := tx.Select("id", "email").First(&user, id).Error; err != nil {
+			return translate(err, ErrUserNotFound, nil)
+		}
+
+		err := tx.Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"firstname":          "Deleted",
+			"lastname":           "User",
+			"email":              fmt.Sprintf("deleted-%d@deleted.invalid", id),
+			"password":           "",
+			"totp_secret":        "",
+			"two_factor_enabled": false,
+			"email_verified_at":  nil,
+			"token_version":      gorm.Expr("token_version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// Tokens other users granted to the OAuth clients of the user die with the clients
+		clients := tx.Model(&entity.OAuthClient{}).Select("id").Where("user_id = ?", id)
+		err = tx.Model(&entity.OAuthGrant{}).Where("client_id IN (?) AND revoked_at IS NULL", clients).Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&entity.WishlistItem{}, &entity.RecoveryCode{}, &entity.UserToken{}, &entity.DataExport{},
+			&entity.APIKey{}, &entity.OAuthClient{}, &entity.OAuthCode{}, &entity.OAuthGrant{}, &entity.UserIdentity{}, &entity.Session{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where(map[string]interface{}{"key": "email:" + strings.ToLower(user.Email)}).Delete(&entity.LoginAttempt{}).Error; err != nil {
+			return err
+		}
+		// The one change audit entries allow, UpdateColumn skips the hook that refuses every other. The record
+		// keeps the targets it erased, verifying the chain accepts the subject only in their place.
+		var targets []string
+		err = tx.Model(&entity.AuditLog{}).Where("LOWER(target_id) = ?", strings.ToLower(user.Email)).
+			Distinct().Pluck("target_id", &targets).Error
+		if err != nil {
+			return err
+		}
+		for _, target := range targets {
+			record.AddTarget(target)
+		}
+		err = tx.Model(&entity.AuditLog{}).Where("LOWER(target_id) = ?", strings.ToLower(user.Email)).
+			UpdateColumn("target_id", entity.AuditErasedPrefix+record.Subject).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.User{}, id).Error; err != nil {
+			return err
 		}
-		resultChan <- result{dto.ConvertUserToResponse(user), nil}
-	}()
 
-	res := <-resultChan
-	return res.user, res.err
+		record.UserID = id
+		return tx.Create(&record).Error
+	})
+}
+
+func (u *userRepository) updateColumns(ctx context.Context, id uint, columns map[string]interface{}) error {
+	result := u.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
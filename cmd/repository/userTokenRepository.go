@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, payload entity.UserToken) (entity.UserToken, error)
+	// Use marks the unexpired, unused token as used, false means it can not be used (anymore)
+	Use(ctx context.Context, id, purpose string) (bool, error)
+	// RevokeAll marks every unused token of the user for purpose as used
+	RevokeAll(ctx context.Context, userID uint, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+// Create implements UserTokenRepository.
+func (u *userTokenRepository) Create(ctx context.Context, payload entity.UserToken) (entity.UserToken, error) {
+	err := u.db.WithContext(ctx).Create(&payload).Error
+	return payload, err
+}
+
+// Use implements UserTokenRepository, the used_at condition lets only one of two concurrent uses win.
+func (u *userTokenRepository) Use(ctx context.Context, id, purpose string) (bool, error) {
+	now := time.Now()
+	result := u.db.WithContext(ctx).Model(&entity.UserToken{}).
+		Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, now).
+		Update("used_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAll implements UserTokenRepository.
+func (u *userTokenRepository) RevokeAll(ctx context.Context, userID uint, purpose string) error {
+	return u.db.WithContext(ctx).Model(&entity.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	FindByUser(ctx context.Context, userID uint) ([]entity.WishlistItem, error)
+	Add(ctx context.Context, userID, productID uint) (entity.WishlistItem, error)
+	Remove(ctx context.Context, userID, productID uint) error
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+// FindByUser implements WishlistRepository.
+func (w *wishlistRepository) FindByUser(ctx context.Context, userID uint) ([]entity.WishlistItem, error) {
+	var items []entity.WishlistItem
+	err := w.db.WithContext(ctx).Joins("Product").Where("wishlist_items.user_id = ?", userID).
+		Order("wishlist_items.created_at DESC").Find(&items).Error
+	return items, err
+}
+
+// Add implements WishlistRepository, adding a product twice keeps the first entry.
+func (w *wishlistRepository) Add(ctx context.Context, userID, productID uint) (entity.WishlistItem, error) {
+	db := w.db.WithContext(ctx)
+
+	var product entity.Product
+	if err := db.Select("id").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrProductNotFound
+		}
+		return entity.WishlistItem{}, err
+	}
+
+	item := entity.WishlistItem{UserID: userID, ProductID: productID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&item).Error; err != nil {
+		return entity.WishlistItem{}, err
+	}
+
+	var saved entity.WishlistItem
+	err := db.Joins("Product").Where("wishlist_items.user_id = ? AND wishlist_items.product_id = ?", userID, productID).
+		First(&saved).Error
+	return saved, err
+}
+
+// Remove implements WishlistRepository.
+func (w *wishlistRepository) Remove(ctx context.Context, userID, productID uint) error {
+	res := w.db.WithContext(ctx).Where("user_id = ? AND product_id = ?", userID, productID).Delete(&entity.WishlistItem{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrWishlistItemNotFound
+	}
+	return res.Error
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
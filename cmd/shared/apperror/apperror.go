@@ -0,0 +1,117 @@
+package apperror
+
+import (
+	"errors"
+	"time"
+)
+
+// Kind classifies a domain error, the delivery layer picks the response status from it
+type Kind string
+
+const (
+	KindInternal        Kind = "internal"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindValidation      Kind = "validation"
+	KindForbidden       Kind = "forbidden"
+	KindUnauthenticated Kind = "unauthenticated"
+	KindTooManyRequests Kind = "too_many_requests"
+)
+
+// FieldError describes why one field of the request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an error whose message is safe to show to clients. Internal errors keep the cause
+// for the logs and only ever expose a generic message.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	// RetryAfter tells throttled clients when to come back
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func Validation(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+// InvalidFields is a validation error that lists every rejected field
+func InvalidFields(message string, fields []FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func Unauthenticated(message string) *Error {
+	return &Error{Kind: KindUnauthenticated, Message: message}
+}
+
+func TooManyRequests(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindTooManyRequests, Message: message, RetryAfter: retryAfter}
+}
+
+// Internal wraps an unexpected error, err is logged but never sent to the client
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: err}
+}
+
+// KindOf returns the kind of the first domain error in the chain, errors without one are internal
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return KindInternal
+}
+
+// FieldsOf returns the rejected fields of the first domain error in the chain
+func FieldsOf(err error) []FieldError {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// RetryAfterOf returns how long a throttled client should wait, zero when err is not throttling
+func RetryAfterOf(err error) time.Duration {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.RetryAfter
+	}
+	return 0
+}
+
+// Message returns the text a client may see for err, internal errors are replaced by a generic message
+func Message(err error) string {
+	if KindOf(err) == KindInternal {
+		return Internal(err).Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,38 @@
+package common
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/altsaqif/go-rest/cmd/shared/export"
+	"github.com/gin-gonic/gin"
+)
+
+// SendExportResponse streams rows as a file attachment in the given format. An error raised before any byte
+// reached the client is returned so the caller can still send an error response, later errors end the stream.
+func SendExportResponse(ctx *gin.Context, format, name string, columns []string, write func(w export.Writer) error) error {
+	ctx.Header("Content-Type", export.ContentType(format))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	ctx.Status(http.StatusOK)
+
+	writer, err := export.NewWriter(format, ctx.Writer, columns)
+	if err == nil {
+		err = write(writer)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return nil
+	}
+
+	if !ctx.Writer.Written() {
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		return err
+	}
+	slog.ErrorContext(ctx.Request.Context(), "SendExportResponse: export aborted", "export", name, "err", err)
+	ctx.Abort()
+	return nil
+}
@@ -3,8 +3,11 @@
 package common
 
 import (
+	"mime"
 	"net/http"
+	"strings"
 
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
 	"github.com/altsaqif/go-rest/cmd/shared/model"
 	"github.com/gin-gonic/gin"
 )
@@ -20,6 +23,17 @@ func SendCreateResponse(ctx *gin.Context, message string, data interface{}) {
 	})
 }
 
+// SendAcceptedResponse defines the standard response structure for work that continues in the background
+func SendAcceptedResponse(ctx *gin.Context, message string, data interface{}) {
+	ctx.JSON(http.StatusAccepted, &model.SingleResponse{
+		Status: model.Status{
+			Code:    http.StatusAccepted,
+			Message: message,
+		},
+		Data: data,
+	})
+}
+
 // SendSuccessResponse defines the standard success response structure
 func SendSuccessResponse(ctx *gin.Context, data interface{}) {
 	ctx.JSON(http.StatusOK, &model.SingleResponse{
@@ -54,10 +68,47 @@ func SendPagedResponse(ctx *gin.Context, data []interface{}, paging model.Paging
 	})
 }
 
+// ProblemContentType is the RFC 7807 media type clients ask for through the Accept header
+const ProblemContentType = "application/problem+json"
+
 // SendErrorResponse defines the standard error response structure
 func SendErrorResponse(ctx *gin.Context, code int, message string) {
-	ctx.AbortWithStatusJSON(code, &model.Status{
-		Code:    code,
-		Message: message,
+	SendFieldErrorResponse(ctx, code, message, nil)
+}
+
+// SendFieldErrorResponse sends an error together with the rejected fields, clients accepting
+// application/problem+json get an RFC 7807 problem, everyone else the standard envelope
+func SendFieldErrorResponse(ctx *gin.Context, code int, message string, fields []apperror.FieldError) {
+	if !acceptsProblem(ctx) {
+		ctx.AbortWithStatusJSON(code, &model.Status{
+			Code:    code,
+			Message: message,
+		})
+		return
+	}
+
+	title := http.StatusText(code)
+	if title == "" {
+		title = "Error"
+	}
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(code, &model.Problem{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    code,
+		Detail:    message,
+		Instance:  ctx.Request.URL.Path,
+		RequestID: RequestID(ctx),
+		Errors:    fields,
 	})
 }
+
+func acceptsProblem(ctx *gin.Context) bool {
+	for _, accept := range strings.Split(ctx.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == ProblemContentType && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+// shared/common/requestID.go
+
+package common
+
+import "github.com/gin-gonic/gin"
+
+// RequestIDHeader carries the request ID between clients, the API and the logs
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "requestID"
+
+// SetRequestID stores the request ID for the rest of the handler chain
+func SetRequestID(ctx *gin.Context, id string) {
+	ctx.Set(requestIDKey, id)
+}
+
+// RequestID returns the ID assigned by the RequestID middleware, empty when it did not run
+func RequestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
@@ -0,0 +1,181 @@
+// shared/common/validation.go
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
+)
+
+// ValidationRule is a custom binding rule together with its message per locale, "{0}" is the field name
+type ValidationRule struct {
+	Tag      string
+	Func     validator.Func
+	Messages map[string]string
+}
+
+// validationMessages holds the messages BindError needs besides the validator translations
+var validationMessages = map[string]map[string]string{
+	"en": {
+		"type":    "{0} must be of type {1}",
+		"invalid": "{0} is invalid",
+	},
+	"id": {
+		"type":    "{0} harus bertipe {1}",
+		"invalid": "{0} tidak valid",
+	},
+}
+
+var translators *ut.UniversalTranslator
+
+// SetupValidator makes the binding validator report fields by their json name, registers the custom
+// rules and loads the English and Indonesian messages. It has to run before the first request is bound.
+func SetupValidator(rules ...ValidationRule) error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unsupported binding validator %T", binding.Validator.Engine())
+	}
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	english := en.New()
+	translators = ut.New(english, english, id.New())
+	defaults := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"id": idTranslations.RegisterDefaultTranslations,
+	}
+	for locale, register := range defaults {
+		trans, _ := translators.GetTranslator(locale)
+		if err := register(validate, trans); err != nil {
+			return err
+		}
+		for key, message := range validationMessages[locale] {
+			if err := trans.Add(key, message, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		rule := rule
+		if err := validate.RegisterValidation(rule.Tag, rule.Func); err != nil {
+			return err
+		}
+		for locale := range defaults {
+			message, ok := rule.Messages[locale]
+			if !ok {
+				message = rule.Messages["en"]
+			}
+			trans, _ := translators.GetTranslator(locale)
+			err := validate.RegisterTranslation(rule.Tag, trans, func(trans ut.Translator) error {
+				return trans.Add(rule.Tag, message, true)
+			}, func(trans ut.Translator, fe validator.FieldError) string {
+				text, _ := trans.T(rule.Tag, fe.Field())
+				return text
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BindError turns an error from ShouldBind into a validation error listing every rejected field, the
+// messages follow the Accept-Language header of the request
+func BindError(ctx *gin.Context, err error) error {
+	trans := translator(ctx)
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]apperror.FieldError, 0, len(validationErrs))
+		messages := make([]string, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			field := fieldName(fe)
+			message := fieldMessage(trans, fe)
+			fields = append(fields, apperror.FieldError{Field: field, Rule: fe.Tag(), Message: message})
+			messages = append(messages, message)
+		}
+		return apperror.InvalidFields(strings.Join(messages, "; "), fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		message := translate(trans, "type", typeErr.Field, typeErr.Type.String())
+		return apperror.InvalidFields(message, []apperror.FieldError{
+			{Field: typeErr.Field, Rule: "type", Message: message},
+		})
+	}
+
+	return apperror.Validation(err.Error())
+}
+
+// translator picks the first supported language of the Accept-Language header, English otherwise
+func translator(ctx *gin.Context) ut.Translator {
+	if translators == nil {
+		return nil
+	}
+
+	var locales []string
+	for _, language := range strings.Split(ctx.GetHeader("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(language), ";")
+		base, _, _ := strings.Cut(tag, "-")
+		if base != "" {
+			locales = append(locales, strings.ToLower(base))
+		}
+	}
+	trans, _ := translators.FindTranslator(locales...)
+	return trans
+}
+
+func translate(trans ut.Translator, key string, params ...string) string {
+	if trans != nil {
+		if text, err := trans.T(key, params...); err == nil {
+			return text
+		}
+	}
+	text := validationMessages["en"][key]
+	for i, param := range params {
+		text = strings.ReplaceAll(text, fmt.Sprintf("{%d}", i), param)
+	}
+	return text
+}
+
+func fieldMessage(trans ut.Translator, fe validator.FieldError) string {
+	if trans != nil {
+		if text := fe.Translate(trans); text != fe.Error() {
+			return text
+		}
+	}
+	return translate(trans, "invalid", fe.Field())
+}
+
+// fieldName drops the root struct from the namespace so nested fields read like "items[0].sku"
+func fieldName(fe validator.FieldError) string {
+	if _, field, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return field
+	}
+	return fe.Field()
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+	FormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Writer streams a table row by row, nothing but the current row is kept in memory
+type Writer interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Negotiate returns the format named by the format query parameter, or else the one requested in Accept.
+// It returns an empty string when neither names a supported format.
+func Negotiate(format, accept string) string {
+	switch strings.ToLower(format) {
+	case FormatCSV, FormatXLSX:
+		return strings.ToLower(format)
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON
+	case "":
+		return FormatFromAccept(accept)
+	default:
+		return ""
+	}
+}
+
+// FormatFromAccept picks the first supported format in an Accept header, CSV when any type is accepted
+func FormatFromAccept(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return FormatCSV
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/csv", "application/csv":
+			return FormatCSV
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return FormatNDJSON
+		case contentTypes[FormatXLSX]:
+			return FormatXLSX
+		case "*/*", "text/*":
+			return FormatCSV
+		}
+	}
+	return ""
+}
+
+// NewWriter creates a Writer for the format, columns name the values passed to WriteRow
+func NewWriter(format string, w io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCsvWriter(w, columns)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case FormatXLSX:
+		return newXlsxWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCsvWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w)}
+	if err := c.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = text(value)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per row with keys in column order
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (n *ndjsonWriter) WriteRow(values ...interface{}) error {
+	n.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(n.columns[i])
+		n.w.Write(key)
+		n.w.WriteByte(':')
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		n.w.Write(encoded)
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+// text formats a cell value for text based formats
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case int:
+		return strconv.Itoa(v)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
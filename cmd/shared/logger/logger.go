@@ -0,0 +1,125 @@
+// cmd/shared/logger/logger.go
+
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/shared/requestinfo"
+)
+
+// Redacted replaces the values of attributes that carry secrets
+const Redacted = "[redacted]"
+
+// secretKeys are matched against the lowercased attribute keys, a key containing one of them is redacted
+var secretKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+
+// New returns a logger writing JSON or text records to w. Records below the level of the package that logged
+// them are dropped, records logged with a request context carry its request ID and user, and secrets are
+// redacted.
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	// The inner handler writes everything, packageHandler decides which records reach it
+	options := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redact}
+	var inner slog.Handler = slog.NewJSONHandler(w, options)
+	if cfg.LogFormat == "text" {
+		inner = slog.NewTextHandler(w, options)
+	}
+
+	minLevel := cfg.LogLevel
+	for _, level := range cfg.PackageLevels {
+		minLevel = min(minLevel, level)
+	}
+	return slog.New(&packageHandler{
+		inner:    inner,
+		levels:   &levels{fallback: cfg.LogLevel, packages: cfg.PackageLevels},
+		minLevel: minLevel,
+	})
+}
+
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(attr.Key, Redacted)
+		}
+	}
+	return attr
+}
+
+// levels resolves the level of the package that logged a record from its program counter
+type levels struct {
+	fallback slog.Level
+	packages map[string]slog.Level
+	// byPC caches the level of each call site
+	byPC sync.Map
+}
+
+func (l *levels) of(pc uintptr) slog.Level {
+	if pc == 0 || len(l.packages) == 0 {
+		return l.fallback
+	}
+	if level, ok := l.byPC.Load(pc); ok {
+		return level.(slog.Level)
+	}
+
+	level := l.fallback
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if packageLevel, ok := l.packages[packageName(frame.Function)]; ok {
+		level = packageLevel
+	}
+	l.byPC.Store(pc, level)
+	return level
+}
+
+// packageName returns the last element of the package path of a function name such as
+// github.com/altsaqif/go-rest/cmd/usecase.(*authUseCase).Login
+func packageName(function string) string {
+	name := function[strings.LastIndex(function, "/")+1:]
+	if dot := strings.Index(name, "."); dot >= 0 {
+		name = name[:dot]
+	}
+	return name
+}
+
+type packageHandler struct {
+	inner  slog.Handler
+	levels *levels
+	// minLevel is the lowest level of any package, records below it are dropped before they are built
+	minLevel slog.Level
+}
+
+func (h *packageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.minLevel
+}
+
+func (h *packageHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < h.levels.of(record.PC) {
+		return nil
+	}
+
+	info := requestinfo.FromContext(ctx)
+	if info.RequestID != "" {
+		record.AddAttrs(slog.String("request_id", info.RequestID))
+	}
+	if info.UserID != 0 {
+		record.AddAttrs(slog.Uint64("user_id", uint64(info.UserID)))
+	}
+	if info.ImpersonatorID != 0 {
+		record.AddAttrs(slog.Uint64("impersonator_id", uint64(info.ImpersonatorID)))
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &packageHandler{inner: h.inner.WithAttrs(attrs), levels: h.levels, minLevel: h.minLevel}
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	return &packageHandler{inner: h.inner.WithGroup(name), levels: h.levels, minLevel: h.minLevel}
+}
@@ -0,0 +1,82 @@
+// cmd/shared/mail/mail.go
+
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+)
+
+// Message is a rendered mail with a plain text and an HTML body
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers a message through a concrete channel
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Mailer renders templated messages and delivers them in the background
+type Mailer interface {
+	// Send renders the named template for to, delivery failures are only logged
+	Send(to, template string, data map[string]interface{}) error
+}
+
+type mailer struct {
+	sender Sender
+	from   string
+	queue  chan Message
+}
+
+// Send implements Mailer.
+func (m *mailer) Send(to, template string, data map[string]interface{}) error {
+	message, err := render(template, data)
+	if err != nil {
+		return err
+	}
+	message.From = m.from
+	message.To = to
+
+	select {
+	case m.queue <- message:
+		return nil
+	default:
+		return fmt.Errorf("mail queue is full, dropping %s mail", template)
+	}
+}
+
+func (m *mailer) run() {
+	for message := range m.queue {
+		if err := m.sender.Send(context.Background(), message); err != nil {
+			slog.Error("mailer: failed to deliver", "subject", message.Subject, "err", err)
+		}
+	}
+}
+
+// NewSender selects the sender configured by MAIL_DRIVER
+func NewSender(cfg config.MailConfig) (Sender, error) {
+	switch cfg.MailDriver {
+	case "", "log":
+		return &logSender{}, nil
+	case "file":
+		return newFileSender(cfg.FileDir)
+	case "smtp":
+		return newSMTPSender(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+}
+
+func NewMailer(sender Sender, cfg config.MailConfig) Mailer {
+	m := &mailer{sender: sender, from: cfg.From, queue: make(chan Message, cfg.QueueSize)}
+	go m.run()
+	return m
+}
@@ -0,0 +1,132 @@
+// cmd/shared/mail/sender.go
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+)
+
+// logSender writes mails to the application log, it is meant for development
+type logSender struct{}
+
+func (l *logSender) Send(ctx context.Context, message Message) error {
+	slog.Info("Mail", "to", message.To, "subject", message.Subject, "body", message.Text)
+	return nil
+}
+
+// fileSender stores every mail as an .eml file, it is meant for development
+type fileSender struct {
+	dir string
+}
+
+func newFileSender(dir string) (Sender, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create mail directory: %w", err)
+	}
+	return &fileSender{dir: dir}, nil
+}
+
+func (f *fileSender) Send(ctx context.Context, message Message) error {
+	body, err := compose(message)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(f.dir, name), body, 0o600)
+}
+
+// smtpSender delivers mails to an SMTP server, STARTTLS is used whenever the server offers it
+type smtpSender struct {
+	addr string
+	auth smtp.Auth
+}
+
+func newSMTPSender(cfg config.MailConfig) Sender {
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return &smtpSender{addr: cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort), auth: auth}
+}
+
+func (s *smtpSender) Send(ctx context.Context, message Message) error {
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	body, err := compose(message)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, from.Address, []string{to.Address}, body)
+}
+
+// compose builds a multipart/alternative MIME message with the plain text and the HTML body
+func compose(message Message) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	headers := []struct{ key, value string }{
+		{"From", message.From},
+		{"To", message.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + writer.Boundary()},
+	}
+	var head bytes.Buffer
+	for _, header := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", header.key, header.value)
+	}
+	head.WriteString("\r\n")
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
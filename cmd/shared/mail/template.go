@@ -0,0 +1,52 @@
+// cmd/shared/mail/template.go
+
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Names of the mail templates
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+// Every template has a NAME.txt defining the "subject" and "text" templates and a NAME.html for the HTML body
+//
+//go:embed templates
+var templateFiles embed.FS
+
+func render(name string, data map[string]interface{}) (Message, error) {
+	// Each mail is parsed on its own so every text file can define its own "subject" and "text"
+	text, err := texttemplate.ParseFS(templateFiles, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("unknown mail template %q: %w", name, err)
+	}
+	html, err := htmltemplate.ParseFS(templateFiles, "templates/"+name+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("unknown mail template %q: %w", name, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
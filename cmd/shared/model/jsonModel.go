@@ -2,6 +2,8 @@
 
 package model
 
+import "github.com/altsaqif/go-rest/cmd/shared/apperror"
+
 // Status defines the standard status structure
 type Status struct {
 	Code    int    `json:"code"`
@@ -20,3 +22,14 @@ type PagedResponse struct {
 	Data   []interface{} `json:"data,omitempty"`
 	Paging Paging        `json:"paging"`
 }
+
+// Problem defines the RFC 7807 error response structure sent to clients accepting application/problem+json
+type Problem struct {
+	Type      string                `json:"type"`
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail,omitempty"`
+	Instance  string                `json:"instance,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+	Errors    []apperror.FieldError `json:"errors,omitempty"`
+}
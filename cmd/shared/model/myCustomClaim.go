@@ -2,8 +2,52 @@ package model
 
 import "github.com/golang-jwt/jwt/v5"
 
+// TokenPurposeChallenge marks the short lived token between the password and the 2FA step of a login
+const TokenPurposeChallenge = "2fa_challenge"
+
+// Purposes of the tokens mailed to users, each of them can be used once
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// TokenPurposeDataExport signs the download link of a data export, it can be used until the export expires
+const TokenPurposeDataExport = "data_export"
+
+// TokenPurposeOIDCState signs the cookie that carries an OpenID Connect login from start to callback
+const TokenPurposeOIDCState = "oidc_state"
+
+// OIDCState holds what the callback of an OpenID Connect login checks, Verifier is the PKCE code verifier
+type OIDCState struct {
+	jwt.RegisteredClaims
+	Purpose  string `json:"purpose"`
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// Actor is the act claim of RFC 8693, it names the admin acting as the user of an impersonation token. Version
+// is the token version of the admin, raising it revokes the impersonation tokens they were issued as well.
+type Actor struct {
+	Subject string `json:"sub"`
+	Version uint   `json:"ver,omitempty"`
+}
+
 type MyCustomClaims struct {
 	jwt.RegisteredClaims
-	UserId uint   `json:"userId"`
-	Role   string `json:"role"`
+	UserId    uint   `json:"userId"`
+	Role      string `json:"role"`
+	TwoFactor bool   `json:"twoFactor,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	Email     string `json:"email,omitempty"`
+	// ClientID and Scope are set on the access tokens of OAuth clients, Scope is space separated
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Version must match the token version of the user, raising it revokes the token
+	Version uint `json:"ver,omitempty"`
+	// SessionID is the session the token was issued for, revoking the session revokes the token
+	SessionID string `json:"sid,omitempty"`
+	// Actor is set on impersonation tokens
+	Actor *Actor `json:"act,omitempty"`
 }
@@ -0,0 +1,27 @@
+// cmd/shared/money/currency.go
+
+package money
+
+import "strings"
+
+// exponents holds the ISO 4217 minor unit exponent of supported currencies
+var exponents = map[string]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0, "JOD": 3, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "OMR": 3,
+	"PHP": 2, "PLN": 2, "QAR": 2, "RUB": 2, "SAR": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// Exponent returns the number of minor unit digits of a currency
+func Exponent(currency string) (int, bool) {
+	exp, ok := exponents[strings.ToUpper(currency)]
+	return exp, ok
+}
+
+// IsSupported reports whether the currency is a known ISO 4217 code
+func IsSupported(currency string) bool {
+	_, ok := Exponent(currency)
+	return ok
+}
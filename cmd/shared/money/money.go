@@ -0,0 +1,165 @@
+// cmd/shared/money/money.go
+
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Money is an exact monetary value stored as integer minor units of an ISO 4217 currency
+type Money struct {
+	Amount   int64  `gorm:"not null;default:0" json:"-"`
+	Currency string `gorm:"type:char(3);not null;default:''" json:"-"`
+}
+
+// jsonMoney is the wire format of Money, the amount is a decimal string
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New creates Money from minor units
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Parse converts a decimal string such as "12.50" into Money of the given currency. Amounts are prices and
+// discounts, so signs are refused along with more decimal places than the currency has.
+func Parse(value, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Money{}, fmt.Errorf("amount is required")
+	}
+	if strings.HasPrefix(value, "-") {
+		return Money{}, fmt.Errorf("amount %q must not be negative", value)
+	}
+
+	whole, frac, _ := strings.Cut(value, ".")
+	if !isDigits(whole) || !isDigits(frac) || whole+frac == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", value, exp, currency)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	units, _ := new(big.Int).SetString("0"+whole+frac, 10)
+	if !units.IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is out of range", value)
+	}
+	return Money{Amount: units.Int64(), Currency: currency}, nil
+}
+
+// String formats the amount as a decimal string using the currency exponent
+func (m Money) String() string {
+	exp, ok := Exponent(m.Currency)
+	if !ok || exp == 0 {
+		return fmt.Sprintf("%d", m.Amount)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := fmt.Sprintf("%0*d", exp+1, amount)
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// IsZero reports whether the value has no amount and no currency
+func (m Money) IsZero() bool {
+	return m.Amount == 0 && m.Currency == ""
+}
+
+// MarshalJSON implements json.Marshaler
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the amount must be a decimal string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var payload jsonMoney
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("money must be an object with a decimal string amount and a currency: %v", err)
+	}
+
+	parsed, err := Parse(payload.Amount, payload.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Convert multiplies the amount by rate and rounds half away from zero into the target currency
+func (m Money) Convert(rate *big.Rat, to string) (Money, error) {
+	to = strings.ToUpper(to)
+	fromExp, ok := Exponent(m.Currency)
+	if !ok {
+		return Money{}, fmt.Errorf("unsupported currency %q", m.Currency)
+	}
+	toExp, ok := Exponent(to)
+	if !ok {
+		return Money{}, fmt.Errorf("unsupported currency %q", to)
+	}
+
+	value := new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(fromExp))
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetInt(pow10(toExp)))
+
+	amount, ok := roundHalfAwayFromZero(value)
+	if !ok {
+		return Money{}, fmt.Errorf("converted amount is out of range")
+	}
+	return Money{Amount: amount, Currency: to}, nil
+}
+
+// ParseRate parses a positive decimal exchange rate such as "0.9132"
+func ParseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", value)
+	}
+	return rate, nil
+}
+
+// isDigits reports whether s holds ASCII digits only, an empty s does
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
+
+func roundHalfAwayFromZero(value *big.Rat) (int64, bool) {
+	num := new(big.Int).Abs(value.Num())
+	quo, rem := new(big.Int).QuoRem(num, value.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if value.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64(), quo.IsInt64()
+}
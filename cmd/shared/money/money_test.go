@@ -0,0 +1,146 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     Money
+		invalid  bool
+	}{
+		{value: "12.50", currency: "USD", want: Money{Amount: 1250, Currency: "USD"}},
+		{value: "12.5", currency: "usd", want: Money{Amount: 1250, Currency: "USD"}},
+		{value: " 12 ", currency: "EUR", want: Money{Amount: 1200, Currency: "EUR"}},
+		{value: "0", currency: "USD", want: Money{Amount: 0, Currency: "USD"}},
+		{value: ".05", currency: "USD", want: Money{Amount: 5, Currency: "USD"}},
+		{value: "7.", currency: "USD", want: Money{Amount: 700, Currency: "USD"}},
+		{value: "007.10", currency: "USD", want: Money{Amount: 710, Currency: "USD"}},
+		{value: "1500", currency: "JPY", want: Money{Amount: 1500, Currency: "JPY"}},
+		{value: "1.234", currency: "KWD", want: Money{Amount: 1234, Currency: "KWD"}},
+		{value: "1.2", currency: "BHD", want: Money{Amount: 1200, Currency: "BHD"}},
+		{value: "92233720368547758.07", currency: "USD", want: Money{Amount: 9223372036854775807, Currency: "USD"}},
+
+		// More decimal places than the currency has are refused rather than rounded
+		{value: "12.505", currency: "USD", invalid: true},
+		{value: "1500.0", currency: "JPY", invalid: true},
+		{value: "1.2345", currency: "KWD", invalid: true},
+
+		{value: "-1", currency: "USD", invalid: true},
+		{value: "-0.01", currency: "USD", invalid: true},
+		{value: "+1", currency: "USD", invalid: true},
+		{value: "+-1", currency: "USD", invalid: true},
+		{value: "-", currency: "USD", invalid: true},
+		{value: "", currency: "USD", invalid: true},
+		{value: ".", currency: "USD", invalid: true},
+		{value: "1.2.3", currency: "USD", invalid: true},
+		{value: "1,50", currency: "USD", invalid: true},
+		{value: "1e3", currency: "USD", invalid: true},
+		{value: "1_000", currency: "USD", invalid: true},
+		{value: "0x10", currency: "USD", invalid: true},
+		{value: "1 000", currency: "USD", invalid: true},
+		{value: "١٢", currency: "USD", invalid: true},
+		{value: "92233720368547758.08", currency: "USD", invalid: true},
+		{value: "12.50", currency: "XXX", invalid: true},
+		{value: "12.50", currency: "", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.currency, func(t *testing.T) {
+			got, err := Parse(tt.value, tt.currency)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("Parse = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Parse = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: New(1250, "USD"), want: "12.50"},
+		{money: New(5, "USD"), want: "0.05"},
+		{money: New(0, "EUR"), want: "0.00"},
+		{money: New(-5, "USD"), want: "-0.05"},
+		{money: New(1500, "JPY"), want: "1500"},
+		{money: New(1, "KWD"), want: "0.001"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+		parsed, err := Parse(tt.want, tt.money.Currency)
+		if tt.money.Amount >= 0 && (err != nil || parsed != tt.money) {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.want, parsed, err, tt.money)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var m Money
+	if err := json.Unmarshal([]byte(`{"amount":"12.50","currency":"usd"}`), &m); err != nil || m != New(1250, "USD") {
+		t.Errorf("Unmarshal = %+v, %v", m, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"-12.50","currency":"USD"}`), &m); err == nil {
+		t.Error("Unmarshal accepted a negative amount")
+	}
+	if err := json.Unmarshal([]byte(`{"amount":12.5,"currency":"USD"}`), &m); err == nil {
+		t.Error("Unmarshal accepted a number amount")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name  string
+		money Money
+		rate  string
+		to    string
+		want  Money
+	}{
+		{name: "same scale", money: New(1000, "USD"), rate: "0.9132", to: "EUR", want: New(913, "EUR")},
+		{name: "half rounds up", money: New(1, "USD"), rate: "0.5", to: "USD", want: New(1, "USD")},
+		{name: "below half rounds down", money: New(1, "USD"), rate: "0.49", to: "USD", want: New(0, "USD")},
+		{name: "negative half rounds away from zero", money: New(-1, "USD"), rate: "0.5", to: "USD", want: New(-1, "USD")},
+		{name: "to a currency without minor units", money: New(150, "USD"), rate: "1", to: "JPY", want: New(2, "JPY")},
+		{name: "to a currency with three digits", money: New(1001, "USD"), rate: "0.30705", to: "KWD", want: New(3074, "KWD")},
+		{name: "from a currency without minor units", money: New(15000, "JPY"), rate: "0.0067", to: "usd", want: New(10050, "USD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := ParseRate(tt.rate)
+			if err != nil {
+				t.Fatalf("parse rate: %v", err)
+			}
+			got, err := tt.money.Convert(rate, tt.to)
+			if err != nil || got != tt.want {
+				t.Errorf("Convert = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := New(1, "USD").Convert(big.NewRat(1, 1), "XXX"); err == nil {
+		t.Error("Convert to an unsupported currency succeeded")
+	}
+	if _, err := New(1<<62, "JPY").Convert(big.NewRat(1, 1), "KWD"); err == nil {
+		t.Error("Convert out of range succeeded")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, value := range []string{"0", "-1", "abc", ""} {
+		if _, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// cmd/shared/query/query.go
+
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op compares a field with the value of a Condition
+type Op string
+
+const (
+	Eq  Op = "eq"
+	In  Op = "in"
+	Gt  Op = "gt"
+	Gte Op = "gte"
+	Lt  Op = "lt"
+	Lte Op = "lte"
+	// Null matches an empty field when the value is true and a set one when it is false
+	Null Op = "null"
+)
+
+// Condition restricts a field, fields are the names a repository knows, never raw columns
+type Condition struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+// Order sorts by a field, descending when Desc
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Spec describes a listing: the rows matching Search and every condition, their order and the page.
+// The methods return copies so a base spec can be extended without changing it.
+type Spec struct {
+	Search     string
+	Conditions []Condition
+	Orders     []Order
+	Page       int
+	Size       int
+}
+
+// Where adds a condition
+func (s Spec) Where(field string, op Op, value interface{}) Spec {
+	s.Conditions = append(append([]Condition{}, s.Conditions...), Condition{Field: field, Op: op, Value: value})
+	return s
+}
+
+// OrderBy adds orders after the existing ones
+func (s Spec) OrderBy(orders ...Order) Spec {
+	s.Orders = append(append([]Order{}, s.Orders...), orders...)
+	return s
+}
+
+// Paginate selects a page, it falls back to the first page of 10 rows
+func (s Spec) Paginate(page, size int) Spec {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+	s.Page, s.Size = page, size
+	return s
+}
+
+// Offset returns the number of rows before the page
+func (s Spec) Offset() int {
+	return (s.Page - 1) * s.Size
+}
+
+// ParseSort reads a comma separated list like "-created_at,email", a leading "-" sorts descending
+func ParseSort(value string, allowed ...string) ([]Order, error) {
+	var orders []Order
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		order := Order{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !contains(allowed, order.Field) {
+			return nil, fmt.Errorf("cannot sort by %q, use one of %s", order.Field, strings.Join(allowed, ", "))
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+// cmd/shared/requestinfo/requestinfo.go
+
+package requestinfo
+
+import "context"
+
+// Info describes the request a usecase runs for, the audit log reads it to record who did what and from where
+type Info struct {
+	RequestID string
+	IP        string
+	// UserID is the authenticated user, zero before RequireToken ran or on public routes
+	UserID uint
+	// ImpersonatorID is the admin acting as the user with an impersonation token
+	ImpersonatorID uint
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying info
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the info stored in ctx, the zero value when there is none
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(contextKey{}).(Info)
+	return info
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/altsaqif/go-rest/cmd/config"
@@ -11,23 +12,44 @@ import (
 )
 
 type JwtService interface {
-	CreateToken(author dto.UserWithProducts) (dto.AuthResponseDto, error)
+	// CreateToken issues the token of a login, sessionID is the session it belongs to and twoFactor tells the
+	// user passed a second factor for it
+	CreateToken(user dto.UserWithProducts, sessionID string, twoFactor bool) (dto.AuthResponseDto, error)
+	// CreateChallengeToken issues the token exchanged for a real one once the 2FA code is checked
+	CreateChallengeToken(user dto.UserWithProducts) (dto.AuthResponseDto, error)
 	ParseToken(tokenHeader string) (jwt.MapClaims, error)
+	// ParseChallengeToken returns the user ID of a valid challenge token
+	ParseChallengeToken(token string) (uint, error)
+	// CreateActionToken signs a mailed token for purpose, id is its JWT ID for single use tracking
+	CreateActionToken(user dto.UserWithProducts, purpose, id string, ttl time.Duration) (string, error)
+	// ParseActionToken verifies token and returns its claims when it was created for purpose
+	ParseActionToken(token, purpose string) (model.MyCustomClaims, error)
+	// CreateOAuthToken issues the access token of an OAuth grant, id is the ID of the grant and scope is space
+	// separated
+	CreateOAuthToken(user dto.UserWithProducts, clientID, scope, id string, ttl time.Duration) (string, error)
+	// CreateImpersonationToken issues a token of user for the admin actor, it carries the actor in the act claim
+	CreateImpersonationToken(user, actor dto.UserWithProducts, ttl time.Duration) (string, error)
+	// CreateOIDCStateToken signs the state of an OpenID Connect login, ParseOIDCStateToken verifies it
+	CreateOIDCStateToken(state model.OIDCState, ttl time.Duration) (string, error)
+	ParseOIDCStateToken(token string) (model.OIDCState, error)
 }
 
 type jwtService struct {
 	cfg config.TokenConfig
 }
 
-func (j *jwtService) CreateToken(user dto.UserWithProducts) (dto.AuthResponseDto, error) {
+func (j *jwtService) CreateToken(user dto.UserWithProducts, sessionID string, twoFactor bool) (dto.AuthResponseDto, error) {
 	claims := model.MyCustomClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.cfg.IssuerName,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.cfg.JwtExpiresTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
-		UserId: user.ID,
-		Role:   user.Role,
+		UserId:    user.ID,
+		Role:      user.Role,
+		TwoFactor: twoFactor,
+		Version:   user.TokenVersion,
+		SessionID: sessionID,
 	}
 
 	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, claims)
@@ -38,13 +60,154 @@ func (j *jwtService) CreateToken(user dto.UserWithProducts) (dto.AuthResponseDto
 	return dto.AuthResponseDto{Token: ss}, nil
 }
 
+func (j *jwtService) CreateChallengeToken(user dto.UserWithProducts) (dto.AuthResponseDto, error) {
+	claims := model.MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.cfg.IssuerName,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.cfg.JwtChallengeExpiresTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserId:  user.ID,
+		Role:    user.Role,
+		Purpose: model.TokenPurposeChallenge,
+	}
+
+	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, claims)
+	ss, err := token.SignedString(j.cfg.JwtSignatureKey)
+	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("oops, failed to create challenge token: %v", err)
+	}
+	return dto.AuthResponseDto{TwoFactorRequired: true, ChallengeToken: ss}, nil
+}
+
+func (j *jwtService) ParseChallengeToken(token string) (uint, error) {
+	claims, err := j.ParseToken(token)
+	if err != nil {
+		return 0, err
+	}
+	if claims["purpose"] != model.TokenPurposeChallenge {
+		return 0, fmt.Errorf("oops, not a challenge token")
+	}
+	userID, ok := claims["userId"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("oops, missing user in challenge token")
+	}
+	return uint(userID), nil
+}
+
+func (j *jwtService) CreateActionToken(user dto.UserWithProducts, purpose, id string, ttl time.Duration) (string, error) {
+	claims := model.MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    j.cfg.IssuerName,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserId:  user.ID,
+		Purpose: purpose,
+		Email:   user.Email,
+	}
+
+	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, claims)
+	ss, err := token.SignedString(j.cfg.JwtSignatureKey)
+	if err != nil {
+		return "", fmt.Errorf("oops, failed to create %s token: %v", purpose, err)
+	}
+	return ss, nil
+}
+
+func (j *jwtService) ParseActionToken(token, purpose string) (model.MyCustomClaims, error) {
+	var claims model.MyCustomClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		return j.cfg.JwtSignatureKey, nil
+	}, jwt.WithValidMethods([]string{j.cfg.JwtSigningMethod.Alg()}))
+	if err != nil {
+		return model.MyCustomClaims{}, fmt.Errorf("oops, failed to verify token: %v", err)
+	}
+	if claims.Purpose != purpose || claims.ID == "" {
+		return model.MyCustomClaims{}, fmt.Errorf("oops, not a %s token", purpose)
+	}
+	return claims, nil
+}
+
+func (j *jwtService) CreateOAuthToken(user dto.UserWithProducts, clientID, scope, id string, ttl time.Duration) (string, error) {
+	claims := model.MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    j.cfg.IssuerName,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserId:   user.ID,
+		Role:     user.Role,
+		Version:  user.TokenVersion,
+		ClientID: clientID,
+		Scope:    scope,
+	}
+
+	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, claims)
+	ss, err := token.SignedString(j.cfg.JwtSignatureKey)
+	if err != nil {
+		return "", fmt.Errorf("oops, failed to create OAuth token: %v", err)
+	}
+	return ss, nil
+}
+
+func (j *jwtService) CreateImpersonationToken(user, actor dto.UserWithProducts, ttl time.Duration) (string, error) {
+	claims := model.MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.cfg.IssuerName,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserId:  user.ID,
+		Role:    user.Role,
+		Version: user.TokenVersion,
+		Actor:   &model.Actor{Subject: strconv.FormatUint(uint64(actor.ID), 10), Version: actor.TokenVersion},
+	}
+
+	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, claims)
+	ss, err := token.SignedString(j.cfg.JwtSignatureKey)
+	if err != nil {
+		return "", fmt.Errorf("oops, failed to create impersonation token: %v", err)
+	}
+	return ss, nil
+}
+
+func (j *jwtService) CreateOIDCStateToken(state model.OIDCState, ttl time.Duration) (string, error) {
+	state.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    j.cfg.IssuerName,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	state.Purpose = model.TokenPurposeOIDCState
+
+	token := jwt.NewWithClaims(j.cfg.JwtSigningMethod, state)
+	ss, err := token.SignedString(j.cfg.JwtSignatureKey)
+	if err != nil {
+		return "", fmt.Errorf("oops, failed to create OIDC state token: %v", err)
+	}
+	return ss, nil
+}
+
+func (j *jwtService) ParseOIDCStateToken(token string) (model.OIDCState, error) {
+	var state model.OIDCState
+	_, err := jwt.ParseWithClaims(token, &state, func(token *jwt.Token) (interface{}, error) {
+		return j.cfg.JwtSignatureKey, nil
+	}, jwt.WithValidMethods([]string{j.cfg.JwtSigningMethod.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return model.OIDCState{}, fmt.Errorf("oops, failed to verify token: %v", err)
+	}
+	if state.Purpose != model.TokenPurposeOIDCState || state.State == "" {
+		return model.OIDCState{}, fmt.Errorf("oops, not an OIDC state token")
+	}
+	return state, nil
+}
+
 func (j *jwtService) ParseToken(tokenHeader string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenHeader, func(token *jwt.Token) (interface{}, error) {
-		// if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		// 	return nil, fmt.Errorf("oops, unexpected signing method: %v", token.Header["alg"])
-		// }
 		return j.cfg.JwtSignatureKey, nil
-	})
+	}, jwt.WithValidMethods([]string{j.cfg.JwtSigningMethod.Alg()}))
 
 	if err != nil {
 		return nil, fmt.Errorf("oops, failed to verify token: %v", err)
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseTokenSigningMethod(t *testing.T) {
+	key := []byte("signature-key")
+	j := NewJwtService(config.TokenConfig{IssuerName: "test", JwtSignatureKey: key, JwtSigningMethod: jwt.SigningMethodHS256, JwtExpiresTime: time.Hour})
+	claims := jwt.MapClaims{"userId": 1, "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}
+
+	sign := func(method jwt.SigningMethod, key interface{}) string {
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign %s: %v", method.Alg(), err)
+		}
+		return signed
+	}
+	issued, err := j.CreateToken(dto.UserWithProducts{ID: 1, Role: "admin"}, "", false)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "issued token", token: issued.Token, valid: true},
+		{name: "HS256", token: sign(jwt.SigningMethodHS256, key), valid: true},
+		{name: "HS512 with the same key", token: sign(jwt.SigningMethodHS512, key)},
+		{name: "alg none", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.ParseToken(tt.token)
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("valid = %v, want %v: %v", valid, tt.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+)
+
+const NotificationBackInStock = "back_in_stock"
+
+// Notification is a message for a single user, Key identifies duplicates
+type Notification struct {
+	Key     string                 `json:"-"`
+	Kind    string                 `json:"kind"`
+	UserID  uint                   `json:"user_id"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers a notification through a concrete channel
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+type NotificationService interface {
+	Enqueue(notification Notification) bool
+}
+
+type notificationService struct {
+	notifier Notifier
+	window   time.Duration
+	queue    chan Notification
+	mu       sync.Mutex
+	sent     map[string]time.Time
+}
+
+// Enqueue implements NotificationService, duplicates within the dedupe window are dropped.
+func (n *notificationService) Enqueue(notification Notification) bool {
+	now := time.Now()
+
+	n.mu.Lock()
+	if last, ok := n.sent[notification.Key]; ok && now.Sub(last) < n.window {
+		n.mu.Unlock()
+		return false
+	}
+	n.sent[notification.Key] = now
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- notification:
+		return true
+	default:
+		slog.Warn("notificationService.Enqueue: queue is full, dropping", "key", notification.Key)
+		n.mu.Lock()
+		delete(n.sent, notification.Key)
+		n.mu.Unlock()
+		return false
+	}
+}
+
+func (n *notificationService) run() {
+	ticker := time.NewTicker(n.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case notification := <-n.queue:
+			if err := n.notifier.Notify(notification); err != nil {
+				slog.Error("notificationService: failed to deliver", "key", notification.Key, "err", err)
+			}
+		case <-ticker.C:
+			n.forgetExpired()
+		}
+	}
+}
+
+func (n *notificationService) forgetExpired() {
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, last := range n.sent {
+		if now.Sub(last) >= n.window {
+			delete(n.sent, key)
+		}
+	}
+}
+
+// logNotifier writes notifications to the application log
+type logNotifier struct{}
+
+func (l *logNotifier) Notify(notification Notification) error {
+	slog.Info("Notification", "kind", notification.Kind, "to_user_id", notification.UserID, "message", notification.Message)
+	return nil
+}
+
+// webhookNotifier posts notifications as JSON to an HTTP endpoint
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewNotifier selects the notifier configured by NOTIFIER_DRIVER
+func NewNotifier(cfg config.NotificationConfig) (Notifier, error) {
+	switch cfg.NotifierDriver {
+	case "", "log":
+		return &logNotifier{}, nil
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("NOTIFIER_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return &webhookNotifier{url: cfg.WebhookURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", cfg.NotifierDriver)
+	}
+}
+
+func NewNotificationService(notifier Notifier, cfg config.NotificationConfig) NotificationService {
+	n := &notificationService{
+		notifier: notifier,
+		window:   cfg.DedupeWindow,
+		queue:    make(chan Notification, cfg.QueueSize),
+		sent:     make(map[string]time.Time),
+	}
+	go n.run()
+	return n
+}
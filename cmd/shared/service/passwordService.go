@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordService interface {
+	// Validate checks a new password against the policy, personal holds the email and names of the user
+	Validate(password string, personal ...string) error
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash and whether the hash should be upgraded
+	Verify(password, hash string) (ok bool, rehash bool)
+}
+
+type passwordService struct {
+	cfg config.PasswordConfig
+}
+
+// Validate implements PasswordService.
+func (p *passwordService) Validate(password string, personal ...string) error {
+	var fields []apperror.FieldError
+	violation := func(rule, message string) {
+		fields = append(fields, apperror.FieldError{Field: "password", Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < p.cfg.MinLength {
+		violation("min", fmt.Sprintf("password must be at least %d characters long", p.cfg.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violation("upper", "password must contain an upper case letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		violation("lower", "password must contain a lower case letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		violation("digit", "password must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violation("symbol", "password must contain a symbol")
+	}
+
+	if containsPersonal(password, personal) {
+		violation("personal", "password must not contain your email or name")
+	}
+
+	breached, err := p.breached(password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		violation("breached", "password has appeared in a data breach, choose another one")
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Message
+	}
+	return apperror.InvalidFields(strings.Join(messages, "; "), fields)
+}
+
+// containsPersonal looks for the local part of the email and every name of at least three letters
+func containsPersonal(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		value, _, _ = strings.Cut(strings.ToLower(value), "@")
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if len([]rune(part)) >= 3 && strings.Contains(lowered, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// breached searches the SHA-1 of the password in the breached password file. The file uses the Have I Been
+// Pwned layout, lines of "HASH:COUNT" sorted by hash, so only the 5 character prefix range is read.
+func (p *passwordService) breached(password string) (bool, error) {
+	if p.cfg.BreachedFile == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	file, err := os.Open(p.cfg.BreachedFile)
+	if err != nil {
+		return false, fmt.Errorf("open breached password file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	start, err := seekPrefix(file, info.Size(), hash[:5])
+	if err != nil {
+		return false, err
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		candidate, _, _ := strings.Cut(line, ":")
+		if !strings.HasPrefix(candidate, hash[:5]) {
+			break
+		}
+		if candidate == hash {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// seekPrefix binary searches the sorted file for the offset of the first line at or after prefix
+func seekPrefix(file *os.File, size int64, prefix string) (int64, error) {
+	buf := make([]byte, 128)
+	low, high := int64(0), size
+	for low < high {
+		mid := low + (high-low)/2
+		start, line, err := lineFrom(file, mid, buf)
+		if err != nil {
+			return 0, err
+		}
+		if start >= size || strings.ToUpper(line) >= prefix {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	start, _, err := lineFrom(file, low, buf)
+	return start, err
+}
+
+// lineFrom returns the first line that starts at or after offset
+func lineFrom(file *os.File, offset int64, buf []byte) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// the line starts right after the first newline found from the previous byte on
+		for pos := offset - 1; ; {
+			n, err := file.ReadAt(buf, pos)
+			if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+				start = pos + int64(i) + 1
+				break
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return pos + int64(n), "", nil
+				}
+				return 0, "", err
+			}
+			pos += int64(n)
+		}
+	}
+
+	n, err := file.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+	line, _, _ := bytes.Cut(buf[:n], []byte("\n"))
+	return start, string(line), nil
+}
+
+// Hash implements PasswordService with the configured algorithm.
+func (p *passwordService) Hash(password string) (string, error) {
+	if p.cfg.HashAlgorithm == "bcrypt" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), p.cfg.BcryptCost)
+		return string(hashed), err
+	}
+
+	salt := make([]byte, p.cfg.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.cfg.Argon2Time, p.cfg.Argon2Memory, p.cfg.Argon2Threads, p.cfg.Argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.cfg.Argon2Memory, p.cfg.Argon2Time,
+		p.cfg.Argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify implements PasswordService, it understands bcrypt and argon2id hashes whatever the configured algorithm.
+func (p *passwordService) Verify(password, hash string) (bool, bool) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return p.verifyArgon2(password, hash)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, p.cfg.HashAlgorithm != "bcrypt" || err != nil || cost < p.cfg.BcryptCost
+}
+
+func (p *passwordService) verifyArgon2(password, hash string) (bool, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false
+	}
+	rehash := p.cfg.HashAlgorithm != "argon2id" || memory != p.cfg.Argon2Memory || time != p.cfg.Argon2Time ||
+		threads != p.cfg.Argon2Threads || uint32(len(key)) != p.cfg.Argon2KeyLen
+	return true, rehash
+}
+
+func NewPasswordService(cfg config.PasswordConfig) PasswordService {
+	return &passwordService{cfg: cfg}
+}
@@ -0,0 +1,39 @@
+// cmd/shared/testdb/testdb.go
+
+package testdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/migration"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// New opens an in-memory SQLite database with the schema of the application, private to the test and dropped
+// when it ends
+func New(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := migration.Run(db, &config.Config{ApiConfig: config.ApiConfig{DefaultCurrency: "IDR"}}); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
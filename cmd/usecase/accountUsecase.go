@@ -0,0 +1,518 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/mail"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+var (
+	// ErrInvalidAccountToken is returned for mailed tokens that are forged, expired or already used
+	ErrInvalidAccountToken = apperror.Validation("invalid or expired token")
+	ErrEmailNotVerified    = apperror.Forbidden("email address is not verified, follow the link in the verification mail")
+	ErrOwnRole             = apperror.Forbidden("you can not change your own role")
+	ErrOwnDeactivation     = apperror.Forbidden("you can not deactivate yourself")
+	ErrEmptyBulkFilter     = apperror.Validation("the filter of a bulk action must not be empty")
+)
+
+type AccountUseCase interface {
+	// SendVerification mails the user a link that verifies their current email
+	SendVerification(ctx context.Context, user dto.UserWithProducts) error
+	// ResendVerification mails a new link, unknown and verified emails are silently ignored
+	ResendVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	// ForgotPassword mails a reset link, unknown emails are silently ignored
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, payload dto.ResetPasswordRequestDto, ip string) error
+	// CheckVerified fails for users with an unverified email when verified emails are required
+	CheckVerified(user dto.UserWithProducts) error
+
+	// UpdateProfile changes the names and the email of the user, a new email has to be verified again
+	UpdateProfile(ctx context.Context, userID uint, payload dto.ProfileUpdateRequestDto, ip string) (dto.UserWithProducts, error)
+	// ChangePassword revokes every token of the user and returns a new one for the current session
+	ChangePassword(ctx context.Context, userID uint, sessionID string, payload dto.ChangePasswordRequestDto, ip string) (dto.AuthResponseDto, error)
+	// DeleteAccount erases the user after checking their password
+	DeleteAccount(ctx context.Context, userID uint, password, ip string) error
+	// UpdateUser is the admin update of any user, a new role revokes the tokens of the user
+	UpdateUser(ctx context.Context, actorID, userID uint, payload dto.UserUpdateRequestDto, ip string) (dto.UserWithProducts, error)
+	// SetActive deactivates a user and revokes their tokens, or reactivates them
+	SetActive(ctx context.Context, actorID, userID uint, active bool, ip string) error
+	// BulkUpdate applies the action to every user matching the filter except the actor, users already in the
+	// target state are matched but not updated
+	BulkUpdate(ctx context.Context, actorID uint, payload dto.UserBulkRequestDto, ip string) (dto.UserBulkResponseDto, error)
+}
+
+type accountUseCase struct {
+	userUc          UserUseCase
+	attemptUc       LoginAttemptUseCase
+	auditUc         AuditUseCase
+	privacyUc       PrivacyUseCase
+	sessionUc       SessionUseCase
+	repo            repository.UserTokenRepository
+	jwtService      service.JwtService
+	passwordService service.PasswordService
+	mailer          mail.Mailer
+	cfg             config.AccountConfig
+}
+
+// SendVerification implements AccountUseCase.
+func (a *accountUseCase) SendVerification(ctx context.Context, user dto.UserWithProducts) error {
+	return a.send(ctx, user, model.TokenPurposeVerifyEmail, a.cfg.VerifyEmailTTL, mail.TemplateVerifyEmail, "/verify-email")
+}
+
+// ResendVerification implements AccountUseCase.
+func (a *accountUseCase) ResendVerification(ctx context.Context, email string) error {
+	user, err := a.userUc.FindUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return a.SendVerification(ctx, user)
+}
+
+// VerifyEmail implements AccountUseCase.
+func (a *accountUseCase) VerifyEmail(ctx context.Context, token string) error {
+	user, claims, err := a.redeemable(ctx, token, model.TokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	if err := a.use(ctx, claims); err != nil {
+		return err
+	}
+	return a.userUc.MarkEmailVerified(ctx, user.ID, claims.Email)
+}
+
+// ForgotPassword implements AccountUseCase.
+func (a *accountUseCase) ForgotPassword(ctx context.Context, email string) error {
+	user, err := a.userUc.FindUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return a.send(ctx, user, model.TokenPurposeResetPassword, a.cfg.ResetPasswordTTL, mail.TemplateResetPassword, "/reset-password")
+}
+
+// ResetPassword implements AccountUseCase. The new password is checked and hashed before the token is used
+// so a rejected password does not burn the link.
+func (a *accountUseCase) ResetPassword(ctx context.Context, payload dto.ResetPasswordRequestDto, ip string) error {
+	user, claims, err := a.redeemable(ctx, payload.Token, model.TokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+	if err := a.passwordService.Validate(payload.Password, user.Email, user.FirstName, user.LastName); err != nil {
+		return err
+	}
+	hashed, err := a.passwordService.Hash(payload.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := a.use(ctx, claims); err != nil {
+		return err
+	}
+	if err := a.userUc.UpdatePassword(ctx, user.ID, hashed); err != nil {
+		return err
+	}
+	if err := a.repo.RevokeAll(ctx, user.ID, model.TokenPurposeResetPassword); err != nil {
+		slog.ErrorContext(ctx, "accountUseCase.ResetPassword: revoke", "err", err)
+	}
+
+	// Following the mailed link proves the mailbox as well, and ends a lockout of the account
+	if err := a.userUc.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+		slog.ErrorContext(ctx, "accountUseCase.ResetPassword: verify email", "err", err)
+	}
+	a.attemptUc.Succeed(ctx, user.Email)
+
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &user.ID,
+		Action:     entity.AuditPasswordReset,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+		Changes:    auditChanges(map[string]string{"password": user.Password}, map[string]string{"password": hashed}),
+	})
+	return nil
+}
+
+// CheckVerified implements AccountUseCase.
+func (a *accountUseCase) CheckVerified(user dto.UserWithProducts) error {
+	if a.cfg.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// UpdateProfile implements AccountUseCase.
+func (a *accountUseCase) UpdateProfile(ctx context.Context, userID uint, payload dto.ProfileUpdateRequestDto, ip string) (dto.UserWithProducts, error) {
+	user, err := a.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+
+	changes := nameChanges(payload.FirstName, payload.LastName)
+	emailChanged := addEmailChange(changes, user, payload.Email)
+	// A stolen session must not be enough to take over the account through a password reset to a new email
+	if emailChanged {
+		if err := a.confirmPassword(ctx, user, payload.CurrentPassword, ip); err != nil {
+			return dto.UserWithProducts{}, err
+		}
+	}
+
+	updated, err := a.update(ctx, user.ID, changes, emailChanged)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	if len(changes) > 0 {
+		a.record(ctx, user.ID, user.ID, entity.AuditUserUpdate, ip, "changed: "+strings.Join(changedFields(changes), ", "), auditChanges(user, updated))
+	}
+	return updated, nil
+}
+
+// ChangePassword implements AccountUseCase.
+func (a *accountUseCase) ChangePassword(ctx context.Context, userID uint, sessionID string, payload dto.ChangePasswordRequestDto, ip string) (dto.AuthResponseDto, error) {
+	user, err := a.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	if err := a.confirmPassword(ctx, user, payload.CurrentPassword, ip); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	if err := a.passwordService.Validate(payload.Password, user.Email, user.FirstName, user.LastName); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	hashed, err := a.passwordService.Hash(payload.Password)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	if err := a.userUc.UpdatePassword(ctx, user.ID, hashed); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	if err := a.userUc.RevokeTokens(ctx, user.ID); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	if err := a.repo.RevokeAll(ctx, user.ID, model.TokenPurposeResetPassword); err != nil {
+		slog.ErrorContext(ctx, "accountUseCase.ChangePassword: revoke", "err", err)
+	}
+	a.record(ctx, user.ID, user.ID, entity.AuditPasswordChange, ip, "",
+		auditChanges(map[string]string{"password": user.Password}, map[string]string{"password": hashed}))
+
+	// The new token carries the raised token version, so only the session that changed the password survives
+	user, err = a.userUc.FindUserByID(ctx, user.ID)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	return a.sessionUc.RenewSession(ctx, user, sessionID, false)
+}
+
+// DeleteAccount implements AccountUseCase.
+func (a *accountUseCase) DeleteAccount(ctx context.Context, userID uint, password, ip string) error {
+	user, err := a.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := a.confirmPassword(ctx, user, password, ip); err != nil {
+		return err
+	}
+	return a.privacyUc.Erase(ctx, user.ID, user.ID, entity.ErasureChannelSelf, ip)
+}
+
+// UpdateUser implements AccountUseCase.
+func (a *accountUseCase) UpdateUser(ctx context.Context, actorID, userID uint, payload dto.UserUpdateRequestDto, ip string) (dto.UserWithProducts, error) {
+	user, err := a.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+
+	changes := nameChanges(payload.FirstName, payload.LastName)
+	emailChanged := addEmailChange(changes, user, payload.Email)
+	roleChanged := payload.Role != nil && *payload.Role != user.Role
+	if roleChanged {
+		if actorID == userID {
+			return dto.UserWithProducts{}, ErrOwnRole
+		}
+		changes["role"] = *payload.Role
+	}
+
+	updated, err := a.update(ctx, user.ID, changes, emailChanged)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	// Tokens carry the role, the old ones would keep the old permissions
+	if roleChanged {
+		if err := a.userUc.RevokeTokens(ctx, user.ID); err != nil {
+			return dto.UserWithProducts{}, err
+		}
+	}
+	if len(changes) > 0 {
+		a.record(ctx, actorID, user.ID, entity.AuditUserUpdate, ip, "changed: "+strings.Join(changedFields(changes), ", "), auditChanges(user, updated))
+	}
+	return updated, nil
+}
+
+// SetActive implements AccountUseCase.
+func (a *accountUseCase) SetActive(ctx context.Context, actorID, userID uint, active bool, ip string) error {
+	if actorID == userID && !active {
+		return ErrOwnDeactivation
+	}
+
+	user, err := a.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var deactivatedAt *time.Time
+	action := entity.AuditUserActivate
+	if !active {
+		now := time.Now()
+		deactivatedAt, action = &now, entity.AuditUserDeactivate
+	}
+	updated, err := a.userUc.UpdateUser(ctx, userID, map[string]interface{}{"deactivated_at": deactivatedAt})
+	if err != nil {
+		return err
+	}
+	if !active {
+		if err := a.userUc.RevokeTokens(ctx, userID); err != nil {
+			return err
+		}
+	}
+	a.record(ctx, actorID, userID, action, ip, "", auditChanges(user, updated))
+	return nil
+}
+
+// BulkUpdate implements AccountUseCase.
+func (a *accountUseCase) BulkUpdate(ctx context.Context, actorID uint, payload dto.UserBulkRequestDto, ip string) (dto.UserBulkResponseDto, error) {
+	spec := payload.Filter.Spec()
+	if spec.Search == "" && len(spec.Conditions) == 0 {
+		return dto.UserBulkResponseDto{}, ErrEmptyBulkFilter
+	}
+
+	matched, err := a.userUc.CountMatchingUsers(ctx, spec, actorID)
+	if err != nil {
+		return dto.UserBulkResponseDto{}, err
+	}
+	result := dto.UserBulkResponseDto{Matched: matched}
+	if payload.DryRun || matched == 0 {
+		return result, nil
+	}
+
+	// Only the users not yet in the target state are updated, so their tokens alone are revoked
+	var changes map[string]interface{}
+	switch payload.Action {
+	case "set_role":
+		others := make([]string, 0, 2)
+		for _, role := range []string{"customer", "reseller", "admin"} {
+			if role != payload.Role {
+				others = append(others, role)
+			}
+		}
+		spec = spec.Where("role", query.In, others)
+		changes = map[string]interface{}{"role": payload.Role}
+	case "deactivate":
+		spec = spec.Where("deactivated_at", query.Null, true)
+		changes = map[string]interface{}{"deactivated_at": time.Now()}
+	case "activate":
+		spec = spec.Where("deactivated_at", query.Null, false)
+		changes = map[string]interface{}{"deactivated_at": nil}
+	default:
+		return dto.UserBulkResponseDto{}, apperror.Validation("unknown bulk action " + payload.Action)
+	}
+
+	if result.Updated, err = a.userUc.UpdateMatchingUsers(ctx, spec, actorID, changes); err != nil {
+		return dto.UserBulkResponseDto{}, err
+	}
+
+	// The search text may be an email or a name, which the entry would keep after an erasure
+	auditFilter := payload.Filter
+	if auditFilter.Query != "" {
+		auditFilter.Query = "[redacted]"
+	}
+	filter, _ := json.Marshal(auditFilter)
+	details := fmt.Sprintf("action: %s, matched: %d, updated: %d, filter: %s", payload.Action, result.Matched, result.Updated, filter)
+	if payload.Action == "set_role" {
+		details += ", role: " + payload.Role
+	}
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     entity.AuditUserBulkUpdate,
+		TargetType: "user",
+		IP:         ip,
+		Details:    details,
+	})
+	return result, nil
+}
+
+// update saves changes and mails a verification link when the email changed
+func (a *accountUseCase) update(ctx context.Context, userID uint, changes map[string]interface{}, emailChanged bool) (dto.UserWithProducts, error) {
+	updated, err := a.userUc.UpdateUser(ctx, userID, changes)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	if emailChanged {
+		if err := a.SendVerification(ctx, updated); err != nil {
+			slog.ErrorContext(ctx, "accountUseCase.update: verification mail", "err", err)
+		}
+	}
+	return updated, nil
+}
+
+// confirmPassword checks the current password of the user, wrong ones count as failed logins
+func (a *accountUseCase) confirmPassword(ctx context.Context, user dto.UserWithProducts, password, ip string) error {
+	if err := a.attemptUc.Check(ctx, user.Email, ip); err != nil {
+		return err
+	}
+	if ok, _ := a.passwordService.Verify(password, user.Password); !ok {
+		a.attemptUc.Fail(ctx, user.Email, ip)
+		message := "current password is incorrect"
+		return apperror.InvalidFields(message, []apperror.FieldError{
+			{Field: "current_password", Rule: "current_password", Message: message},
+		})
+	}
+	return nil
+}
+
+func (a *accountUseCase) record(ctx context.Context, actorID, userID uint, action, ip, details, changes string) {
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IP:         ip,
+		Details:    details,
+		Changes:    changes,
+	})
+}
+
+func nameChanges(firstName, lastName *string) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if firstName != nil {
+		changes["firstname"] = strings.TrimSpace(*firstName)
+	}
+	if lastName != nil {
+		changes["lastname"] = strings.TrimSpace(*lastName)
+	}
+	return changes
+}
+
+// addEmailChange adds a new email to changes and marks it unverified, it reports whether the email changed
+func addEmailChange(changes map[string]interface{}, user dto.UserWithProducts, email *string) bool {
+	if email == nil || strings.EqualFold(*email, user.Email) {
+		return false
+	}
+	changes["email"] = *email
+	changes["email_verified_at"] = nil
+	return true
+}
+
+func changedFields(changes map[string]interface{}) []string {
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// send stores a new token for purpose and mails the link to it
+func (a *accountUseCase) send(ctx context.Context, user dto.UserWithProducts, purpose string, ttl time.Duration, template, path string) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	stored, err := a.repo.Create(ctx, entity.UserToken{
+		ID:        hex.EncodeToString(id),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := a.jwtService.CreateActionToken(user, purpose, stored.ID, ttl)
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(user.Email, template, map[string]interface{}{
+		"Name":      strings.TrimSpace(user.FirstName + " " + user.LastName),
+		"Email":     user.Email,
+		"Link":      a.cfg.AppURL + path + "?token=" + url.QueryEscape(token),
+		"ExpiresIn": humanDuration(ttl),
+	})
+}
+
+// redeemable checks the signature, purpose and email of token without using it
+func (a *accountUseCase) redeemable(ctx context.Context, token, purpose string) (dto.UserWithProducts, model.MyCustomClaims, error) {
+	claims, err := a.jwtService.ParseActionToken(token, purpose)
+	if err != nil {
+		return dto.UserWithProducts{}, model.MyCustomClaims{}, ErrInvalidAccountToken
+	}
+
+	user, err := a.userUc.FindUserByID(ctx, claims.UserId)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return dto.UserWithProducts{}, model.MyCustomClaims{}, ErrInvalidAccountToken
+	}
+	if err != nil {
+		return dto.UserWithProducts{}, model.MyCustomClaims{}, err
+	}
+	// A link mailed to a previous address of the user is worthless
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return dto.UserWithProducts{}, model.MyCustomClaims{}, ErrInvalidAccountToken
+	}
+	return user, claims, nil
+}
+
+func (a *accountUseCase) use(ctx context.Context, claims model.MyCustomClaims) error {
+	used, err := a.repo.Use(ctx, claims.ID, claims.Purpose)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidAccountToken
+	}
+	return nil
+}
+
+// humanDuration spells out ttl for the mails, like "24 hours" or "30 minutes"
+func humanDuration(ttl time.Duration) string {
+	value, unit := int64(ttl/time.Minute), "minute"
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		value, unit = int64(ttl/time.Hour), "hour"
+	}
+	if value == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", value, unit)
+}
+
+func NewAccountUseCase(userUc UserUseCase, attemptUc LoginAttemptUseCase, auditUc AuditUseCase, privacyUc PrivacyUseCase, sessionUc SessionUseCase, repo repository.UserTokenRepository,
+	jwtService service.JwtService, passwordService service.PasswordService, mailer mail.Mailer, cfg config.AccountConfig) AccountUseCase {
+	return &accountUseCase{userUc: userUc, attemptUc: attemptUc, auditUc: auditUc, privacyUc: privacyUc, sessionUc: sessionUc, repo: repo, jwtService: jwtService,
+		passwordService: passwordService, mailer: mailer, cfg: cfg}
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// discardMailer accepts every mail without sending it
+type discardMailer struct{}
+
+func (discardMailer) Send(to, template string, data map[string]interface{}) error {
+	return nil
+}
+
+func TestErasedUserLeavesNoPersonalDataInAuditLog(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+	jwtService := newTestJwtService()
+	passwordService := service.NewPasswordService(config.PasswordConfig{MinLength: 8, HashAlgorithm: "bcrypt", BcryptCost: bcrypt.MinCost})
+	auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+	userUc := NewUserUseCase(repository.NewUserRepository(db))
+	attemptUc := NewLoginAttemptUseCase(repository.NewLoginAttemptRepository(db), userUc, auditUc, testLoginConfig)
+	sessionUc := NewSessionUseCase(repository.NewSessionRepository(db), auditUc, jwtService, config.SessionConfig{TTL: time.Hour, TouchInterval: time.Minute})
+	privacyUc := NewPrivacyUseCase(userUc, auditUc, repository.NewDataExportRepository(db), jwtService,
+		config.PrivacyConfig{ExportDir: t.TempDir(), PseudonymKey: []byte("pseudonym-key")})
+	accountUc := NewAccountUseCase(userUc, attemptUc, auditUc, privacyUc, sessionUc, repository.NewUserTokenRepository(db),
+		jwtService, passwordService, discardMailer{}, config.AccountConfig{VerifyEmailTTL: time.Hour, ResetPasswordTTL: time.Hour})
+	authUc := NewAuthUseCase(userUc, attemptUc, nil, accountUc, sessionUc, auditUc, jwtService, passwordService)
+	admin := createTestUser(t, db, entity.User{Email: "admin@example.com", Password: "hash", Role: "admin"})
+
+	user, err := authUc.Register(ctx, dto.AuthRequestRegisterDto{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com",
+		Password: "correct horse battery", PasswordConfirm: "correct horse battery", Role: "customer"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	email, firstName, lastName := "countess@example.com", "Augusta", "King"
+	_, err = accountUc.UpdateProfile(ctx, user.ID, dto.ProfileUpdateRequestDto{FirstName: &firstName, Email: &email, CurrentPassword: "correct horse battery"}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("update profile: %v", err)
+	}
+	if _, err := accountUc.UpdateUser(ctx, admin.ID, user.ID, dto.UserUpdateRequestDto{LastName: &lastName}, "192.0.2.1"); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	if err := accountUc.SetActive(ctx, admin.ID, user.ID, false, "192.0.2.1"); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	_, err = accountUc.BulkUpdate(ctx, admin.ID, dto.UserBulkRequestDto{Filter: dto.UserFilterDto{Query: email}, Action: "activate"}, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("bulk update: %v", err)
+	}
+	if err := privacyUc.Erase(ctx, admin.ID, user.ID, entity.ErasureChannelAdmin, "192.0.2.1"); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+
+	var entries []entity.AuditLog
+	if err := db.Find(&entries).Error; err != nil {
+		t.Fatalf("find entries: %v", err)
+	}
+	if len(entries) < 6 {
+		t.Fatalf("%d audit entries, want one per write", len(entries))
+	}
+	for _, entry := range entries {
+		for _, personal := range []string{"ada@example.com", email, "Ada", "Lovelace", firstName, lastName} {
+			for field, value := range map[string]string{"target_id": entry.TargetID, "details": entry.Details, "changes": entry.Changes} {
+				if strings.Contains(strings.ToLower(value), strings.ToLower(personal)) {
+					t.Errorf("entry %d %s keeps %q in %s: %s", entry.ID, entry.Action, personal, field, value)
+				}
+			}
+		}
+	}
+
+	verified, err := auditUc.VerifyAuditLogs(ctx)
+	if err != nil || !verified.Intact {
+		t.Errorf("verify = %+v, %v, want an intact chain", verified, err)
+	}
+}
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+)
+
+// APIKeyPrefix starts every API key, keys look like grk_<prefix>_<secret>
+const APIKeyPrefix = "grk_"
+
+// apiKeyTouchInterval limits the writes of last used tracking for busy keys
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrInvalidAPIKey   = apperror.Unauthenticated("invalid, expired or revoked API key")
+	ErrAPIKeyIPDenied  = apperror.Forbidden("the API key is not allowed from this IP")
+	ErrAPIKeyForbidden = apperror.Forbidden("only the owner or an admin can revoke this API key")
+)
+
+type APIKeyUseCase interface {
+	// CreateKey returns the new key with its secret, the secret can not be shown again
+	CreateKey(ctx context.Context, userID uint, payload dto.APIKeyCreateRequestDto, ip string) (dto.APIKeyCreatedResponseDto, error)
+	FindKeys(ctx context.Context, userID uint) ([]dto.APIKeyResponseDto, error)
+	RevokeKey(ctx context.Context, actorID uint, isAdmin bool, id uint, ip string) error
+	// Authenticate checks key and the IP it is used from and returns its owner and scopes
+	Authenticate(ctx context.Context, key, ip string) (dto.UserWithProducts, []string, error)
+}
+
+type apiKeyUseCase struct {
+	repo    repository.APIKeyRepository
+	userUc  UserUseCase
+	auditUc AuditUseCase
+}
+
+// CreateKey implements APIKeyUseCase.
+func (a *apiKeyUseCase) CreateKey(ctx context.Context, userID uint, payload dto.APIKeyCreateRequestDto, ip string) (dto.APIKeyCreatedResponseDto, error) {
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		message := "expires_at must be in the future"
+		return dto.APIKeyCreatedResponseDto{}, apperror.InvalidFields(message, []apperror.FieldError{
+			{Field: "expires_at", Rule: "future", Message: message},
+		})
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return dto.APIKeyCreatedResponseDto{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return dto.APIKeyCreatedResponseDto{}, err
+	}
+
+	key, err := a.repo.Create(ctx, entity.APIKey{
+		UserID:     userID,
+		Name:       strings.TrimSpace(payload.Name),
+		Prefix:     prefix,
+		SecretHash: hashSecret(secret),
+		Scopes:     strings.Join(uniqueStrings(payload.Scopes), ","),
+		AllowedIPs: strings.Join(uniqueStrings(payload.AllowedIPs), ","),
+		ExpiresAt:  payload.ExpiresAt,
+	})
+	if err != nil {
+		return dto.APIKeyCreatedResponseDto{}, err
+	}
+
+	a.record(ctx, userID, key, entity.AuditAPIKeyCreate, ip)
+	return dto.APIKeyCreatedResponseDto{
+		APIKeyResponseDto: dto.ConvertAPIKeyToResponse(key),
+		Key:               APIKeyPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+// FindKeys implements APIKeyUseCase.
+func (a *apiKeyUseCase) FindKeys(ctx context.Context, userID uint) ([]dto.APIKeyResponseDto, error) {
+	keys, err := a.repo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responseKeys := make([]dto.APIKeyResponseDto, len(keys))
+	for i, key := range keys {
+		responseKeys[i] = dto.ConvertAPIKeyToResponse(key)
+	}
+	return responseKeys, nil
+}
+
+// RevokeKey implements APIKeyUseCase.
+func (a *apiKeyUseCase) RevokeKey(ctx context.Context, actorID uint, isAdmin bool, id uint, ip string) error {
+	key, err := a.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !isAdmin && key.UserID != actorID {
+		return ErrAPIKeyForbidden
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	if err := a.repo.Revoke(ctx, key.ID); err != nil {
+		return err
+	}
+	a.record(ctx, actorID, key, entity.AuditAPIKeyRevoke, ip)
+	return nil
+}
+
+// Authenticate implements APIKeyUseCase. Unknown, wrong, revoked and expired keys all fail the same way.
+func (a *apiKeyUseCase) Authenticate(ctx context.Context, key, ip string) (dto.UserWithProducts, []string, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, APIKeyPrefix) {
+		return dto.UserWithProducts{}, nil, ErrInvalidAPIKey
+	}
+
+	stored, err := a.repo.FindByPrefix(ctx, prefix)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return dto.UserWithProducts{}, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return dto.UserWithProducts{}, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(stored.SecretHash)) != 1 ||
+		stored.RevokedAt != nil || (stored.ExpiresAt != nil && time.Now().After(*stored.ExpiresAt)) {
+		return dto.UserWithProducts{}, nil, ErrInvalidAPIKey
+	}
+
+	response := dto.ConvertAPIKeyToResponse(stored)
+	if !ipAllowed(ip, response.AllowedIPs) {
+		return dto.UserWithProducts{}, nil, ErrAPIKeyIPDenied
+	}
+
+	// The key acts with the current role of its owner and dies with their account
+	user, err := a.userUc.FindUserByID(ctx, stored.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) || (err == nil && user.DeactivatedAt != nil) {
+		return dto.UserWithProducts{}, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return dto.UserWithProducts{}, nil, err
+	}
+
+	if err := a.repo.Touch(ctx, stored.ID, ip, apiKeyTouchInterval); err != nil {
+		slog.ErrorContext(ctx, "apiKeyUseCase.Authenticate", "err", err)
+	}
+	return user, response.Scopes, nil
+}
+
+func (a *apiKeyUseCase) record(ctx context.Context, actorID uint, key entity.APIKey, action, ip string) {
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "api_key",
+		TargetID:   strconv.FormatUint(uint64(key.ID), 10),
+		IP:         ip,
+		Details:    "prefix: " + key.Prefix + ", owner: " + strconv.FormatUint(uint64(key.UserID), 10),
+	})
+}
+
+// hashSecret is how API key secrets, OAuth secrets, codes and refresh tokens are stored
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ipAllowed reports whether ip matches one of the IPs or CIDR ranges, an empty list allows every IP
+func ipAllowed(ip string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(parsed) {
+				return true
+			}
+		} else if other := net.ParseIP(entry); other != nil && other.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func NewAPIKeyUseCase(repo repository.APIKeyRepository, userUc UserUseCase, auditUc AuditUseCase) APIKeyUseCase {
+	return &apiKeyUseCase{repo: repo, userUc: userUc, auditUc: auditUc}
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strconv"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
+	"github.com/altsaqif/go-rest/cmd/shared/requestinfo"
+)
+
+// auditSkippedFields are left out of the changes of an entry, they change with every write or are derived
+var auditSkippedFields = map[string]bool{
+	"CreatedAt": true, "UpdatedAt": true, "DeletedAt": true, "users": true, "products": true,
+	"average_rating": true, "review_count": true, "original_price": true,
+}
+
+// auditRedactedFields only show that they changed. Entries can not be edited, so personal data written into
+// them could never be erased with the user.
+var auditRedactedFields = map[string]bool{"password": true, "email": true, "firstname": true, "lastname": true}
+
+type AuditUseCase interface {
+	// Record stores an audit entry, failures are logged so auditing never breaks the audited operation. The
+	// request ID, the IP and the actor missing from entry are taken from the request info of ctx; under
+	// impersonation the admin is the actor.
+	Record(ctx context.Context, entry entity.AuditLog)
+	FindAuditLogs(ctx context.Context, spec query.Spec) ([]entity.AuditLog, model.Paging, error)
+	// VerifyAuditLogs checks the hash chain of every entry
+	VerifyAuditLogs(ctx context.Context) (dto.AuditLogVerifyResponseDto, error)
+}
+
+type auditUseCase struct {
+	repo repository.AuditLogRepository
+}
+
+// Record implements AuditUseCase.
+func (a *auditUseCase) Record(ctx context.Context, entry entity.AuditLog) {
+	info := requestinfo.FromContext(ctx)
+	if entry.RequestID == "" {
+		entry.RequestID = info.RequestID
+	}
+	if entry.IP == "" {
+		entry.IP = info.IP
+	}
+	switch {
+	case info.ImpersonatorID != 0 && (entry.ActorID == nil || *entry.ActorID == info.UserID):
+		entry.ActorID = &info.ImpersonatorID
+		if entry.Details != "" {
+			entry.Details += ", "
+		}
+		entry.Details += "impersonating: " + strconv.FormatUint(uint64(info.UserID), 10)
+	case entry.ActorID == nil && info.UserID != 0:
+		entry.ActorID = &info.UserID
+	}
+
+	if _, err := a.repo.Create(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "auditUseCase.Record", "action", entry.Action, "err", err)
+	}
+}
+
+// FindAuditLogs implements AuditUseCase.
+func (a *auditUseCase) FindAuditLogs(ctx context.Context, spec query.Spec) ([]entity.AuditLog, model.Paging, error) {
+	return a.repo.FindAll(ctx, spec)
+}
+
+// VerifyAuditLogs implements AuditUseCase.
+func (a *auditUseCase) VerifyAuditLogs(ctx context.Context) (dto.AuditLogVerifyResponseDto, error) {
+	checked, brokenAt, err := a.repo.Verify(ctx)
+	if err != nil {
+		return dto.AuditLogVerifyResponseDto{}, err
+	}
+	if brokenAt != nil {
+		slog.WarnContext(ctx, "auditUseCase.VerifyAuditLogs: chain broken", "entry", *brokenAt)
+	}
+	return dto.AuditLogVerifyResponseDto{Intact: brokenAt == nil, Checked: checked, BrokenAt: brokenAt}, nil
+}
+
+// auditChanges returns the fields that differ between the JSON of before and after as the changes of an audit
+// entry, before is nil for creations and after for deletions
+func auditChanges(before, after interface{}) string {
+	beforeFields, afterFields := auditFields(before), auditFields(after)
+
+	changes := map[string]map[string]interface{}{}
+	for _, fields := range []map[string]interface{}{beforeFields, afterFields} {
+		for field := range fields {
+			if _, seen := changes[field]; seen || auditSkippedFields[field] {
+				continue
+			}
+			oldValue, newValue := beforeFields[field], afterFields[field]
+			if reflect.DeepEqual(oldValue, newValue) {
+				continue
+			}
+			if auditRedactedFields[field] {
+				oldValue, newValue = redactAudit(oldValue), redactAudit(newValue)
+			}
+			changes[field] = map[string]interface{}{"before": oldValue, "after": newValue}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		slog.Error("auditChanges", "err", err)
+		return ""
+	}
+	return string(encoded)
+}
+
+// redactAudit hides a value but keeps whether it was set
+func redactAudit(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return "[redacted]"
+}
+
+func auditFields(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields
+	}
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(encoded, &fields)
+	}
+	if err != nil {
+		slog.Error("auditFields", "err", err)
+	}
+	return fields
+}
+
+func NewAuditUseCase(repo repository.AuditLogRepository) AuditUseCase {
+	return &auditUseCase{repo: repo}
+}
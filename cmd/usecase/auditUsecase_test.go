@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"gorm.io/gorm"
+)
+
+func TestVerifyAuditLogs(t *testing.T) {
+	// tamper changes the chain behind the back of the hooks and returns the ID of the first entry it breaks
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint
+	}{
+		{name: "intact"},
+		{
+			name: "edited entry",
+			tamper: func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint {
+				tamper(t, db.Model(&entries[1]).UpdateColumn("details", "nothing happened"))
+				return entries[1].ID
+			},
+		},
+		{
+			name: "removed entry",
+			tamper: func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint {
+				tamper(t, db.Exec("DELETE FROM audit_logs WHERE id = ?", entries[1].ID))
+				return entries[2].ID
+			},
+		},
+		{
+			name: "forged erasure",
+			tamper: func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint {
+				tamper(t, db.Model(&entries[1]).UpdateColumn("target_id", entity.AuditErasedPrefix+strings.Repeat("0", 64)))
+				return entries[1].ID
+			},
+		},
+		{
+			name: "erasure of another target",
+			tamper: func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint {
+				// The subject exists, but its erasure did not replace this target
+				tamper(t, db.Model(&entries[1]).UpdateColumn("target_id", entries[0].TargetID))
+				return entries[1].ID
+			},
+		},
+		{
+			name: "removed erasure record",
+			tamper: func(t *testing.T, db *gorm.DB, entries []entity.AuditLog) uint {
+				tamper(t, db.Exec("DELETE FROM erasure_records"))
+				return entries[0].ID
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.New(t)
+			ctx := context.Background()
+			auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+			userUc := NewUserUseCase(repository.NewUserRepository(db))
+			privacyUc := NewPrivacyUseCase(userUc, auditUc, repository.NewDataExportRepository(db), newTestJwtService(),
+				config.PrivacyConfig{ExportDir: t.TempDir(), PseudonymKey: []byte("pseudonym-key")})
+			admin := createTestUser(t, db, entity.User{Email: "admin@example.com", Password: "hash", Role: "admin"})
+			user := createTestUser(t, db, entity.User{Email: "user@example.com", Password: "hash"})
+
+			auditUc.Record(ctx, entity.AuditLog{Action: entity.AuditLoginLockout, TargetType: "account", TargetID: "User@example.com"})
+			auditUc.Record(ctx, entity.AuditLog{Action: entity.AuditLoginLockout, TargetType: "account", TargetID: "other@example.com"})
+			if err := privacyUc.Erase(ctx, admin.ID, user.ID, entity.ErasureChannelAdmin, "192.0.2.1"); err != nil {
+				t.Fatalf("erase: %v", err)
+			}
+			var entries []entity.AuditLog
+			if err := db.Order("id").Find(&entries).Error; err != nil {
+				t.Fatalf("find entries: %v", err)
+			}
+			if len(entries) != 3 || !strings.HasPrefix(entries[0].TargetID, entity.AuditErasedPrefix) {
+				t.Fatalf("entries %+v, want 3 with the first erased", entries)
+			}
+
+			var brokenAt uint
+			if tt.tamper != nil {
+				brokenAt = tt.tamper(t, db, entries)
+			}
+			got, err := auditUc.VerifyAuditLogs(ctx)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if brokenAt == 0 {
+				if !got.Intact || got.Checked != int64(len(entries)) || got.BrokenAt != nil {
+					t.Errorf("verify = %+v, want all %d entries intact", got, len(entries))
+				}
+				return
+			}
+			if got.Intact || got.BrokenAt == nil || *got.BrokenAt != brokenAt {
+				t.Errorf("verify = %+v, want broken at %d", got, brokenAt)
+			}
+		})
+	}
+}
+
+// tamper fails the test when the change to the chain did not go through
+func tamper(t *testing.T, result *gorm.DB) {
+	t.Helper()
+	if result.Error != nil || result.RowsAffected == 0 {
+		t.Fatalf("tamper: %v, %d rows", result.Error, result.RowsAffected)
+	}
+}
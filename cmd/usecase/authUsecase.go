@@ -1,90 +1,201 @@
 package usecase
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
 	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
 	"github.com/altsaqif/go-rest/cmd/shared/service"
-	"github.com/altsaqif/go-rest/cmd/utils"
 )
 
+// ErrInvalidCredentials is returned by Login for an unknown email or a wrong password alike
+var ErrInvalidCredentials = apperror.Unauthenticated("invalid email or password")
+
+// ErrAccountDeactivated is returned by Login for the right password of a deactivated user
+var ErrAccountDeactivated = apperror.Forbidden("account is deactivated")
+
+// ErrInvalidChallenge is returned by LoginTwoFactor for a missing, expired or forged challenge token
+var ErrInvalidChallenge = apperror.Unauthenticated("invalid or expired challenge token")
+
 type AuthUseCase interface {
-	Login(payload dto.AuthRequestLoginDto) (dto.AuthResponseDto, error)
-	Register(payload dto.AuthRequestRegisterDto) (dto.UserWithProducts, error)
-	FindUserByEmail(email string) (dto.UserWithProducts, error)
+	// Login returns the token of a new session, or a challenge token for LoginTwoFactor when the user has 2FA enabled
+	Login(ctx context.Context, payload dto.AuthRequestLoginDto, ip, userAgent string) (dto.AuthResponseDto, error)
+	LoginTwoFactor(ctx context.Context, payload dto.TwoFactorLoginRequestDto, ip, userAgent string) (dto.AuthResponseDto, error)
+	SetupTwoFactor(ctx context.Context, userID uint) (dto.TwoFactorSetupResponseDto, error)
+	// VerifyTwoFactor enables 2FA and returns a token of the current session that already counts as a 2FA login
+	VerifyTwoFactor(ctx context.Context, userID uint, sessionID, code string) (dto.TwoFactorVerifyResponseDto, error)
+	Register(ctx context.Context, payload dto.AuthRequestRegisterDto) (dto.UserWithProducts, error)
+	FindUserByEmail(ctx context.Context, email string) (dto.UserWithProducts, error)
 }
 
 type authUseCase struct {
-	uc         UserUseCase
-	jwtService service.JwtService
+	uc              UserUseCase
+	attemptUc       LoginAttemptUseCase
+	twoFactorUc     TwoFactorUseCase
+	accountUc       AccountUseCase
+	sessionUc       SessionUseCase
+	auditUc         AuditUseCase
+	jwtService      service.JwtService
+	passwordService service.PasswordService
+	// dummyHash is verified for unknown emails so they take as long as a wrong password
+	dummyHash string
 }
 
 // GetUserByEmail implements AuthUseCase.
-func (a *authUseCase) FindUserByEmail(email string) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+func (a *authUseCase) FindUserByEmail(ctx context.Context, email string) (dto.UserWithProducts, error) {
+	return a.uc.FindUserByEmail(ctx, email)
+}
+
+func (a *authUseCase) Login(ctx context.Context, payload dto.AuthRequestLoginDto, ip, userAgent string) (dto.AuthResponseDto, error) {
+	if err := a.attemptUc.Check(ctx, payload.Email, ip); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	user, err := a.uc.FindUserByEmail(ctx, payload.Email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		a.passwordService.Verify(payload.Password, a.dummyHash)
+		a.attemptUc.Fail(ctx, payload.Email, ip)
+		return dto.AuthResponseDto{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return dto.AuthResponseDto{}, err
 	}
+	ok, rehash := a.passwordService.Verify(payload.Password, user.Password)
+	if !ok {
+		a.attemptUc.Fail(ctx, payload.Email, ip)
+		return dto.AuthResponseDto{}, ErrInvalidCredentials
+	}
+	a.attemptUc.Succeed(ctx, payload.Email)
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := a.uc.FindUserByEmail(email)
-		resultChan <- result{user, err}
-	}()
+	// Only the right password learns that the account is deactivated or the email unverified
+	if user.DeactivatedAt != nil {
+		return dto.AuthResponseDto{}, ErrAccountDeactivated
+	}
+	if err := a.accountUc.CheckVerified(user); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
 
-	res := <-resultChan
-	return res.user, res.err
+	// Hashes from an outdated algorithm or cost are upgraded while the plain password is at hand
+	if rehash {
+		if hashed, err := a.passwordService.Hash(payload.Password); err != nil {
+			slog.ErrorContext(ctx, "authUseCase.Login: rehash", "err", err)
+		} else if err := a.uc.UpdatePassword(ctx, user.ID, hashed); err != nil {
+			slog.ErrorContext(ctx, "authUseCase.Login: rehash", "err", err)
+		}
+	}
+
+	if user.TwoFactorEnabled {
+		return a.jwtService.CreateChallengeToken(user)
+	}
+	return a.sessionUc.StartSession(ctx, user, false, ip, userAgent)
 }
 
-func (a *authUseCase) Login(payload dto.AuthRequestLoginDto) (dto.AuthResponseDto, error) {
-	type result struct {
-		token dto.AuthResponseDto
-		err   error
+// LoginTwoFactor implements AuthUseCase, wrong codes count as failed logins of the account.
+func (a *authUseCase) LoginTwoFactor(ctx context.Context, payload dto.TwoFactorLoginRequestDto, ip, userAgent string) (dto.AuthResponseDto, error) {
+	userID, err := a.jwtService.ParseChallengeToken(payload.ChallengeToken)
+	if err != nil {
+		return dto.AuthResponseDto{}, ErrInvalidChallenge
+	}
+	user, err := a.uc.FindUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return dto.AuthResponseDto{}, ErrInvalidChallenge
+	}
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	if err := a.attemptUc.Check(ctx, user.Email, ip); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	err = a.twoFactorUc.Check(ctx, user, payload.Code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		a.attemptUc.Fail(ctx, user.Email, ip)
+		return dto.AuthResponseDto{}, err
 	}
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	a.attemptUc.Succeed(ctx, user.Email)
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := a.uc.FindUserByEmail(payload.Email)
-		if err != nil || !utils.CheckPasswordHash(payload.Password, user.Password) {
-			resultChan <- result{dto.AuthResponseDto{}, err}
-			return
-		}
+	return a.sessionUc.StartSession(ctx, user, true, ip, userAgent)
+}
+
+// SetupTwoFactor implements AuthUseCase.
+func (a *authUseCase) SetupTwoFactor(ctx context.Context, userID uint) (dto.TwoFactorSetupResponseDto, error) {
+	return a.twoFactorUc.Setup(ctx, userID)
+}
+
+// VerifyTwoFactor implements AuthUseCase.
+func (a *authUseCase) VerifyTwoFactor(ctx context.Context, userID uint, sessionID, code string) (dto.TwoFactorVerifyResponseDto, error) {
+	recoveryCodes, err := a.twoFactorUc.Enable(ctx, userID, code)
+	if err != nil {
+		return dto.TwoFactorVerifyResponseDto{}, err
+	}
 
-		token, err := a.jwtService.CreateToken(user)
-		resultChan <- result{token, err}
-	}()
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &userID,
+		Action:     entity.AuditTwoFactorEnable,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		Changes:    auditChanges(map[string]bool{"two_factor_enabled": false}, map[string]bool{"two_factor_enabled": true}),
+	})
 
-	res := <-resultChan
-	return res.token, res.err
+	user, err := a.uc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.TwoFactorVerifyResponseDto{}, err
+	}
+	token, err := a.sessionUc.RenewSession(ctx, user, sessionID, true)
+	if err != nil {
+		return dto.TwoFactorVerifyResponseDto{}, err
+	}
+	return dto.TwoFactorVerifyResponseDto{Token: token.Token, RecoveryCodes: recoveryCodes}, nil
 }
 
-func (a *authUseCase) Register(payload dto.AuthRequestRegisterDto) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+func (a *authUseCase) Register(ctx context.Context, payload dto.AuthRequestRegisterDto) (dto.UserWithProducts, error) {
+	if err := a.passwordService.Validate(payload.Password, payload.Email, payload.FirstName, payload.LastName); err != nil {
+		return dto.UserWithProducts{}, err
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		hashedPassword, err := utils.HashPassword(payload.Password)
-		if err != nil {
-			resultChan <- result{dto.UserWithProducts{}, err}
-			return
-		}
+	hashedPassword, err := a.passwordService.Hash(payload.Password)
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
 
-		user, err := a.uc.RegisterNewUser(entity.User{
-			FirstName: payload.FirstName,
-			LastName:  payload.LastName,
-			Email:     payload.Email,
-			Password:  hashedPassword,
-			Role:      payload.Role,
-		})
-		resultChan <- result{user, err}
-	}()
-
-	res := <-resultChan
-	return res.user, res.err
+	user, err := a.uc.RegisterNewUser(ctx, entity.User{
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Email:     payload.Email,
+		Password:  hashedPassword,
+		Role:      payload.Role,
+	})
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	a.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &user.ID,
+		Action:     entity.AuditUserRegister,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Changes:    auditChanges(nil, user),
+	})
+
+	// The user can ask for another verification mail, so a failed one does not fail the registration
+	if err := a.accountUc.SendVerification(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "authUseCase.Register: verification mail", "err", err)
+	}
+	return user, nil
 }
 
-func NewAuthUseCase(uc UserUseCase, jwtService service.JwtService) AuthUseCase {
-	return &authUseCase{uc: uc, jwtService: jwtService}
+func NewAuthUseCase(uc UserUseCase, attemptUc LoginAttemptUseCase, twoFactorUc TwoFactorUseCase, accountUc AccountUseCase, sessionUc SessionUseCase, auditUc AuditUseCase, jwtService service.JwtService, passwordService service.PasswordService) AuthUseCase {
+	// The dummy hash belongs to no user, matching it can not log anyone in
+	dummyHash, err := passwordService.Hash("dummy password for unknown emails")
+	if err != nil {
+		slog.Error("NewAuthUseCase", "err", err)
+	}
+	return &authUseCase{uc: uc, attemptUc: attemptUc, twoFactorUc: twoFactorUc, accountUc: accountUc, sessionUc: sessionUc, auditUc: auditUc, jwtService: jwtService, passwordService: passwordService, dummyHash: dummyHash}
 }
@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+)
+
+// ErrCouponInvalid is returned when a coupon payload is malformed or a coupon cannot be applied
+var ErrCouponInvalid = apperror.Validation("invalid coupon")
+
+type CouponUseCase interface {
+	CreateCoupon(ctx context.Context, payload dto.CouponRequestDto) (dto.CouponResponseDto, error)
+	FindCouponByID(ctx context.Context, id uint) (dto.CouponResponseDto, error)
+	FindAllCoupons(ctx context.Context, page, size int) ([]dto.CouponResponseDto, model.Paging, error)
+	UpdateCoupon(ctx context.Context, id uint, payload dto.CouponRequestDto) (dto.CouponResponseDto, error)
+	DeleteCoupon(ctx context.Context, id uint) error
+	ValidateCoupon(ctx context.Context, userID uint, payload dto.CouponValidateRequestDto) (dto.CouponValidateResponseDto, error)
+	Rule() repository.CouponRule
+}
+
+type couponUseCase struct {
+	repo        repository.CouponRepository
+	productRepo repository.ProductRepository
+}
+
+// CreateCoupon implements CouponUseCase.
+func (c *couponUseCase) CreateCoupon(ctx context.Context, payload dto.CouponRequestDto) (dto.CouponResponseDto, error) {
+	coupon, productIDs, err := buildCoupon(payload)
+	if err != nil {
+		return dto.CouponResponseDto{}, err
+	}
+
+	created, err := c.repo.Create(ctx, coupon, productIDs)
+	if err != nil {
+		return dto.CouponResponseDto{}, err
+	}
+	return dto.ConvertCouponToResponse(created), nil
+}
+
+// FindCouponByID implements CouponUseCase.
+func (c *couponUseCase) FindCouponByID(ctx context.Context, id uint) (dto.CouponResponseDto, error) {
+	coupon, err := c.repo.FindByID(ctx, id)
+	if err != nil {
+		return dto.CouponResponseDto{}, err
+	}
+	return dto.ConvertCouponToResponse(coupon), nil
+}
+
+// FindAllCoupons implements CouponUseCase.
+func (c *couponUseCase) FindAllCoupons(ctx context.Context, page, size int) ([]dto.CouponResponseDto, model.Paging, error) {
+	coupons, paging, err := c.repo.FindAll(ctx, page, size)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	responseCoupons := make([]dto.CouponResponseDto, len(coupons))
+	for i, coupon := range coupons {
+		responseCoupons[i] = dto.ConvertCouponToResponse(coupon)
+	}
+	return responseCoupons, paging, nil
+}
+
+// UpdateCoupon implements CouponUseCase.
+func (c *couponUseCase) UpdateCoupon(ctx context.Context, id uint, payload dto.CouponRequestDto) (dto.CouponResponseDto, error) {
+	coupon, productIDs, err := buildCoupon(payload)
+	if err != nil {
+		return dto.CouponResponseDto{}, err
+	}
+
+	updated, err := c.repo.UpdateByID(ctx, id, coupon, productIDs)
+	if err != nil {
+		return dto.CouponResponseDto{}, err
+	}
+	return dto.ConvertCouponToResponse(updated), nil
+}
+
+// DeleteCoupon implements CouponUseCase.
+func (c *couponUseCase) DeleteCoupon(ctx context.Context, id uint) error {
+	return c.repo.DeleteByID(ctx, id)
+}
+
+// ValidateCoupon implements CouponUseCase, it checks a coupon without redeeming it.
+func (c *couponUseCase) ValidateCoupon(ctx context.Context, userID uint, payload dto.CouponValidateRequestDto) (dto.CouponValidateResponseDto, error) {
+	code := normalizeCouponCode(payload.Code)
+	coupon, err := c.repo.FindByCode(ctx, code)
+	if err != nil {
+		return dto.CouponValidateResponseDto{}, err
+	}
+
+	found, err := c.productRepo.FindByID(ctx, payload.ProductID)
+	if err != nil {
+		return dto.CouponValidateResponseDto{}, err
+	}
+	product := entity.Product{Category: found.Category, Price: found.Price}
+	product.ID = found.ID
+
+	redeemed, err := c.repo.CountRedemptions(ctx, coupon.ID, userID)
+	if err != nil {
+		return dto.CouponValidateResponseDto{}, err
+	}
+
+	discount, err := couponDiscount(coupon, product, redeemed, time.Now())
+	if err != nil {
+		return dto.CouponValidateResponseDto{}, err
+	}
+
+	return dto.CouponValidateResponseDto{
+		Code:       coupon.Code,
+		ProductID:  product.ID,
+		Price:      product.Price,
+		Discount:   discount,
+		FinalPrice: money.New(product.Price.Amount-discount.Amount, product.Price.Currency),
+	}, nil
+}
+
+// Rule implements CouponUseCase, the returned rule is evaluated while the coupon row is locked.
+func (c *couponUseCase) Rule() repository.CouponRule {
+	return func(coupon entity.Coupon, product entity.Product, redeemed int64) (money.Money, error) {
+		return couponDiscount(coupon, product, redeemed, time.Now())
+	}
+}
+
+// couponDiscount checks every coupon restriction and returns the discount for one unit of product
+func couponDiscount(coupon entity.Coupon, product entity.Product, redeemed int64, now time.Time) (money.Money, error) {
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return money.Money{}, fmt.Errorf("%w: coupon is not active yet", ErrCouponInvalid)
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return money.Money{}, fmt.Errorf("%w: coupon has expired", ErrCouponInvalid)
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return money.Money{}, repository.ErrCouponExhausted
+	}
+	if coupon.PerUserLimit > 0 && redeemed >= int64(coupon.PerUserLimit) {
+		return money.Money{}, fmt.Errorf("%w: coupon usage limit per user has been reached", ErrCouponInvalid)
+	}
+	if !couponCoversProduct(coupon, product) {
+		return money.Money{}, fmt.Errorf("%w: coupon does not apply to this product", ErrCouponInvalid)
+	}
+
+	price := product.Price
+	if coupon.MinOrder.Amount > 0 {
+		if coupon.MinOrder.Currency != price.Currency {
+			return money.Money{}, fmt.Errorf("%w: coupon is not valid for %s prices", ErrCouponInvalid, price.Currency)
+		}
+		if price.Amount < coupon.MinOrder.Amount {
+			return money.Money{}, fmt.Errorf("%w: minimum order value is %s %s", ErrCouponInvalid, coupon.MinOrder.String(), coupon.MinOrder.Currency)
+		}
+	}
+
+	var amount int64
+	switch coupon.Type {
+	case entity.CouponTypePercentage:
+		amount = (price.Amount*int64(coupon.PercentOff) + 50) / 100
+	case entity.CouponTypeFixed:
+		if coupon.AmountOff.Currency != price.Currency {
+			return money.Money{}, fmt.Errorf("%w: coupon is not valid for %s prices", ErrCouponInvalid, price.Currency)
+		}
+		amount = coupon.AmountOff.Amount
+	}
+	if amount > price.Amount {
+		amount = price.Amount
+	}
+
+	return money.New(amount, price.Currency), nil
+}
+
+func couponCoversProduct(coupon entity.Coupon, product entity.Product) bool {
+	if len(coupon.Products) == 0 && coupon.Category == "" {
+		return true
+	}
+	for _, scoped := range coupon.Products {
+		if scoped.ID == product.ID {
+			return true
+		}
+	}
+	return coupon.Category != "" && strings.EqualFold(coupon.Category, product.Category)
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// buildCoupon validates the request payload and maps it to a Coupon entity
+func buildCoupon(payload dto.CouponRequestDto) (entity.Coupon, []uint, error) {
+	coupon := entity.Coupon{
+		Code:         normalizeCouponCode(payload.Code),
+		Type:         strings.ToLower(payload.Type),
+		UsageLimit:   payload.UsageLimit,
+		PerUserLimit: payload.PerUserLimit,
+		StartsAt:     payload.StartsAt,
+		EndsAt:       payload.EndsAt,
+		Category:     strings.TrimSpace(payload.Category),
+	}
+
+	if coupon.Code == "" {
+		return entity.Coupon{}, nil, fmt.Errorf("%w: code is required", ErrCouponInvalid)
+	}
+
+	switch coupon.Type {
+	case entity.CouponTypePercentage:
+		if payload.PercentOff < 1 || payload.PercentOff > 100 {
+			return entity.Coupon{}, nil, fmt.Errorf("%w: percent_off must be between 1 and 100", ErrCouponInvalid)
+		}
+		coupon.PercentOff = payload.PercentOff
+	case entity.CouponTypeFixed:
+		if payload.AmountOff == nil || payload.AmountOff.Amount <= 0 {
+			return entity.Coupon{}, nil, fmt.Errorf("%w: amount_off must be positive", ErrCouponInvalid)
+		}
+		coupon.AmountOff = *payload.AmountOff
+	default:
+		return entity.Coupon{}, nil, fmt.Errorf("%w: type must be %q or %q", ErrCouponInvalid, entity.CouponTypePercentage, entity.CouponTypeFixed)
+	}
+
+	if payload.MinOrder != nil {
+		if payload.MinOrder.Amount < 0 {
+			return entity.Coupon{}, nil, fmt.Errorf("%w: min_order must not be negative", ErrCouponInvalid)
+		}
+		coupon.MinOrder = *payload.MinOrder
+	}
+	if coupon.UsageLimit < 0 || coupon.PerUserLimit < 0 {
+		return entity.Coupon{}, nil, fmt.Errorf("%w: usage limits must not be negative", ErrCouponInvalid)
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		return entity.Coupon{}, nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrCouponInvalid)
+	}
+
+	seen := make(map[uint]bool)
+	productIDs := []uint{}
+	for _, id := range payload.ProductIDs {
+		if !seen[id] {
+			seen[id] = true
+			productIDs = append(productIDs, id)
+		}
+	}
+
+	return coupon, productIDs, nil
+}
+
+func NewCouponUseCase(repo repository.CouponRepository, productRepo repository.ProductRepository) CouponUseCase {
+	return &couponUseCase{repo: repo, productRepo: productRepo}
+}
@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+)
+
+type EnrollmentUseCase interface {
+	Enroll(ctx context.Context, userID, productID uint, payload dto.EnrollmentRequestDto) (dto.EnrollmentResponseDto, error)
+}
+
+type enrollmentUseCase struct {
+	repo     repository.EnrollmentRepository
+	couponUc CouponUseCase
+}
+
+// Enroll implements EnrollmentUseCase.
+func (e *enrollmentUseCase) Enroll(ctx context.Context, userID, productID uint, payload dto.EnrollmentRequestDto) (dto.EnrollmentResponseDto, error) {
+	enrollment, err := e.repo.Enroll(ctx, userID, productID, normalizeCouponCode(payload.CouponCode), e.couponUc.Rule())
+	if err != nil {
+		return dto.EnrollmentResponseDto{}, err
+	}
+	return dto.ConvertEnrollmentToResponse(enrollment), nil
+}
+
+func NewEnrollmentUseCase(repo repository.EnrollmentRepository, couponUc CouponUseCase) EnrollmentUseCase {
+	return &enrollmentUseCase{repo: repo, couponUc: couponUc}
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"gorm.io/gorm"
+)
+
+// ErrExchangeRateNotFound is returned when no rate is configured between two currencies
+var ErrExchangeRateNotFound = apperror.Validation("exchange rate not found")
+
+type ExchangeRateUseCase interface {
+	SetRate(ctx context.Context, payload dto.ExchangeRateRequestDto) (entity.ExchangeRate, error)
+	FindAllRates(ctx context.Context) ([]entity.ExchangeRate, error)
+	Convert(ctx context.Context, price money.Money, currency string) (money.Money, error)
+	FindRate(ctx context.Context, base, quote string) (*big.Rat, error)
+}
+
+type exchangeRateUseCase struct {
+	repo repository.ExchangeRateRepository
+}
+
+// SetRate implements ExchangeRateUseCase.
+func (e *exchangeRateUseCase) SetRate(ctx context.Context, payload dto.ExchangeRateRequestDto) (entity.ExchangeRate, error) {
+	base := strings.ToUpper(payload.BaseCurrency)
+	quote := strings.ToUpper(payload.QuoteCurrency)
+	if !money.IsSupported(base) || !money.IsSupported(quote) {
+		return entity.ExchangeRate{}, apperror.Validation(fmt.Sprintf("unsupported currency pair %s/%s", payload.BaseCurrency, payload.QuoteCurrency))
+	}
+	if base == quote {
+		return entity.ExchangeRate{}, apperror.Validation("base and quote currency must differ")
+	}
+	rate, err := money.ParseRate(payload.Rate)
+	if err != nil {
+		return entity.ExchangeRate{}, apperror.Validation(err.Error())
+	}
+
+	return e.repo.Upsert(ctx, entity.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          rate.FloatString(10),
+	})
+}
+
+// FindAllRates implements ExchangeRateUseCase.
+func (e *exchangeRateUseCase) FindAllRates(ctx context.Context) ([]entity.ExchangeRate, error) {
+	return e.repo.FindAll(ctx)
+}
+
+// Convert implements ExchangeRateUseCase using a direct rate or the inverse of the opposite pair.
+func (e *exchangeRateUseCase) Convert(ctx context.Context, price money.Money, currency string) (money.Money, error) {
+	currency = strings.ToUpper(currency)
+	if !money.IsSupported(currency) {
+		return money.Money{}, apperror.Validation(fmt.Sprintf("unsupported currency %q", currency))
+	}
+	if price.Currency == currency {
+		return price, nil
+	}
+
+	rate, err := e.FindRate(ctx, price.Currency, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return price.Convert(rate, currency)
+}
+
+// FindRate implements ExchangeRateUseCase, it falls back to the inverse of the opposite pair.
+func (e *exchangeRateUseCase) FindRate(ctx context.Context, base, quote string) (*big.Rat, error) {
+	direct, err := e.repo.FindByPair(ctx, base, quote)
+	if err == nil {
+		return money.ParseRate(direct.Rate)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	inverse, err := e.repo.FindByPair(ctx, quote, base)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateNotFound, base, quote)
+		}
+		return nil, err
+	}
+	rate, err := money.ParseRate(inverse.Rate)
+	if err != nil {
+		return nil, err
+	}
+	return rate.Inv(rate), nil
+}
+
+func NewExchangeRateUseCase(repo repository.ExchangeRateRepository) ExchangeRateUseCase {
+	return &exchangeRateUseCase{repo: repo}
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+func newTestJwtService() service.JwtService {
+	return service.NewJwtService(config.TokenConfig{
+		IssuerName:              "test",
+		JwtSignatureKey:         []byte("signature-key"),
+		JwtSigningMethod:        jwt.SigningMethodHS256,
+		JwtExpiresTime:          time.Hour,
+		JwtChallengeExpiresTime: time.Minute,
+	})
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, user entity.User) dto.UserWithProducts {
+	t.Helper()
+	if user.FirstName == "" {
+		user.FirstName, user.LastName = "Test", "User"
+	}
+	if user.Role == "" {
+		user.Role = "customer"
+	}
+	created, err := repository.NewUserRepository(db).Create(context.Background(), user)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return created
+}
+
+func findTestUser(t *testing.T, db *gorm.DB, email string) entity.User {
+	t.Helper()
+	var user entity.User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		t.Fatalf("find user %s: %v", email, err)
+	}
+	return user
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+// ErrImpersonationRefused is returned by Impersonate for admins, their rights would pass to whoever impersonates them
+var ErrImpersonationRefused = apperror.Forbidden("admins can not be impersonated")
+
+type ImpersonationUseCase interface {
+	// Impersonate returns a token of the user for the admin actor, it expires after the impersonation TTL and
+	// is revoked along with the tokens of either of them
+	Impersonate(ctx context.Context, actorID, userID uint, ip string) (dto.ImpersonationResponseDto, error)
+	// RecordRequest writes a request made with an impersonation token to the audit log
+	RecordRequest(ctx context.Context, actorID, userID uint, method, path string, status int, ip string)
+}
+
+type impersonationUseCase struct {
+	userUc     UserUseCase
+	auditUc    AuditUseCase
+	jwtService service.JwtService
+	cfg        config.ImpersonationConfig
+}
+
+// Impersonate implements ImpersonationUseCase.
+func (i *impersonationUseCase) Impersonate(ctx context.Context, actorID, userID uint, ip string) (dto.ImpersonationResponseDto, error) {
+	actor, err := i.userUc.FindUserByID(ctx, actorID)
+	if err != nil {
+		return dto.ImpersonationResponseDto{}, err
+	}
+	user, err := i.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.ImpersonationResponseDto{}, err
+	}
+	if user.Role == "admin" {
+		return dto.ImpersonationResponseDto{}, ErrImpersonationRefused
+	}
+	if user.DeactivatedAt != nil {
+		return dto.ImpersonationResponseDto{}, ErrAccountDeactivated
+	}
+
+	expiresAt := time.Now().Add(i.cfg.TTL)
+	token, err := i.jwtService.CreateImpersonationToken(user, actor, i.cfg.TTL)
+	if err != nil {
+		return dto.ImpersonationResponseDto{}, err
+	}
+
+	i.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actor.ID,
+		Action:     entity.AuditImpersonationStart,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+		Details:    "expires: " + expiresAt.UTC().Format(time.RFC3339),
+	})
+	return dto.ImpersonationResponseDto{Token: token, UserID: user.ID, ExpiresAt: expiresAt}, nil
+}
+
+// RecordRequest implements ImpersonationUseCase.
+func (i *impersonationUseCase) RecordRequest(ctx context.Context, actorID, userID uint, method, path string, status int, ip string) {
+	i.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     entity.AuditImpersonationRequest,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IP:         ip,
+		Details:    fmt.Sprintf("%s %s %d", method, path, status),
+	})
+}
+
+func NewImpersonationUseCase(userUc UserUseCase, auditUc AuditUseCase, jwtService service.JwtService, cfg config.ImpersonationConfig) ImpersonationUseCase {
+	return &impersonationUseCase{userUc: userUc, auditUc: auditUc, jwtService: jwtService, cfg: cfg}
+}
@@ -0,0 +1,473 @@
+package usecase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// maxImportLineSize bounds the memory one NDJSON line can take, product rows are far smaller
+const maxImportLineSize = 1 << 20
+
+var (
+	// ErrImportFormat is returned for an import format other than CSV or NDJSON
+	ErrImportFormat = apperror.Validation("unsupported import format")
+	// ErrImportInvalid is returned when the import file cannot be read at all, e.g. a missing CSV header
+	ErrImportInvalid = apperror.Validation("invalid import file")
+	// ErrImportForbidden is returned when a user looks up an import started by someone else
+	ErrImportForbidden = apperror.Forbidden("only the owner can view this import")
+
+	// ErrImportLineTooLong is returned for an NDJSON line above maxImportLineSize, the rows after it are not read
+	ErrImportLineTooLong = apperror.Validation(fmt.Sprintf("NDJSON lines must be at most %d bytes", maxImportLineSize))
+
+	// errMalformedRow marks a single record that could not be decoded, the import goes on with the next one
+	errMalformedRow = errors.New("malformed row")
+)
+
+type ImportUseCase interface {
+	ImportProducts(ctx context.Context, userID uint, format string, body io.Reader, size int64, async bool) (dto.ImportJobResponseDto, error)
+	FindImport(ctx context.Context, userID uint, isAdmin bool, id uint) (dto.ImportJobResponseDto, error)
+}
+
+type importUseCase struct {
+	repo            repository.ImportJobRepository
+	productRepo     repository.ProductRepository
+	auditUc         AuditUseCase
+	cfg             config.ImportConfig
+	defaultCurrency string
+}
+
+// ImportProducts implements ImportUseCase. Small bodies are imported while the request waits, bodies of
+// unknown size or above the async threshold are spooled to a temporary file and imported in the background.
+func (i *importUseCase) ImportProducts(ctx context.Context, userID uint, format string, body io.Reader, size int64, async bool) (dto.ImportJobResponseDto, error) {
+	if format != ImportFormatCSV && format != ImportFormatNDJSON {
+		return dto.ImportJobResponseDto{}, ErrImportFormat
+	}
+
+	if async || size < 0 || size > i.cfg.AsyncThreshold {
+		return i.importInBackground(ctx, userID, format, body)
+	}
+
+	job, err := i.repo.Create(ctx, entity.ImportJob{UserID: userID, Format: format, Status: entity.ImportStatusRunning})
+	if err != nil {
+		return dto.ImportJobResponseDto{}, err
+	}
+
+	if err := i.process(ctx, &job, body); errors.Is(err, ErrImportInvalid) {
+		return dto.ImportJobResponseDto{}, err
+	}
+	return dto.ConvertImportJobToResponse(job), nil
+}
+
+// FindImport implements ImportUseCase.
+func (i *importUseCase) FindImport(ctx context.Context, userID uint, isAdmin bool, id uint) (dto.ImportJobResponseDto, error) {
+	job, err := i.repo.FindByID(ctx, id)
+	if err != nil {
+		return dto.ImportJobResponseDto{}, err
+	}
+	if !isAdmin && job.UserID != userID {
+		return dto.ImportJobResponseDto{}, ErrImportForbidden
+	}
+	return dto.ConvertImportJobToResponse(job), nil
+}
+
+// importInBackground copies the body to disk so the request can finish, then imports the file
+func (i *importUseCase) importInBackground(ctx context.Context, userID uint, format string, body io.Reader) (dto.ImportJobResponseDto, error) {
+	file, err := os.CreateTemp(i.cfg.TempDir, "product-import-*")
+	if err != nil {
+		return dto.ImportJobResponseDto{}, err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return dto.ImportJobResponseDto{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return dto.ImportJobResponseDto{}, err
+	}
+
+	job, err := i.repo.Create(ctx, entity.ImportJob{UserID: userID, Format: format, Status: entity.ImportStatusPending})
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return dto.ImportJobResponseDto{}, err
+	}
+
+	// The job outlives the request, so it keeps the request values but not its deadline
+	background := context.WithoutCancel(ctx)
+	go func(job entity.ImportJob) {
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		job.Status = entity.ImportStatusRunning
+		if err := i.repo.Save(background, job); err != nil {
+			slog.ErrorContext(background, "importUseCase.importInBackground", "err", err)
+		}
+		i.process(background, &job, file)
+	}(job)
+
+	return dto.ConvertImportJobToResponse(job), nil
+}
+
+// process reads every row of body, upserts valid rows in batches and stores the per-row report on the job
+func (i *importUseCase) process(ctx context.Context, job *entity.ImportJob, body io.Reader) error {
+	report := []dto.ImportRowResultDto{}
+	err := i.importRows(ctx, job, body, &report)
+
+	job.Total = len(report)
+	job.Created, job.Updated, job.Failed = 0, 0, 0
+	for _, row := range report {
+		switch row.Action {
+		case dto.ImportActionCreated:
+			job.Created++
+		case dto.ImportActionUpdated:
+			job.Updated++
+		default:
+			job.Failed++
+		}
+	}
+
+	job.Status = entity.ImportStatusCompleted
+	if err != nil {
+		job.Status = entity.ImportStatusFailed
+		job.Error = apperror.Message(err)
+		slog.ErrorContext(ctx, "importUseCase.process", "err", err)
+	}
+	if encoded, err := json.Marshal(report); err == nil {
+		job.Report = string(encoded)
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+
+	// The report is stored even when the rows ran out of time
+	if err := i.repo.Save(context.WithoutCancel(ctx), *job); err != nil {
+		slog.ErrorContext(ctx, "importUseCase.process", "err", err)
+	}
+	return err
+}
+
+func (i *importUseCase) importRows(ctx context.Context, job *entity.ImportJob, body io.Reader, report *[]dto.ImportRowResultDto) error {
+	reader, err := newProductRowReader(job.Format, body)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]entity.Product, 0, i.cfg.BatchSize)
+	batchRows := make([]int, 0, i.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		results, err := i.productRepo.UpsertBatch(ctx, batch)
+		if err != nil {
+			slog.ErrorContext(ctx, "importUseCase.importRows", "err", err)
+		}
+		// The row that rolled back the batch gets its error, the others are told which row it was
+		failed := -1
+		var upsertErr *repository.UpsertError
+		if errors.As(err, &upsertErr) {
+			failed = upsertErr.Index
+		}
+		for j, index := range batchRows {
+			row := &(*report)[index]
+			switch {
+			case j == failed:
+				row.Action = dto.ImportActionFailed
+				row.Error = apperror.Message(upsertErr.Err)
+			case failed >= 0:
+				row.Action = dto.ImportActionFailed
+				row.Error = fmt.Sprintf("not imported, row %d rolled back its batch", (*report)[batchRows[failed]].Row)
+			case err != nil:
+				row.Action = dto.ImportActionFailed
+				row.Error = apperror.Message(err)
+			case results[j].Created:
+				row.Action = dto.ImportActionCreated
+				row.ProductID = results[j].ID
+				i.record(ctx, job, entity.AuditProductCreate, results[j].ID, nil, dto.ConvertProductToResponse(results[j].After))
+			default:
+				row.Action = dto.ImportActionUpdated
+				row.ProductID = results[j].ID
+				i.record(ctx, job, entity.AuditProductUpdate, results[j].ID,
+					dto.ConvertProductToResponse(results[j].Before), dto.ConvertProductToResponse(results[j].After))
+			}
+		}
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+	}
+
+	for number := 1; ; number++ {
+		raw, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		result := dto.ImportRowResultDto{Row: number, SKU: strings.TrimSpace(raw.SKU), Name: strings.TrimSpace(raw.Name)}
+		if err != nil && !errors.Is(err, errMalformedRow) {
+			flush()
+			return err
+		}
+
+		var product entity.Product
+		if err == nil {
+			product, err = i.buildImportProduct(raw)
+		}
+		if err != nil {
+			result.Action = dto.ImportActionFailed
+			result.Error = err.Error()
+			*report = append(*report, result)
+			continue
+		}
+
+		*report = append(*report, result)
+		batch = append(batch, product)
+		batchRows = append(batchRows, len(*report)-1)
+		if len(batch) >= i.cfg.BatchSize {
+			flush()
+		}
+	}
+
+	flush()
+	return nil
+}
+
+// record audits a write of an imported product like productUseCase does, the user who started the job is the
+// actor since background imports outlive the request
+func (i *importUseCase) record(ctx context.Context, job *entity.ImportJob, action string, id uint, before, after interface{}) {
+	i.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &job.UserID,
+		Action:     action,
+		TargetType: "product",
+		TargetID:   strconv.FormatUint(uint64(id), 10),
+		Details:    "import: " + strconv.FormatUint(uint64(job.ID), 10),
+		Changes:    auditChanges(before, after),
+	})
+}
+
+// buildImportProduct validates a raw row and maps it to a Product entity
+func (i *importUseCase) buildImportProduct(row dto.ProductImportRow) (entity.Product, error) {
+	product := entity.Product{
+		Name:        strings.TrimSpace(row.Name),
+		Description: strings.TrimSpace(row.Description),
+		Category:    strings.TrimSpace(row.Category),
+	}
+
+	if sku := strings.TrimSpace(row.SKU); sku != "" {
+		if len(sku) > 64 {
+			return entity.Product{}, fmt.Errorf("sku must be at most 64 characters")
+		}
+		product.SKU = &sku
+	}
+	if product.Name == "" {
+		return entity.Product{}, fmt.Errorf("name is required")
+	}
+	if len(product.Category) > 100 {
+		return entity.Product{}, fmt.Errorf("category must be at most 100 characters")
+	}
+
+	stock, err := strconv.Atoi(strings.TrimSpace(row.Stock))
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("stock must be a whole number")
+	}
+	if stock < 0 {
+		return entity.Product{}, fmt.Errorf("stock must not be negative")
+	}
+	product.Stock = stock
+
+	currency := strings.TrimSpace(row.Currency)
+	if currency == "" {
+		currency = i.defaultCurrency
+	}
+	price, err := money.Parse(row.Price, currency)
+	if err != nil {
+		return entity.Product{}, fmt.Errorf("price: %v", err)
+	}
+	product.Price = price
+
+	return product, nil
+}
+
+// productRowReader yields import rows one at a time, it returns io.EOF after the last row and an error
+// wrapping errMalformedRow for a record that could not be decoded
+type productRowReader interface {
+	Next() (dto.ProductImportRow, error)
+}
+
+func newProductRowReader(format string, body io.Reader) (productRowReader, error) {
+	switch format {
+	case ImportFormatCSV:
+		return newCsvRowReader(body)
+	case ImportFormatNDJSON:
+		return newNdjsonRowReader(body), nil
+	default:
+		return nil, ErrImportFormat
+	}
+}
+
+// csvRowReader maps CSV records to rows by the column names of the header line
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCsvRowReader(body io.Reader) (*csvRowReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file is empty", ErrImportInvalid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportInvalid, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = index
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("%w: the header has no name column", ErrImportInvalid)
+	}
+
+	return &csvRowReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvRowReader) Next() (dto.ProductImportRow, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return dto.ProductImportRow{}, fmt.Errorf("%w: %v", errMalformedRow, err)
+		}
+		return dto.ProductImportRow{}, err
+	}
+
+	field := func(name string) string {
+		index, ok := c.columns[name]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return record[index]
+	}
+
+	return dto.ProductImportRow{
+		SKU:         field("sku"),
+		Name:        field("name"),
+		Description: field("description"),
+		Category:    field("category"),
+		Stock:       field("stock"),
+		Price:       field("price"),
+		Currency:    field("currency"),
+	}, nil
+}
+
+// ndjsonRowReader decodes one JSON object per line, blank lines are skipped
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNdjsonRowReader(body io.Reader) *ndjsonRowReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	return &ndjsonRowReader{scanner: scanner}
+}
+
+func (n *ndjsonRowReader) Next() (dto.ProductImportRow, error) {
+	for n.scanner.Scan() {
+		line := n.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		return decodeNdjsonRow(line)
+	}
+
+	err := n.scanner.Err()
+	switch {
+	case errors.Is(err, bufio.ErrTooLong):
+		return dto.ProductImportRow{}, ErrImportLineTooLong
+	case err != nil:
+		return dto.ProductImportRow{}, err
+	default:
+		return dto.ProductImportRow{}, io.EOF
+	}
+}
+
+func decodeNdjsonRow(line []byte) (dto.ProductImportRow, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return dto.ProductImportRow{}, fmt.Errorf("%w: %v", errMalformedRow, err)
+	}
+
+	var row dto.ProductImportRow
+	var err error
+	text := func(name string) string {
+		value, ok := fields[name]
+		if !ok || err != nil {
+			return ""
+		}
+		switch v := value.(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		case json.Number:
+			return v.String()
+		default:
+			err = fmt.Errorf("%w: %s must be a string or a number", errMalformedRow, name)
+			return ""
+		}
+	}
+
+	row.SKU = text("sku")
+	row.Name = text("name")
+	row.Description = text("description")
+	row.Category = text("category")
+	row.Stock = text("stock")
+	row.Currency = text("currency")
+
+	// price is either a plain decimal or a Money object {"amount":"12.34","currency":"USD"}
+	if price, ok := fields["price"].(map[string]interface{}); ok {
+		fields = price
+		row.Price = text("amount")
+		if currency := text("currency"); currency != "" {
+			row.Currency = currency
+		}
+	} else {
+		row.Price = text("price")
+	}
+
+	return row, err
+}
+
+func NewImportUseCase(repo repository.ImportJobRepository, productRepo repository.ProductRepository, auditUc AuditUseCase, cfg config.ImportConfig, defaultCurrency string) ImportUseCase {
+	return &importUseCase{repo: repo, productRepo: productRepo, auditUc: auditUc, cfg: cfg, defaultCurrency: defaultCurrency}
+}
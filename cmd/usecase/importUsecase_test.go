@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"gorm.io/gorm"
+)
+
+func newTestImportUseCase(t *testing.T, batchSize int) (ImportUseCase, *gorm.DB) {
+	db := testdb.New(t)
+	cfg := config.ImportConfig{BatchSize: batchSize, AsyncThreshold: 1 << 30, TempDir: t.TempDir()}
+	auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+	uc := NewImportUseCase(repository.NewImportJobRepository(db), repository.NewProductRepository(db, nil), auditUc, cfg, "USD")
+	return uc, db
+}
+
+func createTestProduct(t *testing.T, db *gorm.DB, product entity.Product) entity.Product {
+	t.Helper()
+	if product.Price.IsZero() {
+		product.Price = money.New(100, "USD")
+	}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+func findTestProduct(t *testing.T, db *gorm.DB, id uint) entity.Product {
+	t.Helper()
+	var product entity.Product
+	if err := db.First(&product, id).Error; err != nil {
+		t.Fatalf("find product %d: %v", id, err)
+	}
+	return product
+}
+
+func importProducts(t *testing.T, uc ImportUseCase, format, body string) dto.ImportJobResponseDto {
+	t.Helper()
+	job, err := uc.ImportProducts(context.Background(), 1, format, strings.NewReader(body), int64(len(body)), false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	return job
+}
+
+// importRow is the expected report of one row, error is a part of its message
+type importRow struct {
+	action string
+	error  string
+}
+
+func checkImportRows(t *testing.T, job dto.ImportJobResponseDto, want []importRow) {
+	t.Helper()
+	if len(job.Rows) != len(want) {
+		t.Fatalf("rows %+v, want %d", job.Rows, len(want))
+	}
+	for i, row := range job.Rows {
+		if row.Row != i+1 || row.Action != want[i].action || !strings.Contains(row.Error, want[i].error) || (want[i].error == "") != (row.Error == "") {
+			t.Errorf("row %d = %+v, want %s %q", i+1, row, want[i].action, want[i].error)
+		}
+	}
+}
+
+func TestImportProductsMixedRows(t *testing.T) {
+	tests := []struct {
+		format string
+		body   string
+		// invalid is the error of the last row, which each format rejects its own way
+		invalid string
+	}{
+		{
+			format: ImportFormatCSV,
+			body: "sku,name,description,category,stock,price,currency\n" +
+				"MUG-1,Mug v2,Bigger,kitchen,5,12.50,\n" +
+				",Poster,,art,3,4.00,USD\n" +
+				"TEE-1,Tee,,apparel,2,-1,\n" +
+				"BAD-1,Bad \"quote,,,1,1.00,\n" +
+				"CUP-1,Cup,,kitchen,0,1500,JPY\n" +
+				"HAT-1,,,apparel,1,1.00,\n",
+			invalid: "name is required",
+		},
+		{
+			format: ImportFormatNDJSON,
+			body: `{"sku":"MUG-1","name":"Mug v2","description":"Bigger","category":"kitchen","stock":5,"price":"12.50"}` + "\n" +
+				`{"name":"Poster","category":"art","stock":"3","price":{"amount":"4.00","currency":"USD"}}` + "\n" +
+				`{"sku":"TEE-1","name":"Tee","stock":2,"price":"-1"}` + "\n" +
+				"\n" +
+				`{"sku":"BAD-1","name":` + "\n" +
+				`{"sku":"CUP-1","name":"Cup","category":"kitchen","stock":0,"price":1500,"currency":"JPY"}` + "\n" +
+				`{"sku":"HAT-1","name":"Hat","stock":true,"price":"1.00"}`,
+			invalid: "stock must be a string or a number",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			uc, db := newTestImportUseCase(t, 2)
+			sku := "MUG-1"
+			mug := createTestProduct(t, db, entity.Product{SKU: &sku, Name: "Mug", Stock: 1})
+			poster := createTestProduct(t, db, entity.Product{Name: "Poster"})
+
+			job := importProducts(t, uc, tt.format, tt.body)
+			if job.Status != entity.ImportStatusCompleted || job.Total != 6 || job.Created != 1 || job.Updated != 2 || job.Failed != 3 {
+				t.Errorf("job %+v, want 6 rows: 1 created, 2 updated, 3 failed", job)
+			}
+			checkImportRows(t, job, []importRow{
+				{action: dto.ImportActionUpdated},
+				{action: dto.ImportActionUpdated},
+				{action: dto.ImportActionFailed, error: "must not be negative"},
+				{action: dto.ImportActionFailed, error: "malformed row"},
+				{action: dto.ImportActionCreated},
+				{action: dto.ImportActionFailed, error: tt.invalid},
+			})
+
+			// Rows with a SKU match by SKU and may rename the product, rows without one match by name
+			if job.Rows[0].ProductID != mug.ID || job.Rows[1].ProductID != poster.ID {
+				t.Errorf("matched products %d and %d, want %d and %d", job.Rows[0].ProductID, job.Rows[1].ProductID, mug.ID, poster.ID)
+			}
+			if got := findTestProduct(t, db, mug.ID); got.Name != "Mug v2" || got.Stock != 5 || got.Price != money.New(1250, "USD") {
+				t.Errorf("mug %+v", got)
+			}
+			if got := findTestProduct(t, db, poster.ID); got.SKU != nil || got.Stock != 3 || got.Price != money.New(400, "USD") {
+				t.Errorf("poster %+v", got)
+			}
+			if got := findTestProduct(t, db, job.Rows[4].ProductID); got.SKU == nil || *got.SKU != "CUP-1" || got.Price != money.New(1500, "JPY") {
+				t.Errorf("cup %+v", got)
+			}
+
+			var count int64
+			db.Model(&entity.Product{}).Count(&count)
+			if count != 3 {
+				t.Errorf("%d products, want 3", count)
+			}
+		})
+	}
+}
+
+func TestImportProductsBatchRollback(t *testing.T) {
+	uc, db := newTestImportUseCase(t, 2)
+	// The SKU of a deleted product is still taken, writing it fails inside the batch
+	sku := "OLD-1"
+	deleted := createTestProduct(t, db, entity.Product{SKU: &sku, Name: "Old"})
+	if err := db.Delete(&deleted).Error; err != nil {
+		t.Fatalf("delete product: %v", err)
+	}
+
+	job := importProducts(t, uc, ImportFormatCSV, "sku,name,stock,price\n"+
+		"NEW-1,New,1,1.00\n"+
+		"OLD-1,Old again,1,1.00\n"+
+		"NEXT-1,Next,1,1.00\n")
+	checkImportRows(t, job, []importRow{
+		{action: dto.ImportActionFailed, error: "row 2 rolled back its batch"},
+		{action: dto.ImportActionFailed, error: repository.ErrSKUTaken.Message},
+		{action: dto.ImportActionCreated},
+	})
+	if job.Status != entity.ImportStatusCompleted || job.Created != 1 || job.Failed != 2 {
+		t.Errorf("job %+v, want 1 created and 2 failed", job)
+	}
+
+	var count int64
+	db.Model(&entity.Product{}).Where("sku = ?", "NEW-1").Count(&count)
+	if count != 0 {
+		t.Error("the rolled back batch left its first row behind")
+	}
+}
+
+func TestImportProductsNdjsonLineLimit(t *testing.T) {
+	uc, db := newTestImportUseCase(t, 2)
+
+	// Lines above the default buffer of bufio.Scanner are read, lines above maxImportLineSize stop the import
+	long := strings.Repeat("a", 100*1024)
+	job := importProducts(t, uc, ImportFormatNDJSON,
+		`{"sku":"LONG-1","name":"Long","description":"`+long+`","stock":1,"price":"1.00"}`+"\n"+
+			`{"sku":"HUGE-1","name":"Huge","description":"`+strings.Repeat("a", maxImportLineSize)+`","stock":1,"price":"1.00"}`+"\n"+
+			`{"sku":"AFTER-1","name":"After","stock":1,"price":"1.00"}`+"\n")
+
+	if job.Status != entity.ImportStatusFailed || job.Error != ErrImportLineTooLong.Message {
+		t.Errorf("job status %s, error %q, want failed with %q", job.Status, job.Error, ErrImportLineTooLong.Message)
+	}
+	checkImportRows(t, job, []importRow{{action: dto.ImportActionCreated}})
+	if got := findTestProduct(t, db, job.Rows[0].ProductID); got.Description != long {
+		t.Errorf("description of %d bytes, want %d", len(got.Description), len(long))
+	}
+}
+
+func TestImportProductsAudit(t *testing.T) {
+	uc, db := newTestImportUseCase(t, 2)
+	sku := "MUG-1"
+	mug := createTestProduct(t, db, entity.Product{SKU: &sku, Name: "Mug", Stock: 1})
+
+	job := importProducts(t, uc, ImportFormatCSV, "sku,name,stock,price\n"+
+		"MUG-1,Mug,4,1.00\n"+
+		"CUP-1,Cup,2,3.00\n"+
+		"BAD-1,,1,1.00\n")
+
+	var entries []entity.AuditLog
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatalf("find entries: %v", err)
+	}
+	// Only the written rows are audited, each as the single create or update of the product would be
+	want := []struct {
+		action   string
+		targetID uint
+		changes  string
+	}{
+		{action: entity.AuditProductUpdate, targetID: mug.ID, changes: `{"stock":{"after":4,"before":1}}`},
+		{action: entity.AuditProductCreate, targetID: job.Rows[1].ProductID, changes: `"sku":{"after":"CUP-1","before":null}`},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("%d audit entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Action != want[i].action || entry.TargetID != strconv.FormatUint(uint64(want[i].targetID), 10) ||
+			!strings.Contains(entry.Changes, want[i].changes) || entry.ActorID == nil || *entry.ActorID != 1 ||
+			entry.Details != "import: "+strconv.FormatUint(uint64(job.ID), 10) {
+			t.Errorf("entry %d = %+v, want %s of %d with %s", i+1, entry, want[i].action, want[i].targetID, want[i].changes)
+		}
+	}
+
+	verified, err := NewAuditUseCase(repository.NewAuditLogRepository(db)).VerifyAuditLogs(context.Background())
+	if err != nil || !verified.Intact || verified.Checked != int64(len(want)) {
+		t.Errorf("verify = %+v, %v, want %d chained entries", verified, err, len(want))
+	}
+}
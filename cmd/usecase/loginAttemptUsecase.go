@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+)
+
+type LoginAttemptUseCase interface {
+	// Check fails while the account or the client IP is locked, or the account is still backing off
+	Check(ctx context.Context, email, ip string) error
+	// Fail counts a failed login against the account and the client IP
+	Fail(ctx context.Context, email, ip string)
+	// Succeed forgets the failed logins of the account
+	Succeed(ctx context.Context, email string)
+	Unlock(ctx context.Context, actorID, userID uint, ip string) error
+}
+
+type loginAttemptUseCase struct {
+	repo    repository.LoginAttemptRepository
+	userUc  UserUseCase
+	auditUc AuditUseCase
+	cfg     config.LoginConfig
+}
+
+// Check implements LoginAttemptUseCase.
+func (l *loginAttemptUseCase) Check(ctx context.Context, email, ip string) error {
+	attempts, err := l.repo.FindByKeys(ctx, accountKey(email), ipKey(ip))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if w := l.wait(attempt, now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return apperror.TooManyRequests("too many failed login attempts, try again later", wait)
+	}
+	return nil
+}
+
+// wait returns how long the next attempt has to wait, only accounts back off between failures
+func (l *loginAttemptUseCase) wait(attempt entity.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if l.stale(attempt, now) || !strings.HasPrefix(attempt.Key, "email:") {
+		return 0
+	}
+
+	next := attempt.LastFailureAt.Add(l.backoff(attempt.Failures))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// backoff doubles BackoffBase for every failure after the first, capped at BackoffMax
+func (l *loginAttemptUseCase) backoff(failures int) time.Duration {
+	delay := l.cfg.BackoffBase
+	for i := 1; i < failures && delay < l.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > l.cfg.BackoffMax {
+		delay = l.cfg.BackoffMax
+	}
+	return delay
+}
+
+// stale reports whether the failures are too old to count or their lockout has expired
+func (l *loginAttemptUseCase) stale(attempt entity.LoginAttempt, now time.Time) bool {
+	if attempt.LastFailureAt == nil || now.Sub(*attempt.LastFailureAt) > l.cfg.FailureWindow {
+		return true
+	}
+	return attempt.LockedUntil != nil && !now.Before(*attempt.LockedUntil)
+}
+
+// Fail implements LoginAttemptUseCase.
+func (l *loginAttemptUseCase) Fail(ctx context.Context, email, ip string) {
+	l.fail(ctx, accountKey(email), l.cfg.MaxFailures, "account", strings.ToLower(strings.TrimSpace(email)), ip)
+	l.fail(ctx, ipKey(ip), l.cfg.IPMaxFailures, "ip", ip, ip)
+}
+
+func (l *loginAttemptUseCase) fail(ctx context.Context, key string, limit int, targetType, targetID, ip string) {
+	now := time.Now()
+	locked := false
+	attempt, err := l.repo.Update(ctx, key, func(attempt *entity.LoginAttempt) {
+		if l.stale(*attempt, now) {
+			attempt.Failures = 0
+			attempt.LockedUntil = nil
+		}
+		attempt.Failures++
+		attempt.LastFailureAt = &now
+		if attempt.Failures >= limit && attempt.LockedUntil == nil {
+			until := now.Add(l.cfg.Lockout)
+			attempt.LockedUntil = &until
+			locked = true
+		}
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "loginAttemptUseCase.Fail", "key", key, "err", err)
+		return
+	}
+
+	if locked {
+		l.auditUc.Record(ctx, entity.AuditLog{
+			Action:     entity.AuditLoginLockout,
+			TargetType: targetType,
+			TargetID:   targetID,
+			IP:         ip,
+			Details:    fmt.Sprintf("locked until %s after %d failed logins", attempt.LockedUntil.UTC().Format(time.RFC3339), attempt.Failures),
+		})
+	}
+}
+
+// Succeed implements LoginAttemptUseCase.
+func (l *loginAttemptUseCase) Succeed(ctx context.Context, email string) {
+	if _, err := l.repo.DeleteByKey(ctx, accountKey(email)); err != nil {
+		slog.ErrorContext(ctx, "loginAttemptUseCase.Succeed", "err", err)
+	}
+}
+
+// Unlock implements LoginAttemptUseCase.
+func (l *loginAttemptUseCase) Unlock(ctx context.Context, actorID, userID uint, ip string) error {
+	user, err := l.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	unlocked, err := l.repo.DeleteByKey(ctx, accountKey(user.Email))
+	if err != nil {
+		return err
+	}
+
+	l.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     entity.AuditLoginUnlock,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IP:         ip,
+		Details:    fmt.Sprintf("cleared failed logins: %t", unlocked),
+	})
+	return nil
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func NewLoginAttemptUseCase(repo repository.LoginAttemptRepository, userUc UserUseCase, auditUc AuditUseCase, cfg config.LoginConfig) LoginAttemptUseCase {
+	return &loginAttemptUseCase{repo: repo, userUc: userUc, auditUc: auditUc, cfg: cfg}
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"gorm.io/gorm"
+)
+
+var testLoginConfig = config.LoginConfig{
+	MaxFailures:   3,
+	IPMaxFailures: 5,
+	FailureWindow: time.Hour,
+	Lockout:       15 * time.Minute,
+	BackoffBase:   time.Second,
+	BackoffMax:    8 * time.Second,
+}
+
+func newTestLoginAttemptUseCase(t *testing.T) (LoginAttemptUseCase, *gorm.DB) {
+	db := testdb.New(t)
+	auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+	uc := NewLoginAttemptUseCase(repository.NewLoginAttemptRepository(db), NewUserUseCase(repository.NewUserRepository(db)), auditUc, testLoginConfig)
+	return uc, db
+}
+
+// retryAfter returns the wait of a throttled check, or 0 when it passed
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	if err == nil {
+		return 0
+	}
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Kind != apperror.KindTooManyRequests {
+		t.Fatalf("err = %v, want too many requests", err)
+	}
+	return appErr.RetryAfter
+}
+
+// age moves the failures of key back in time, as if d had passed
+func age(t *testing.T, db *gorm.DB, key string, d time.Duration) {
+	t.Helper()
+	var attempt entity.LoginAttempt
+	if err := db.Where(map[string]interface{}{"key": key}).First(&attempt).Error; err != nil {
+		t.Fatalf("find attempt %s: %v", key, err)
+	}
+	if attempt.LastFailureAt != nil {
+		at := attempt.LastFailureAt.Add(-d)
+		attempt.LastFailureAt = &at
+	}
+	if attempt.LockedUntil != nil {
+		until := attempt.LockedUntil.Add(-d)
+		attempt.LockedUntil = &until
+	}
+	if err := db.Save(&attempt).Error; err != nil {
+		t.Fatalf("save attempt %s: %v", key, err)
+	}
+}
+
+func TestLoginAttemptBackoff(t *testing.T) {
+	l := &loginAttemptUseCase{cfg: testLoginConfig}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 3, want: 4 * time.Second},
+		{failures: 4, want: 8 * time.Second},
+		{failures: 5, want: 8 * time.Second},
+		{failures: 100, want: 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := l.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginAttemptCheckBacksOff(t *testing.T) {
+	uc, _ := newTestLoginAttemptUseCase(t)
+	ctx := context.Background()
+
+	if err := uc.Check(ctx, "user@example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("check before failures: %v", err)
+	}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		uc.Fail(ctx, "user@example.com", "192.0.2.1")
+		wait := retryAfter(t, uc.Check(ctx, " USER@example.com", "198.51.100.1"))
+		if wait <= want/2 || wait > want {
+			t.Errorf("wait after %d failures = %v, want up to %v", i+1, wait, want)
+		}
+	}
+
+	// Only the account backs off, other accounts of the IP are not slowed down
+	if err := uc.Check(ctx, "other@example.com", "192.0.2.1"); err != nil {
+		t.Errorf("other account: %v", err)
+	}
+}
+
+func TestLoginAttemptLockout(t *testing.T) {
+	uc, db := newTestLoginAttemptUseCase(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, entity.User{Email: "user@example.com", Password: "hash"})
+
+	for i := 0; i < testLoginConfig.MaxFailures; i++ {
+		uc.Fail(ctx, user.Email, "192.0.2.1")
+	}
+	age(t, db, accountKey(user.Email), testLoginConfig.BackoffMax)
+	if wait := retryAfter(t, uc.Check(ctx, user.Email, "198.51.100.1")); wait <= testLoginConfig.Lockout-time.Minute {
+		t.Errorf("wait = %v, want the lockout of %v", wait, testLoginConfig.Lockout)
+	}
+
+	var lockouts int64
+	db.Model(&entity.AuditLog{}).Where("action = ? AND target_type = ?", entity.AuditLoginLockout, "account").Count(&lockouts)
+	if lockouts != 1 {
+		t.Errorf("lockout audits = %d, want 1", lockouts)
+	}
+
+	// An expired lockout lets the account in and starts counting again
+	age(t, db, accountKey(user.Email), testLoginConfig.Lockout)
+	if err := uc.Check(ctx, user.Email, "198.51.100.1"); err != nil {
+		t.Errorf("check after the lockout: %v", err)
+	}
+	uc.Fail(ctx, user.Email, "198.51.100.1")
+	var attempt entity.LoginAttempt
+	db.Where(map[string]interface{}{"key": accountKey(user.Email)}).First(&attempt)
+	if attempt.Failures != 1 || attempt.LockedUntil != nil {
+		t.Errorf("after the lockout: failures %d, locked until %v, want 1 and unlocked", attempt.Failures, attempt.LockedUntil)
+	}
+}
+
+func TestLoginAttemptIPLockout(t *testing.T) {
+	uc, db := newTestLoginAttemptUseCase(t)
+	ctx := context.Background()
+
+	// Spraying one password over many accounts locks the IP before any account
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		uc.Fail(ctx, email, "192.0.2.1")
+	}
+	if wait := retryAfter(t, uc.Check(ctx, "f@example.com", "192.0.2.1")); wait == 0 {
+		t.Error("locked IP passed the check")
+	}
+	if err := uc.Check(ctx, "f@example.com", "198.51.100.1"); err != nil {
+		t.Errorf("other IP: %v", err)
+	}
+
+	var lockouts int64
+	db.Model(&entity.AuditLog{}).Where("action = ? AND target_type = ? AND target_id = ?", entity.AuditLoginLockout, "ip", "192.0.2.1").Count(&lockouts)
+	if lockouts != 1 {
+		t.Errorf("IP lockout audits = %d, want 1", lockouts)
+	}
+}
+
+func TestLoginAttemptStaleFailuresReset(t *testing.T) {
+	uc, db := newTestLoginAttemptUseCase(t)
+	ctx := context.Background()
+
+	for i := 0; i < testLoginConfig.MaxFailures-1; i++ {
+		uc.Fail(ctx, "user@example.com", "192.0.2.1")
+	}
+	age(t, db, accountKey("user@example.com"), testLoginConfig.FailureWindow+time.Minute)
+	if err := uc.Check(ctx, "user@example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("check after the window: %v", err)
+	}
+
+	// The failures before the window no longer count towards the lockout
+	uc.Fail(ctx, "user@example.com", "192.0.2.1")
+	var attempt entity.LoginAttempt
+	db.Where(map[string]interface{}{"key": accountKey("user@example.com")}).First(&attempt)
+	if attempt.Failures != 1 || attempt.LockedUntil != nil {
+		t.Errorf("failures %d, locked until %v, want 1 and unlocked", attempt.Failures, attempt.LockedUntil)
+	}
+}
+
+func TestLoginAttemptUnlock(t *testing.T) {
+	uc, db := newTestLoginAttemptUseCase(t)
+	ctx := context.Background()
+	admin := createTestUser(t, db, entity.User{Email: "admin@example.com", Password: "hash", Role: "admin"})
+	user := createTestUser(t, db, entity.User{Email: "user@example.com", Password: "hash"})
+
+	for i := 0; i < testLoginConfig.MaxFailures; i++ {
+		uc.Fail(ctx, user.Email, "192.0.2.1")
+	}
+	if wait := retryAfter(t, uc.Check(ctx, user.Email, "198.51.100.1")); wait == 0 {
+		t.Fatal("locked account passed the check")
+	}
+
+	if err := uc.Unlock(ctx, admin.ID, user.ID, "203.0.113.1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := uc.Check(ctx, user.Email, "198.51.100.1"); err != nil {
+		t.Errorf("check after unlock: %v", err)
+	}
+
+	var audit entity.AuditLog
+	if err := db.Where("action = ?", entity.AuditLoginUnlock).First(&audit).Error; err != nil {
+		t.Fatalf("unlock audit: %v", err)
+	}
+	if audit.ActorID == nil || *audit.ActorID != admin.ID || audit.Details != "cleared failed logins: true" {
+		t.Errorf("unlock audit %+v", audit)
+	}
+
+	if err := uc.Unlock(ctx, admin.ID, 999, "203.0.113.1"); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Errorf("unlock of an unknown user: err = %v, want %v", err, repository.ErrUserNotFound)
+	}
+}
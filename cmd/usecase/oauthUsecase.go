@@ -0,0 +1,579 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+var (
+	ErrOAuthClientForbidden = apperror.Forbidden("only the owner or an admin can revoke this OAuth client")
+	ErrUnknownOAuthClient   = apperror.Validation("unknown or revoked client_id")
+	ErrInvalidRedirectURI   = apperror.Validation("redirect_uri is not registered for this client")
+	ErrOAuthTokenRevoked    = apperror.Unauthenticated("the OAuth token was revoked")
+)
+
+// Error codes of RFC 6749 section 5.2
+const (
+	OAuthInvalidRequest       = "invalid_request"
+	OAuthInvalidClient        = "invalid_client"
+	OAuthInvalidGrant         = "invalid_grant"
+	OAuthUnauthorizedClient   = "unauthorized_client"
+	OAuthUnsupportedGrantType = "unsupported_grant_type"
+	OAuthInvalidScope         = "invalid_scope"
+)
+
+// OAuthError is answered by the token, introspection and revocation endpoints in the format of RFC 6749
+// instead of the usual error response, OAuth clients rely on it
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+type OAuthUseCase interface {
+	// RegisterClient returns the new client with the secret of a confidential client, it can not be shown again
+	RegisterClient(ctx context.Context, userID uint, payload dto.OAuthClientCreateRequestDto, ip string) (dto.OAuthClientCreatedResponseDto, error)
+	FindClients(ctx context.Context, userID uint) ([]dto.OAuthClientResponseDto, error)
+	// RevokeClient revokes the client and every token issued to it
+	RevokeClient(ctx context.Context, actorID uint, isAdmin bool, id, ip string) error
+	// Authorize checks an authorization request and describes what the user is asked to approve
+	Authorize(ctx context.Context, request dto.OAuthAuthorizeRequestDto) (dto.OAuthConsentDto, error)
+	// Consent answers an authorization request for the user, the URL sends them back to the client with a code
+	// or an access_denied error
+	Consent(ctx context.Context, userID uint, request dto.OAuthConsentRequestDto, ip string) (dto.OAuthRedirectDto, error)
+	Token(ctx context.Context, client dto.OAuthClientAuthDto, request dto.OAuthTokenRequestDto) (dto.OAuthTokenResponseDto, error)
+	Introspect(ctx context.Context, client dto.OAuthClientAuthDto, token string) (dto.OAuthIntrospectionDto, error)
+	// Revoke revokes the grant of an access or refresh token of the client, unknown tokens are ignored
+	Revoke(ctx context.Context, client dto.OAuthClientAuthDto, token string) error
+	// CheckAccessToken fails when the grant of an access token was revoked
+	CheckAccessToken(ctx context.Context, id string) error
+}
+
+type oauthUseCase struct {
+	repo       repository.OAuthRepository
+	userUc     UserUseCase
+	auditUc    AuditUseCase
+	jwtService service.JwtService
+	cfg        config.OAuthConfig
+}
+
+// RegisterClient implements OAuthUseCase.
+func (o *oauthUseCase) RegisterClient(ctx context.Context, userID uint, payload dto.OAuthClientCreateRequestDto, ip string) (dto.OAuthClientCreatedResponseDto, error) {
+	redirectURIs := uniqueStrings(payload.RedirectURIs)
+	for _, redirectURI := range redirectURIs {
+		if parsed, err := url.Parse(redirectURI); err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			message := "redirect_uris must be absolute URLs without a fragment"
+			return dto.OAuthClientCreatedResponseDto{}, apperror.InvalidFields(message, []apperror.FieldError{
+				{Field: "redirect_uris", Rule: "redirect_uri", Message: message},
+			})
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return dto.OAuthClientCreatedResponseDto{}, err
+	}
+	client := entity.OAuthClient{
+		ID:           id,
+		UserID:       userID,
+		Name:         strings.TrimSpace(payload.Name),
+		Type:         payload.Type,
+		RedirectURIs: strings.Join(redirectURIs, ","),
+		Scopes:       strings.Join(uniqueStrings(payload.Scopes), ","),
+	}
+
+	var secret string
+	if client.Type == entity.OAuthClientConfidential {
+		if secret, err = randomHex(32); err != nil {
+			return dto.OAuthClientCreatedResponseDto{}, err
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+
+	if client, err = o.repo.CreateClient(ctx, client); err != nil {
+		return dto.OAuthClientCreatedResponseDto{}, err
+	}
+
+	o.record(ctx, userID, client, entity.AuditOAuthClientCreate, ip, "owner: "+strconv.FormatUint(uint64(client.UserID), 10))
+	return dto.OAuthClientCreatedResponseDto{
+		OAuthClientResponseDto: dto.ConvertOAuthClientToResponse(client),
+		ClientSecret:           secret,
+	}, nil
+}
+
+// FindClients implements OAuthUseCase.
+func (o *oauthUseCase) FindClients(ctx context.Context, userID uint) ([]dto.OAuthClientResponseDto, error) {
+	clients, err := o.repo.FindClientsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responseClients := make([]dto.OAuthClientResponseDto, len(clients))
+	for i, client := range clients {
+		responseClients[i] = dto.ConvertOAuthClientToResponse(client)
+	}
+	return responseClients, nil
+}
+
+// RevokeClient implements OAuthUseCase.
+func (o *oauthUseCase) RevokeClient(ctx context.Context, actorID uint, isAdmin bool, id, ip string) error {
+	client, err := o.repo.FindClientByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !isAdmin && client.UserID != actorID {
+		return ErrOAuthClientForbidden
+	}
+	if client.RevokedAt != nil {
+		return nil
+	}
+
+	if err := o.repo.RevokeClient(ctx, client.ID); err != nil {
+		return err
+	}
+	o.record(ctx, actorID, client, entity.AuditOAuthClientRevoke, ip, "owner: "+strconv.FormatUint(uint64(client.UserID), 10))
+	return nil
+}
+
+// Authorize implements OAuthUseCase.
+func (o *oauthUseCase) Authorize(ctx context.Context, request dto.OAuthAuthorizeRequestDto) (dto.OAuthConsentDto, error) {
+	client, redirectURI, scopes, err := o.resolveRequest(ctx, request)
+	if err != nil {
+		return dto.OAuthConsentDto{}, err
+	}
+
+	return dto.OAuthConsentDto{
+		ClientID:    client.ID,
+		ClientName:  client.Name,
+		Scopes:      scopes,
+		RedirectURI: redirectURI,
+		State:       request.State,
+	}, nil
+}
+
+// Consent implements OAuthUseCase.
+func (o *oauthUseCase) Consent(ctx context.Context, userID uint, request dto.OAuthConsentRequestDto, ip string) (dto.OAuthRedirectDto, error) {
+	client, redirectURI, scopes, err := o.resolveRequest(ctx, request.OAuthAuthorizeRequestDto)
+	if err != nil {
+		return dto.OAuthRedirectDto{}, err
+	}
+
+	params := url.Values{}
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+	if !request.Approve {
+		params.Set("error", "access_denied")
+		return dto.OAuthRedirectDto{RedirectURI: withQuery(redirectURI, params)}, nil
+	}
+
+	code, err := randomHex(32)
+	if err != nil {
+		return dto.OAuthRedirectDto{}, err
+	}
+	err = o.repo.CreateCode(ctx, entity.OAuthCode{
+		ID:            hashSecret(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scopes:        strings.Join(scopes, ","),
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().Add(o.cfg.CodeTTL),
+	})
+	if err != nil {
+		return dto.OAuthRedirectDto{}, err
+	}
+
+	o.record(ctx, userID, client, entity.AuditOAuthConsent, ip, "scopes: "+strings.Join(scopes, " "))
+	params.Set("code", code)
+	return dto.OAuthRedirectDto{RedirectURI: withQuery(redirectURI, params)}, nil
+}
+
+// Token implements OAuthUseCase.
+func (o *oauthUseCase) Token(ctx context.Context, auth dto.OAuthClientAuthDto, request dto.OAuthTokenRequestDto) (dto.OAuthTokenResponseDto, error) {
+	client, err := o.authenticateClient(ctx, auth)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+
+	switch request.GrantType {
+	case "authorization_code":
+		return o.exchangeCode(ctx, client, request)
+	case "client_credentials":
+		return o.clientCredentials(ctx, client, request)
+	case "refresh_token":
+		return o.refresh(ctx, client, request)
+	case "":
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidRequest, "grant_type is required")
+	default:
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthUnsupportedGrantType, "grant_type must be authorization_code, client_credentials or refresh_token")
+	}
+}
+
+func (o *oauthUseCase) exchangeCode(ctx context.Context, client entity.OAuthClient, request dto.OAuthTokenRequestDto) (dto.OAuthTokenResponseDto, error) {
+	if request.Code == "" || len(request.CodeVerifier) < 43 || len(request.CodeVerifier) > 128 {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidRequest, "code and a code_verifier of 43 to 128 characters are required")
+	}
+
+	// The code is used up even when the exchange fails, a stolen code can not be retried
+	code, err := o.repo.UseCode(ctx, hashSecret(request.Code))
+	if errors.Is(err, repository.ErrOAuthCodeNotFound) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the code is invalid or was already used")
+	}
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	if code.ClientID != client.ID || time.Now().After(code.ExpiresAt) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the code is invalid or expired")
+	}
+	if request.RedirectURI != code.RedirectURI &&
+		!(request.RedirectURI == "" && len(dto.ConvertOAuthClientToResponse(client).RedirectURIs) == 1) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+
+	sum := sha256.Sum256([]byte(request.CodeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "code_verifier does not match the code_challenge")
+	}
+
+	user, err := o.activeUser(ctx, code.UserID)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	return o.issue(ctx, client, user, splitScopes(code.Scopes), true, "")
+}
+
+// clientCredentials issues a token acting as the owner of the client, within the scopes of the client
+func (o *oauthUseCase) clientCredentials(ctx context.Context, client entity.OAuthClient, request dto.OAuthTokenRequestDto) (dto.OAuthTokenResponseDto, error) {
+	if client.Type != entity.OAuthClientConfidential {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthUnauthorizedClient, "public clients can not use client_credentials")
+	}
+
+	scopes, ok := resolveScopes(request.Scope, splitScopes(client.Scopes))
+	if !ok {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidScope, "scope exceeds the scopes of the client")
+	}
+
+	owner, err := o.activeUser(ctx, client.UserID)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	return o.issue(ctx, client, owner, scopes, false, "")
+}
+
+// refresh exchanges a refresh token for new tokens, the old grant is revoked so each refresh token works once
+func (o *oauthUseCase) refresh(ctx context.Context, client entity.OAuthClient, request dto.OAuthTokenRequestDto) (dto.OAuthTokenResponseDto, error) {
+	if request.RefreshToken == "" {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	grant, err := o.repo.FindGrantByRefreshHash(ctx, hashSecret(request.RefreshToken))
+	if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the refresh token is invalid")
+	}
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	if grant.ClientID != client.ID || !refreshActive(grant) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the refresh token is invalid, expired or revoked")
+	}
+
+	scopes, ok := resolveScopes(request.Scope, splitScopes(grant.Scopes))
+	if !ok {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidScope, "scope exceeds the scopes of the refresh token")
+	}
+
+	user, err := o.activeUser(ctx, grant.UserID)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	if user.TokenVersion != grant.TokenVersion {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the refresh token was revoked")
+	}
+	return o.issue(ctx, client, user, scopes, true, grant.ID)
+}
+
+// issue creates a grant with its access token and, when refreshable, a refresh token. A previous grant is
+// replaced by the new one.
+func (o *oauthUseCase) issue(ctx context.Context, client entity.OAuthClient, user dto.UserWithProducts, scopes []string, refreshable bool, previous string) (dto.OAuthTokenResponseDto, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	scope := strings.Join(scopes, " ")
+	accessToken, err := o.jwtService.CreateOAuthToken(user, client.ID, scope, id, o.cfg.AccessTokenTTL)
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+
+	now := time.Now()
+	grant := entity.OAuthGrant{
+		ID:              id,
+		ClientID:        client.ID,
+		UserID:          user.ID,
+		Scopes:          strings.Join(scopes, ","),
+		TokenVersion:    user.TokenVersion,
+		AccessExpiresAt: now.Add(o.cfg.AccessTokenTTL),
+	}
+	response := dto.OAuthTokenResponseDto{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(o.cfg.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+	if refreshable {
+		if response.RefreshToken, err = randomHex(32); err != nil {
+			return dto.OAuthTokenResponseDto{}, err
+		}
+		refreshHash := hashSecret(response.RefreshToken)
+		refreshExpiresAt := now.Add(o.cfg.RefreshTokenTTL)
+		grant.RefreshHash = &refreshHash
+		grant.RefreshExpiresAt = &refreshExpiresAt
+	}
+
+	if previous == "" {
+		err = o.repo.CreateGrant(ctx, grant)
+	} else {
+		err = o.repo.RotateGrant(ctx, previous, grant)
+	}
+	if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+		return dto.OAuthTokenResponseDto{}, oauthError(OAuthInvalidGrant, "the refresh token was already used")
+	}
+	if err != nil {
+		return dto.OAuthTokenResponseDto{}, err
+	}
+	return response, nil
+}
+
+// Introspect implements OAuthUseCase. Only confidential clients may introspect, and only their own tokens are
+// reported active.
+func (o *oauthUseCase) Introspect(ctx context.Context, auth dto.OAuthClientAuthDto, token string) (dto.OAuthIntrospectionDto, error) {
+	client, err := o.authenticateClient(ctx, auth)
+	if err != nil {
+		return dto.OAuthIntrospectionDto{}, err
+	}
+	if client.Type != entity.OAuthClientConfidential {
+		return dto.OAuthIntrospectionDto{}, oauthError(OAuthUnauthorizedClient, "public clients can not introspect tokens")
+	}
+
+	grant, tokenType, err := o.findGrant(ctx, token)
+	if err != nil || grant.ClientID != client.ID {
+		return dto.OAuthIntrospectionDto{}, err
+	}
+
+	expiresAt := grant.AccessExpiresAt
+	if tokenType == "refresh_token" {
+		if !refreshActive(grant) {
+			return dto.OAuthIntrospectionDto{}, nil
+		}
+		expiresAt = *grant.RefreshExpiresAt
+	} else if grant.RevokedAt != nil || time.Now().After(expiresAt) {
+		return dto.OAuthIntrospectionDto{}, nil
+	}
+
+	user, err := o.userUc.FindUserByID(ctx, grant.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return dto.OAuthIntrospectionDto{}, nil
+	}
+	if err != nil {
+		return dto.OAuthIntrospectionDto{}, err
+	}
+	if user.DeactivatedAt != nil || user.TokenVersion != grant.TokenVersion {
+		return dto.OAuthIntrospectionDto{}, nil
+	}
+
+	return dto.OAuthIntrospectionDto{
+		Active:    true,
+		Scope:     strings.Join(splitScopes(grant.Scopes), " "),
+		ClientID:  grant.ClientID,
+		Username:  user.Email,
+		TokenType: tokenType,
+		Exp:       expiresAt.Unix(),
+		Iat:       grant.CreatedAt.Unix(),
+		Sub:       strconv.FormatUint(uint64(user.ID), 10),
+	}, nil
+}
+
+// Revoke implements OAuthUseCase.
+func (o *oauthUseCase) Revoke(ctx context.Context, auth dto.OAuthClientAuthDto, token string) error {
+	client, err := o.authenticateClient(ctx, auth)
+	if err != nil {
+		return err
+	}
+
+	grant, _, err := o.findGrant(ctx, token)
+	if err != nil || grant.ID == "" || grant.ClientID != client.ID {
+		return err
+	}
+	return o.repo.RevokeGrant(ctx, grant.ID)
+}
+
+// CheckAccessToken implements OAuthUseCase.
+func (o *oauthUseCase) CheckAccessToken(ctx context.Context, id string) error {
+	grant, err := o.repo.FindGrantByID(ctx, id)
+	if errors.Is(err, repository.ErrOAuthGrantNotFound) || (err == nil && grant.RevokedAt != nil) {
+		return ErrOAuthTokenRevoked
+	}
+	return err
+}
+
+// findGrant returns the grant of an access token or a refresh token and the type of token, an empty grant
+// when the token is neither
+func (o *oauthUseCase) findGrant(ctx context.Context, token string) (entity.OAuthGrant, string, error) {
+	id, tokenType := "", "Bearer"
+	if claims, err := o.jwtService.ParseToken(token); err == nil {
+		if _, ok := claims["client_id"].(string); ok {
+			id, _ = claims["jti"].(string)
+		}
+	}
+
+	var grant entity.OAuthGrant
+	var err error
+	if id != "" {
+		grant, err = o.repo.FindGrantByID(ctx, id)
+	} else {
+		tokenType = "refresh_token"
+		grant, err = o.repo.FindGrantByRefreshHash(ctx, hashSecret(token))
+	}
+	if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+		return entity.OAuthGrant{}, "", nil
+	}
+	return grant, tokenType, err
+}
+
+// authenticateClient checks the credentials of a client, public clients send their client_id alone
+func (o *oauthUseCase) authenticateClient(ctx context.Context, auth dto.OAuthClientAuthDto) (entity.OAuthClient, error) {
+	if auth.ClientID == "" {
+		return entity.OAuthClient{}, oauthError(OAuthInvalidClient, "client authentication is required")
+	}
+
+	client, err := o.repo.FindClientByID(ctx, auth.ClientID)
+	if errors.Is(err, repository.ErrOAuthClientNotFound) {
+		return entity.OAuthClient{}, oauthError(OAuthInvalidClient, "unknown client or wrong secret")
+	}
+	if err != nil {
+		return entity.OAuthClient{}, err
+	}
+
+	valid := client.RevokedAt == nil
+	if client.Type == entity.OAuthClientConfidential {
+		valid = valid && subtle.ConstantTimeCompare([]byte(hashSecret(auth.ClientSecret)), []byte(client.SecretHash)) == 1
+	} else {
+		valid = valid && auth.ClientSecret == ""
+	}
+	if !valid {
+		return entity.OAuthClient{}, oauthError(OAuthInvalidClient, "unknown client or wrong secret")
+	}
+	return client, nil
+}
+
+// resolveRequest finds the client, redirect URI and scopes of an authorization request. Without redirect_uri
+// the only registered one is used.
+func (o *oauthUseCase) resolveRequest(ctx context.Context, request dto.OAuthAuthorizeRequestDto) (entity.OAuthClient, string, []string, error) {
+	client, err := o.repo.FindClientByID(ctx, request.ClientID)
+	if errors.Is(err, repository.ErrOAuthClientNotFound) || (err == nil && client.RevokedAt != nil) {
+		return entity.OAuthClient{}, "", nil, ErrUnknownOAuthClient
+	}
+	if err != nil {
+		return entity.OAuthClient{}, "", nil, err
+	}
+
+	registered := dto.ConvertOAuthClientToResponse(client)
+	redirectURI := request.RedirectURI
+	if redirectURI == "" && len(registered.RedirectURIs) == 1 {
+		redirectURI = registered.RedirectURIs[0]
+	}
+	if !slices.Contains(registered.RedirectURIs, redirectURI) {
+		return entity.OAuthClient{}, "", nil, ErrInvalidRedirectURI
+	}
+
+	scopes, ok := resolveScopes(request.Scope, registered.Scopes)
+	if !ok {
+		return entity.OAuthClient{}, "", nil, apperror.Validation("scope exceeds the scopes of the client")
+	}
+	return client, redirectURI, scopes, nil
+}
+
+// activeUser returns the user a token is issued for, users that are gone or deactivated fail with invalid_grant
+func (o *oauthUseCase) activeUser(ctx context.Context, id uint) (dto.UserWithProducts, error) {
+	user, err := o.userUc.FindUserByID(ctx, id)
+	if errors.Is(err, repository.ErrUserNotFound) || (err == nil && user.DeactivatedAt != nil) {
+		return dto.UserWithProducts{}, oauthError(OAuthInvalidGrant, "the user is no longer active")
+	}
+	return user, err
+}
+
+func (o *oauthUseCase) record(ctx context.Context, actorID uint, client entity.OAuthClient, action, ip, details string) {
+	o.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+		IP:         ip,
+		Details:    details,
+	})
+}
+
+// resolveScopes returns the space separated requested scopes, or every allowed scope when none were requested.
+// It fails when a requested scope is not allowed.
+func resolveScopes(requested string, allowed []string) ([]string, bool) {
+	scopes := uniqueStrings(strings.Fields(requested))
+	if len(scopes) == 0 {
+		return allowed, len(allowed) > 0
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, false
+		}
+	}
+	return scopes, true
+}
+
+func splitScopes(value string) []string {
+	return uniqueStrings(strings.Split(value, ","))
+}
+
+func refreshActive(grant entity.OAuthGrant) bool {
+	return grant.RevokedAt == nil && grant.RefreshExpiresAt != nil && time.Now().Before(*grant.RefreshExpiresAt)
+}
+
+// withQuery adds params to the query of rawURL, which was checked when the client was registered
+func withQuery(rawURL string, params url.Values) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func NewOAuthUseCase(repo repository.OAuthRepository, userUc UserUseCase, auditUc AuditUseCase, jwtService service.JwtService, cfg config.OAuthConfig) OAuthUseCase {
+	return &oauthUseCase{repo: repo, userUc: userUc, auditUc: auditUc, jwtService: jwtService, cfg: cfg}
+}
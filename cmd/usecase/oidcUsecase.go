@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/oidc"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+var (
+	ErrUnknownOIDCProvider = apperror.NotFound("unknown identity provider")
+	ErrInvalidOIDCState    = apperror.Unauthenticated("invalid or expired login, please start again")
+	ErrOIDCLoginFailed     = apperror.Unauthenticated("the identity provider did not confirm the login")
+	ErrOIDCEmailUnverified = apperror.Forbidden("the identity provider did not confirm the email")
+	ErrOIDCSignupRefused   = apperror.Forbidden("no user can be registered for this identity")
+)
+
+// roleRank orders the roles a group mapping can give, the highest of several mapped groups wins
+var roleRank = map[string]int{"customer": 1, "reseller": 2, "admin": 3}
+
+type OIDCUseCase interface {
+	// Start returns the URL of the provider and the state token the callback must be given back
+	Start(ctx context.Context, provider string) (dto.OIDCStartDto, error)
+	// Callback completes the login, it links or provisions the user and returns a token, or a challenge token
+	// when the user has 2FA enabled and the provider did not ask for a second factor
+	Callback(ctx context.Context, provider string, payload dto.OIDCCallbackRequestDto, stateToken, ip, userAgent string) (dto.AuthResponseDto, error)
+}
+
+type oidcUseCase struct {
+	providers  map[string]*oidc.Provider
+	repo       repository.UserIdentityRepository
+	userUc     UserUseCase
+	auditUc    AuditUseCase
+	sessionUc  SessionUseCase
+	jwtService service.JwtService
+	cfg        config.OIDCConfig
+}
+
+// Start implements OIDCUseCase.
+func (o *oidcUseCase) Start(ctx context.Context, provider string) (dto.OIDCStartDto, error) {
+	p, ok := o.providers[provider]
+	if !ok {
+		return dto.OIDCStartDto{}, ErrUnknownOIDCProvider
+	}
+
+	state := model.OIDCState{Provider: provider}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		random, err := randomHex(32)
+		if err != nil {
+			return dto.OIDCStartDto{}, err
+		}
+		*value = random
+	}
+	sum := sha256.Sum256([]byte(state.Verifier))
+
+	authURL, err := p.AuthCodeURL(ctx, o.redirectURI(provider), state.State, state.Nonce, base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		slog.ErrorContext(ctx, "oidcUseCase.Start", "provider", provider, "err", err)
+		return dto.OIDCStartDto{}, apperror.Internal(err)
+	}
+	stateToken, err := o.jwtService.CreateOIDCStateToken(state, o.cfg.StateTTL)
+	if err != nil {
+		return dto.OIDCStartDto{}, err
+	}
+	return dto.OIDCStartDto{AuthURL: authURL, StateToken: stateToken, StateTTL: o.cfg.StateTTL}, nil
+}
+
+// Callback implements OIDCUseCase.
+func (o *oidcUseCase) Callback(ctx context.Context, provider string, payload dto.OIDCCallbackRequestDto, stateToken, ip, userAgent string) (dto.AuthResponseDto, error) {
+	p, ok := o.providers[provider]
+	if !ok {
+		return dto.AuthResponseDto{}, ErrUnknownOIDCProvider
+	}
+
+	// The state ties the callback to the browser that started the login, a forged callback has no cookie for it
+	state, err := o.jwtService.ParseOIDCStateToken(stateToken)
+	if err != nil || state.Provider != provider || state.State != payload.State {
+		return dto.AuthResponseDto{}, ErrInvalidOIDCState
+	}
+	if payload.Error != "" {
+		slog.InfoContext(ctx, "oidcUseCase.Callback: provider error", "provider", provider, "error", payload.Error, "description", payload.ErrorDescription)
+		return dto.AuthResponseDto{}, ErrOIDCLoginFailed
+	}
+	if payload.Code == "" {
+		return dto.AuthResponseDto{}, ErrOIDCLoginFailed
+	}
+
+	rawIDToken, err := p.Exchange(ctx, payload.Code, o.redirectURI(provider), state.Verifier)
+	if err != nil {
+		slog.WarnContext(ctx, "oidcUseCase.Callback: exchange", "provider", provider, "err", err)
+		return dto.AuthResponseDto{}, ErrOIDCLoginFailed
+	}
+	claims, err := p.Verify(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		slog.WarnContext(ctx, "oidcUseCase.Callback: verify", "provider", provider, "err", err)
+		return dto.AuthResponseDto{}, ErrOIDCLoginFailed
+	}
+
+	user, err := o.resolveUser(ctx, p.Config(), claims, ip)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	if user.DeactivatedAt != nil {
+		return dto.AuthResponseDto{}, ErrAccountDeactivated
+	}
+	if user, err = o.syncRole(ctx, p.Config(), user, claims.Groups, ip); err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	// A second factor checked by the provider counts as ours for this session, otherwise users with 2FA still
+	// need their code
+	if slices.Contains(claims.AMR, "mfa") {
+		return o.sessionUc.StartSession(ctx, user, true, ip, userAgent)
+	}
+	if user.TwoFactorEnabled {
+		return o.jwtService.CreateChallengeToken(user)
+	}
+	return o.sessionUc.StartSession(ctx, user, false, ip, userAgent)
+}
+
+// resolveUser finds the user linked to the identity. An identity seen for the first time is linked to the user
+// with its email, or a new user is provisioned; both need an email the provider verified.
+func (o *oidcUseCase) resolveUser(ctx context.Context, provider config.OIDCProviderConfig, claims oidc.Claims, ip string) (dto.UserWithProducts, error) {
+	identity, err := o.repo.FindBySubject(ctx, provider.Name, claims.Subject)
+	if err == nil {
+		if err := o.repo.Touch(ctx, identity.ID, claims.Email); err != nil {
+			slog.ErrorContext(ctx, "oidcUseCase.resolveUser: touch", "err", err)
+		}
+		return o.userUc.FindUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return dto.UserWithProducts{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return dto.UserWithProducts{}, ErrOIDCEmailUnverified
+	}
+
+	action := entity.AuditOIDCLink
+	user, err := o.userUc.FindUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if user.EmailVerifiedAt == nil {
+			if err := o.userUc.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+				return dto.UserWithProducts{}, err
+			}
+		}
+	case errors.Is(err, repository.ErrUserNotFound):
+		if user, err = o.provision(ctx, provider, claims); err != nil {
+			return dto.UserWithProducts{}, err
+		}
+		action = entity.AuditOIDCProvision
+	default:
+		return dto.UserWithProducts{}, err
+	}
+
+	now := time.Now()
+	_, err = o.repo.Create(ctx, entity.UserIdentity{
+		UserID:      user.ID,
+		Provider:    provider.Name,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	})
+	if err != nil {
+		return dto.UserWithProducts{}, err
+	}
+
+	o.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &user.ID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+		Details:    "provider: " + provider.Name,
+	})
+	return o.userUc.FindUserByID(ctx, user.ID)
+}
+
+// provision registers the user of a new identity. It has no password, so it can only log in through a provider
+// until it resets one.
+func (o *oidcUseCase) provision(ctx context.Context, provider config.OIDCProviderConfig, claims oidc.Claims) (dto.UserWithProducts, error) {
+	role := groupRole(provider, claims.Groups)
+	if role == "" {
+		role = provider.DefaultRole
+	}
+	if !provider.AllowSignup || role == "" {
+		return dto.UserWithProducts{}, ErrOIDCSignupRefused
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	now := time.Now()
+	return o.userUc.RegisterNewUser(ctx, entity.User{
+		FirstName:       firstName,
+		LastName:        lastName,
+		Email:           claims.Email,
+		Role:            role,
+		EmailVerifiedAt: &now,
+	})
+}
+
+// syncRole gives the user the role of their groups, users without a mapped group keep their role
+func (o *oidcUseCase) syncRole(ctx context.Context, provider config.OIDCProviderConfig, user dto.UserWithProducts, groups []string, ip string) (dto.UserWithProducts, error) {
+	role := groupRole(provider, groups)
+	if role == "" || role == user.Role {
+		return user, nil
+	}
+
+	if _, err := o.userUc.UpdateUser(ctx, user.ID, map[string]interface{}{"role": role}); err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	// Tokens carry the role, the old ones would keep the old permissions
+	if err := o.userUc.RevokeTokens(ctx, user.ID); err != nil {
+		return dto.UserWithProducts{}, err
+	}
+	o.auditUc.Record(ctx, entity.AuditLog{
+		Action:     entity.AuditUserUpdate,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+		Details:    "changed: role, provider: " + provider.Name,
+		Changes:    auditChanges(map[string]string{"role": user.Role}, map[string]string{"role": role}),
+	})
+	return o.userUc.FindUserByID(ctx, user.ID)
+}
+
+func (o *oidcUseCase) redirectURI(provider string) string {
+	return o.cfg.APIURL + config.ApiGroup + strings.Replace(config.GetOIDCCallback, ":provider", provider, 1)
+}
+
+// groupRole returns the highest role mapped from groups, or an empty string when none of them is mapped
+func groupRole(provider config.OIDCProviderConfig, groups []string) string {
+	var role string
+	for _, group := range groups {
+		if mapped := provider.GroupRoles[group]; roleRank[mapped] > roleRank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// NewOIDCUseCase builds the configured providers, client sends their discovery, key and token requests
+func NewOIDCUseCase(repo repository.UserIdentityRepository, userUc UserUseCase, auditUc AuditUseCase, sessionUc SessionUseCase, jwtService service.JwtService, client *http.Client, cfg config.OIDCConfig) OIDCUseCase {
+	providers := make(map[string]*oidc.Provider, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		providers[provider.Name] = oidc.NewProvider(provider, client)
+	}
+	return &oidcUseCase{providers: providers, repo: repo, userUc: userUc, auditUc: auditUc, sessionUc: sessionUc, jwtService: jwtService, cfg: cfg}
+}
@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	testClientID     = "app"
+	testClientSecret = "client-secret"
+)
+
+// testIdP is an identity provider serving discovery, keys and the token endpoint over TLS, so it is only reached
+// through the client of its server
+type testIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu sync.Mutex
+	// grants holds the ID token claims of each issued code
+	grants map[string]jwt.MapClaims
+	// sign turns the claims of the next ID token into the token, RS256 with the published key when nil
+	sign func(claims jwt.MapClaims) string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &testIdP{key: key, grants: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.srv.URL,
+			"authorization_endpoint": idp.srv.URL + "/authorize",
+			"token_endpoint":         idp.srv.URL + "/token",
+			"jwks_uri":               idp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != testClientID || secret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		idp.mu.Lock()
+		claims, ok := idp.grants[r.PostFormValue("code")]
+		delete(idp.grants, r.PostFormValue("code"))
+		sign := idp.sign
+		idp.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		if sign == nil {
+			sign = idp.signRS256
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": sign(claims), "token_type": "Bearer"})
+	})
+	idp.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (i *testIdP) signRS256(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	signed, _ := token.SignedString(i.key)
+	return signed
+}
+
+// login runs a login through the provider, the ID token carries claims over the valid defaults
+func (i *testIdP) login(t *testing.T, uc OIDCUseCase, claims jwt.MapClaims) (dto.AuthResponseDto, error) {
+	t.Helper()
+	start, err := uc.Start(context.Background(), "corp")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	authURL, err := url.Parse(start.AuthURL)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	query := authURL.Query()
+
+	grant := jwt.MapClaims{"iss": i.srv.URL, "aud": testClientID, "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(), "nonce": query.Get("nonce")}
+	for name, value := range claims {
+		grant[name] = value
+	}
+	code := "code-" + query.Get("state")
+	i.mu.Lock()
+	i.grants[code] = grant
+	i.mu.Unlock()
+
+	payload := dto.OIDCCallbackRequestDto{Code: code, State: query.Get("state")}
+	return uc.Callback(context.Background(), "corp", payload, start.StateToken, "127.0.0.1", "test")
+}
+
+func newTestOIDCUseCase(t *testing.T, idp *testIdP) (OIDCUseCase, *gorm.DB) {
+	db := testdb.New(t)
+	jwtService := newTestJwtService()
+	auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+	sessionUc := NewSessionUseCase(repository.NewSessionRepository(db), auditUc, jwtService, config.SessionConfig{TTL: time.Hour, TouchInterval: time.Minute})
+	cfg := config.OIDCConfig{
+		APIURL:   "http://api.test",
+		StateTTL: time.Minute,
+		Providers: []config.OIDCProviderConfig{{
+			Name:         "corp",
+			Issuer:       idp.srv.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+			Scopes:       []string{"openid", "email", "profile"},
+			GroupsClaim:  "groups",
+			GroupRoles:   map[string]string{"it-admins": "admin", "sales": "reseller"},
+			DefaultRole:  "customer",
+			AllowSignup:  true,
+		}},
+	}
+	uc := NewOIDCUseCase(repository.NewUserIdentityRepository(db), NewUserUseCase(repository.NewUserRepository(db)), auditUc, sessionUc, jwtService, idp.srv.Client(), cfg)
+	return uc, db
+}
+
+func TestOIDCCallbackVerifiesIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	uc, db := newTestOIDCUseCase(t, idp)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		sign   func(claims jwt.MapClaims) string
+		err    error
+	}{
+		{name: "valid token", claims: jwt.MapClaims{}},
+		{name: "wrong issuer", claims: jwt.MapClaims{"iss": "https://issuer.invalid"}, err: ErrOIDCLoginFailed},
+		{name: "wrong audience", claims: jwt.MapClaims{"aud": "other-app"}, err: ErrOIDCLoginFailed},
+		{name: "wrong nonce", claims: jwt.MapClaims{"nonce": "forged"}, err: ErrOIDCLoginFailed},
+		{name: "expired", claims: jwt.MapClaims{"iat": time.Now().Add(-3 * time.Hour).Unix(), "exp": time.Now().Add(-2 * time.Hour).Unix()}, err: ErrOIDCLoginFailed},
+		{
+			name:   "alg none",
+			claims: jwt.MapClaims{},
+			sign: func(claims jwt.MapClaims) string {
+				signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+			err: ErrOIDCLoginFailed,
+		},
+		{
+			name:   "HS256 with the client secret",
+			claims: jwt.MapClaims{},
+			sign: func(claims jwt.MapClaims) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+				token.Header["kid"] = "key-1"
+				signed, _ := token.SignedString([]byte(testClientSecret))
+				return signed
+			},
+			err: ErrOIDCLoginFailed,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := "subject-" + string(rune('a'+i))
+			claims := jwt.MapClaims{"sub": subject, "email": subject + "@corp.test", "email_verified": true}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			idp.mu.Lock()
+			idp.sign = tt.sign
+			idp.mu.Unlock()
+
+			res, err := idp.login(t, uc, claims)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			var identities int64
+			db.Model(&entity.UserIdentity{}).Where("subject = ?", subject).Count(&identities)
+			if tt.err != nil {
+				if identities != 0 {
+					t.Errorf("rejected token linked an identity")
+				}
+				return
+			}
+			if res.Token == "" || identities != 1 {
+				t.Errorf("token = %q, identities = %d, want a token and one identity", res.Token, identities)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackLinksByEmail(t *testing.T) {
+	idp := newTestIdP(t)
+	uc, db := newTestOIDCUseCase(t, idp)
+	local := createTestUser(t, db, entity.User{Email: "local@corp.test", Password: "hash"})
+
+	_, err := idp.login(t, uc, jwt.MapClaims{"sub": "local-1", "email": "local@corp.test", "email_verified": false})
+	if !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("unverified email: err = %v, want %v", err, ErrOIDCEmailUnverified)
+	}
+
+	if _, err := idp.login(t, uc, jwt.MapClaims{"sub": "local-1", "email": "local@corp.test", "email_verified": "true"}); err != nil {
+		t.Fatalf("verified email: %v", err)
+	}
+	var identity entity.UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", "corp", "local-1").First(&identity).Error; err != nil {
+		t.Fatalf("identity: %v", err)
+	}
+	if identity.UserID != local.ID {
+		t.Errorf("identity linked to user %d, want %d", identity.UserID, local.ID)
+	}
+	user := findTestUser(t, db, "local@corp.test")
+	if user.EmailVerifiedAt == nil || user.Password != "hash" {
+		t.Errorf("linked user: verified at %v, password %q, want verified and the password kept", user.EmailVerifiedAt, user.Password)
+	}
+
+	// The subject keeps finding the user once its email changes at the provider
+	if _, err := idp.login(t, uc, jwt.MapClaims{"sub": "local-1", "email": "renamed@corp.test", "email_verified": false}); err != nil {
+		t.Fatalf("login after email change: %v", err)
+	}
+	var users int64
+	db.Model(&entity.User{}).Count(&users)
+	if users != 1 {
+		t.Errorf("users = %d, want the linked user only", users)
+	}
+}
+
+func TestOIDCCallbackMapsGroupsToRoles(t *testing.T) {
+	idp := newTestIdP(t)
+	uc, db := newTestOIDCUseCase(t, idp)
+
+	tests := []struct {
+		name   string
+		groups interface{}
+		role   string
+		// revoked tells the role changed, so the tokens of the user must be revoked
+		revoked bool
+	}{
+		{name: "provisioned with the highest mapped group", groups: []string{"staff", "sales"}, role: "reseller"},
+		{name: "promoted by a single group", groups: "it-admins", role: "admin", revoked: true},
+		{name: "no mapped group keeps the role", groups: []string{"staff"}, role: "admin"},
+		{name: "no groups keeps the role", role: "admin"},
+	}
+	var tokenVersion uint
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"sub": "groups-1", "email": "groups@corp.test", "email_verified": true}
+			if tt.groups != nil {
+				claims["groups"] = tt.groups
+			}
+			if _, err := idp.login(t, uc, claims); err != nil {
+				t.Fatalf("login: %v", err)
+			}
+
+			user := findTestUser(t, db, "groups@corp.test")
+			if user.Role != tt.role {
+				t.Errorf("role = %q, want %q", user.Role, tt.role)
+			}
+			if revoked := user.TokenVersion > tokenVersion; revoked != tt.revoked {
+				t.Errorf("token version %d after %d, revoked = %v, want %v", user.TokenVersion, tokenVersion, revoked, tt.revoked)
+			}
+			tokenVersion = user.TokenVersion
+		})
+	}
+}
+
+func TestOIDCCallbackSecondFactor(t *testing.T) {
+	idp := newTestIdP(t)
+	uc, db := newTestOIDCUseCase(t, idp)
+	jwtService := newTestJwtService()
+	createTestUser(t, db, entity.User{Email: "plain@corp.test", Password: "hash"})
+	createTestUser(t, db, entity.User{Email: "totp@corp.test", Password: "hash", TwoFactorEnabled: true})
+
+	tests := []struct {
+		name      string
+		email     string
+		amr       []string
+		challenge bool
+		twoFactor bool
+	}{
+		{name: "no 2FA", email: "plain@corp.test"},
+		{name: "no 2FA, provider MFA", email: "plain@corp.test", amr: []string{"pwd", "mfa"}, twoFactor: true},
+		{name: "2FA", email: "totp@corp.test", amr: []string{"pwd"}, challenge: true},
+		{name: "2FA, provider MFA", email: "totp@corp.test", amr: []string{"pwd", "mfa"}, twoFactor: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"sub": tt.email, "email": tt.email, "email_verified": true}
+			if tt.amr != nil {
+				claims["amr"] = tt.amr
+			}
+			res, err := idp.login(t, uc, claims)
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if tt.challenge {
+				if res.ChallengeToken == "" || res.Token != "" {
+					t.Errorf("got %+v, want a challenge", res)
+				}
+				return
+			}
+			if got := tokenTwoFactor(t, jwtService, res.Token); got != tt.twoFactor {
+				t.Errorf("twoFactor = %v, want %v", got, tt.twoFactor)
+			}
+		})
+	}
+
+	// The provider MFA counts for the session only, it does not turn 2FA on
+	if user := findTestUser(t, db, "plain@corp.test"); user.TwoFactorEnabled {
+		t.Error("provider MFA enabled 2FA of the user")
+	}
+}
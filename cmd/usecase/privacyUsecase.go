@@ -0,0 +1,305 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+var (
+	// ErrInvalidDownloadLink is returned for download links that are forged, expired or point to a removed export
+	ErrInvalidDownloadLink = apperror.Forbidden("invalid or expired download link")
+	ErrDataExportNotReady  = apperror.Conflict("the data export is not ready")
+	ErrOwnErasure          = apperror.Forbidden("delete your own account with DELETE /profiles/me")
+)
+
+type PrivacyUseCase interface {
+	// RequestExport builds a ZIP archive of the personal data of the user in the background, an export still
+	// being built is returned instead of starting another one
+	RequestExport(ctx context.Context, userID uint, ip string) (dto.DataExportResponseDto, error)
+	// FindExport returns an export of the user, with a signed download URL once it is completed
+	FindExport(ctx context.Context, userID uint, id string) (dto.DataExportResponseDto, error)
+	// OpenExport checks a signed download token and returns the path of the archive
+	OpenExport(ctx context.Context, token string) (string, error)
+	// Erase anonymizes the user and stores an erasure record, actorID is the user or the admin handling the request
+	Erase(ctx context.Context, actorID, userID uint, channel, ip string) error
+}
+
+type privacyUseCase struct {
+	userUc     UserUseCase
+	auditUc    AuditUseCase
+	repo       repository.DataExportRepository
+	jwtService service.JwtService
+	cfg        config.PrivacyConfig
+}
+
+// RequestExport implements PrivacyUseCase.
+func (p *privacyUseCase) RequestExport(ctx context.Context, userID uint, ip string) (dto.DataExportResponseDto, error) {
+	p.removeExpired(ctx)
+
+	exports, err := p.repo.FindByUser(ctx, userID)
+	if err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	for _, export := range exports {
+		if export.Status == entity.DataExportStatusPending {
+			return dto.ConvertDataExportToResponse(export), nil
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	export, err := p.repo.Create(ctx, entity.DataExport{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Status:    entity.DataExportStatusPending,
+		ExpiresAt: time.Now().Add(p.cfg.ExportTTL),
+	})
+	if err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	p.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &userID,
+		Action:     entity.AuditDataExport,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		IP:         ip,
+	})
+
+	// The export outlives the request, so it keeps the request values but not its deadline
+	go p.build(context.WithoutCancel(ctx), export)
+
+	return dto.ConvertDataExportToResponse(export), nil
+}
+
+// FindExport implements PrivacyUseCase.
+func (p *privacyUseCase) FindExport(ctx context.Context, userID uint, id string) (dto.DataExportResponseDto, error) {
+	export, err := p.repo.FindByID(ctx, id)
+	if err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	// Exports of other users do not exist as far as the caller can tell
+	if export.UserID != userID || time.Now().After(export.ExpiresAt) {
+		return dto.DataExportResponseDto{}, repository.ErrDataExportNotFound
+	}
+
+	response := dto.ConvertDataExportToResponse(export)
+	if export.Status != entity.DataExportStatusCompleted {
+		return response, nil
+	}
+
+	user, err := p.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	token, err := p.jwtService.CreateActionToken(user, model.TokenPurposeDataExport, export.ID, time.Until(export.ExpiresAt))
+	if err != nil {
+		return dto.DataExportResponseDto{}, err
+	}
+	response.DownloadURL = config.ApiGroup + config.GetDataExportDownload + "?token=" + url.QueryEscape(token)
+	return response, nil
+}
+
+// OpenExport implements PrivacyUseCase.
+func (p *privacyUseCase) OpenExport(ctx context.Context, token string) (string, error) {
+	claims, err := p.jwtService.ParseActionToken(token, model.TokenPurposeDataExport)
+	if err != nil {
+		return "", ErrInvalidDownloadLink
+	}
+
+	export, err := p.repo.FindByID(ctx, claims.ID)
+	if errors.Is(err, repository.ErrDataExportNotFound) {
+		return "", ErrInvalidDownloadLink
+	}
+	if err != nil {
+		return "", err
+	}
+	if export.UserID != claims.UserId || time.Now().After(export.ExpiresAt) {
+		return "", ErrInvalidDownloadLink
+	}
+	if export.Status != entity.DataExportStatusCompleted {
+		return "", ErrDataExportNotReady
+	}
+	return p.path(export.ID), nil
+}
+
+// Erase implements PrivacyUseCase. The user row stays anonymized so orders and redemptions keep their
+// amounts for the books, the erasure record only keeps a keyed hash of the email.
+func (p *privacyUseCase) Erase(ctx context.Context, actorID, userID uint, channel, ip string) error {
+	if channel == entity.ErasureChannelAdmin && actorID == userID {
+		return ErrOwnErasure
+	}
+
+	user, err := p.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exports, err := p.repo.FindByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	record := entity.ErasureRecord{
+		Subject:     p.subject(user.Email),
+		RequestedBy: actorID,
+		Channel:     channel,
+		IP:          ip,
+	}
+	if err := p.userUc.AnonymizeUser(ctx, user.ID, record); err != nil {
+		return err
+	}
+	for _, export := range exports {
+		p.removeFile(export.ID)
+	}
+
+	action := entity.AuditAccountDelete
+	if channel == entity.ErasureChannelAdmin {
+		action = entity.AuditUserErase
+	}
+	p.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+	})
+	return nil
+}
+
+// build writes the archive of export and stores the outcome
+func (p *privacyUseCase) build(ctx context.Context, export entity.DataExport) {
+	size, err := p.writeArchive(ctx, export)
+
+	now := time.Now()
+	export.FinishedAt = &now
+	export.Status, export.Size = entity.DataExportStatusCompleted, size
+	if err != nil {
+		slog.ErrorContext(ctx, "privacyUseCase.build", "err", err)
+		export.Status, export.Size, export.Error = entity.DataExportStatusFailed, 0, apperror.Message(err)
+		p.removeFile(export.ID)
+	}
+
+	// The export is gone when the user was erased meanwhile, and so must be the archive
+	if err := p.repo.Finish(ctx, export); err != nil {
+		slog.ErrorContext(ctx, "privacyUseCase.build", "err", err)
+		p.removeFile(export.ID)
+	}
+}
+
+// writeArchive stores one JSON file per kind of record, the archive is written under a temporary name so a
+// download never sees half of it
+func (p *privacyUseCase) writeArchive(ctx context.Context, export entity.DataExport) (int64, error) {
+	user, err := p.userUc.FindUserByID(ctx, export.UserID)
+	if err != nil {
+		return 0, err
+	}
+	data, err := p.repo.FindPersonalData(ctx, user.ID, user.Email)
+	if err != nil {
+		return 0, err
+	}
+	data.Profile = dto.ConvertUserToProfile(user)
+
+	if err := os.MkdirAll(p.cfg.ExportDir, 0o700); err != nil {
+		return 0, err
+	}
+	file, err := os.CreateTemp(p.cfg.ExportDir, export.ID+"-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	files := []struct {
+		name    string
+		records interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"enrollments.json", data.Enrollments},
+		{"coupon_redemptions.json", data.CouponRedemptions},
+		{"reviews.json", data.Reviews},
+		{"wishlist.json", data.Wishlist},
+		{"audit_logs.json", data.AuditLogs},
+	}
+	for _, f := range files {
+		writer, err := archive.Create(f.name)
+		if err != nil {
+			return 0, err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.records); err != nil {
+			return 0, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(file.Name(), p.path(export.ID))
+}
+
+// removeExpired drops the exports past their expiry together with their archives
+func (p *privacyUseCase) removeExpired(ctx context.Context) {
+	exports, err := p.repo.FindExpired(ctx, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "privacyUseCase.removeExpired", "err", err)
+		return
+	}
+	for _, export := range exports {
+		p.removeFile(export.ID)
+		if err := p.repo.DeleteByID(ctx, export.ID); err != nil {
+			slog.ErrorContext(ctx, "privacyUseCase.removeExpired", "err", err)
+		}
+	}
+}
+
+func (p *privacyUseCase) removeFile(id string) {
+	if err := os.Remove(p.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("privacyUseCase.removeFile", "err", err)
+	}
+}
+
+func (p *privacyUseCase) path(id string) string {
+	return filepath.Join(p.cfg.ExportDir, id+".zip")
+}
+
+// subject is the keyed hash an erased email is known by
+func (p *privacyUseCase) subject(email string) string {
+	mac := hmac.New(sha256.New, p.cfg.PseudonymKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewPrivacyUseCase(userUc UserUseCase, auditUc AuditUseCase, repo repository.DataExportRepository, jwtService service.JwtService, cfg config.PrivacyConfig) PrivacyUseCase {
+	return &privacyUseCase{userUc: userUc, auditUc: auditUc, repo: repo, jwtService: jwtService, cfg: cfg}
+}
@@ -1,149 +1,148 @@
 package usecase
 
 import (
-	"fmt"
+	"context"
+	"math/big"
+	"strconv"
 
 	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
 	"github.com/altsaqif/go-rest/cmd/repository"
 	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/money"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
 )
 
 type ProductUseCase interface {
-	CreateProduct(payload entity.Product) (dto.ProductWithUsers, error)
-	FindProductByID(id uint) (dto.ProductWithUsers, error)
-	FindAllProducts(page, size int) ([]dto.ProductWithUsers, model.Paging, error)
-	FindProductsByStock(stock int) ([]dto.ProductWithUsers, error)
-	UpdateProduct(id uint, payload entity.Product) (dto.ProductWithUsers, error)
-	DeleteProduct(id uint) error
-	ProductExists(id uint) (bool, error)
+	CreateProduct(ctx context.Context, payload dto.ProductCreateRequestDto) (dto.ProductWithUsers, error)
+	FindProductByID(ctx context.Context, id uint) (dto.ProductWithUsers, error)
+	FindAllProducts(ctx context.Context, spec query.Spec, currency string) ([]dto.ProductWithUsers, model.Paging, error)
+	FindProductsByStock(ctx context.Context, stock int) ([]dto.ProductWithUsers, error)
+	UpdateProduct(ctx context.Context, id uint, payload dto.ProductUpdateRequestDto) (dto.ProductWithUsers, error)
+	DeleteProduct(ctx context.Context, id uint) error
+	ProductExists(ctx context.Context, id uint) (bool, error)
+	ExportProducts(ctx context.Context, spec query.Spec, currency string, fn func(product dto.ProductExportDto) error) error
 }
 
 type productUseCase struct {
-	repo repository.ProductRepository
+	repo            repository.ProductRepository
+	rateUc          ExchangeRateUseCase
+	auditUc         AuditUseCase
+	defaultCurrency string
 }
 
-func NewProductUseCase(repo repository.ProductRepository) ProductUseCase {
-	return &productUseCase{repo: repo}
+func NewProductUseCase(repo repository.ProductRepository, rateUc ExchangeRateUseCase, auditUc AuditUseCase, defaultCurrency string) ProductUseCase {
+	return &productUseCase{repo: repo, rateUc: rateUc, auditUc: auditUc, defaultCurrency: defaultCurrency}
 }
 
-func (p *productUseCase) CreateProduct(payload entity.Product) (dto.ProductWithUsers, error) {
-	type result struct {
-		product dto.ProductWithUsers
-		err     error
+func (p *productUseCase) CreateProduct(ctx context.Context, payload dto.ProductCreateRequestDto) (dto.ProductWithUsers, error) {
+	product := payload.ToEntity()
+	if product.Price.Currency == "" {
+		product.Price = money.New(product.Price.Amount, p.defaultCurrency)
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		product, err := p.repo.Create(payload)
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	return res.product, res.err
-}
-
-func (p *productUseCase) FindProductByID(id uint) (dto.ProductWithUsers, error) {
-	type result struct {
-		product dto.ProductWithUsers
-		err     error
+	created, err := p.repo.Create(ctx, product)
+	if err != nil {
+		return dto.ProductWithUsers{}, err
 	}
+	p.record(ctx, entity.AuditProductCreate, created.ID, nil, created)
+	return created, nil
+}
 
-	resultChan := make(chan result)
-	go func() {
-		product, err := p.repo.FindByID(id)
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	return res.product, res.err
+func (p *productUseCase) FindProductByID(ctx context.Context, id uint) (dto.ProductWithUsers, error) {
+	return p.repo.FindByID(ctx, id)
 }
 
-func (p *productUseCase) FindAllProducts(page, size int) ([]dto.ProductWithUsers, model.Paging, error) {
-	type result struct {
-		products []dto.ProductWithUsers
-		paging   model.Paging
-		err      error
+func (p *productUseCase) FindAllProducts(ctx context.Context, spec query.Spec, currency string) ([]dto.ProductWithUsers, model.Paging, error) {
+	products, paging, err := p.repo.FindAll(ctx, spec)
+	if err != nil || currency == "" {
+		return products, paging, err
 	}
 
-	resultChan := make(chan result)
-	go func() {
-		products, paging, err := p.repo.FindAll(page, size)
-		resultChan <- result{products, paging, err}
-	}()
-
-	res := <-resultChan
-	return res.products, res.paging, res.err
+	// Convert every price into the requested currency, keeping the original
+	for i := range products {
+		original := products[i].Price
+		converted, err := p.rateUc.Convert(ctx, original, currency)
+		if err != nil {
+			return nil, model.Paging{}, err
+		}
+		products[i].Price = converted
+		products[i].OriginalPrice = &original
+	}
+	return products, paging, nil
 }
 
 // FindProductsByStock implements ProductUseCase.
-func (p *productUseCase) FindProductsByStock(stock int) ([]dto.ProductWithUsers, error) {
-	type result struct {
-		products []dto.ProductWithUsers
-		err      error
-	}
-
-	resultChan := make(chan result)
-	go func() {
-		products, err := p.repo.FindByStock(stock)
-		resultChan <- result{products, err}
-	}()
-
-	res := <-resultChan
-	return res.products, res.err
+func (p *productUseCase) FindProductsByStock(ctx context.Context, stock int) ([]dto.ProductWithUsers, error) {
+	return p.repo.FindByStock(ctx, stock)
 }
 
-func (p *productUseCase) UpdateProduct(id uint, payload entity.Product) (dto.ProductWithUsers, error) {
-	type result struct {
-		product dto.ProductWithUsers
-		err     error
+func (p *productUseCase) UpdateProduct(ctx context.Context, id uint, payload dto.ProductUpdateRequestDto) (dto.ProductWithUsers, error) {
+	before, err := p.repo.FindByID(ctx, id)
+	if err != nil {
+		return dto.ProductWithUsers{}, err
 	}
-
-	resultChan := make(chan result)
-	go func() {
-		product, err := p.repo.UpdateByID(id, payload)
-		resultChan <- result{product, err}
-	}()
-
-	res := <-resultChan
-	return res.product, res.err
+	updated, err := p.repo.UpdateByID(ctx, id, payload.Changes())
+	if err != nil {
+		return dto.ProductWithUsers{}, err
+	}
+	p.record(ctx, entity.AuditProductUpdate, id, before, updated)
+	return updated, nil
 }
 
-func (p *productUseCase) DeleteProduct(id uint) error {
-	type result struct {
-		err error
+func (p *productUseCase) DeleteProduct(ctx context.Context, id uint) error {
+	before, err := p.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
 	}
+	if err := p.repo.DeleteByID(ctx, id); err != nil {
+		return err
+	}
+	p.record(ctx, entity.AuditProductDelete, id, before, nil)
+	return nil
+}
 
-	resultChan := make(chan result)
-	go func() {
-		err := p.repo.DeleteByID(id)
-		resultChan <- result{err}
-	}()
-
-	res := <-resultChan
-	return res.err
+// record audits a write of the product, the actor comes from the request info of ctx
+func (p *productUseCase) record(ctx context.Context, action string, id uint, before, after interface{}) {
+	p.auditUc.Record(ctx, entity.AuditLog{
+		Action:     action,
+		TargetType: "product",
+		TargetID:   strconv.FormatUint(uint64(id), 10),
+		Changes:    auditChanges(before, after),
+	})
 }
 
-func (p *productUseCase) ProductExists(id uint) (bool, error) {
-	// Channels for signaling completion and errors
-	existsCh := make(chan bool)
-	errCh := make(chan error, 1)
+func (p *productUseCase) ProductExists(ctx context.Context, id uint) (bool, error) {
+	return p.repo.ProductExists(ctx, id)
+}
 
-	// Goroutine to check if the product exists
-	go func() {
-		exists, err := p.repo.ProductExists(id)
-		if err != nil {
-			errCh <- fmt.Errorf("failed to check if product exists: %v", err)
-			return
+// ExportProducts implements ProductUseCase, prices are converted like FindAllProducts with each rate looked up once.
+func (p *productUseCase) ExportProducts(ctx context.Context, spec query.Spec, currency string, fn func(product dto.ProductExportDto) error) error {
+	rates := make(map[string]*big.Rat)
+
+	return p.repo.Each(ctx, spec, func(product entity.Product) error {
+		response := dto.ConvertProductToResponse(product)
+		if currency != "" {
+			original := response.Price
+			converted := original
+			if original.Currency != currency {
+				rate, ok := rates[original.Currency]
+				if !ok {
+					var err error
+					if rate, err = p.rateUc.FindRate(ctx, original.Currency, currency); err != nil {
+						return err
+					}
+					rates[original.Currency] = rate
+				}
+
+				var err error
+				if converted, err = original.Convert(rate, currency); err != nil {
+					return err
+				}
+			}
+			response.Price = converted
+			response.OriginalPrice = &original
 		}
-		existsCh <- exists
-	}()
-
-	// Wait for results or errors
-	select {
-	case exists := <-existsCh:
-		return exists, nil
-	case err := <-errCh:
-		return false, err
-	}
+		return fn(dto.ProductExportDto{ProductWithUsers: response})
+	})
 }
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/model"
+)
+
+var (
+	// ErrReviewInvalid is returned when a review payload breaks a validation rule
+	ErrReviewInvalid = apperror.Validation("invalid review")
+	// ErrReviewForbidden is returned when a user changes a review written by someone else
+	ErrReviewForbidden = apperror.Forbidden("only the author can change this review")
+)
+
+type ReviewUseCase interface {
+	CreateReview(ctx context.Context, userID, productID uint, payload dto.ReviewRequestDto) (dto.ReviewResponseDto, error)
+	FindProductReviews(ctx context.Context, productID uint, includeHidden bool, page, size int) ([]dto.ReviewResponseDto, model.Paging, error)
+	UpdateReview(ctx context.Context, userID, reviewID uint, payload dto.ReviewRequestDto) (dto.ReviewResponseDto, error)
+	DeleteReview(ctx context.Context, userID, reviewID uint) error
+	SetReviewHidden(ctx context.Context, reviewID uint, hidden bool) (dto.ReviewResponseDto, error)
+}
+
+type reviewUseCase struct {
+	repo repository.ReviewRepository
+}
+
+// CreateReview implements ReviewUseCase.
+func (r *reviewUseCase) CreateReview(ctx context.Context, userID, productID uint, payload dto.ReviewRequestDto) (dto.ReviewResponseDto, error) {
+	review, err := buildReview(payload)
+	if err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+	review.UserID = userID
+	review.ProductID = productID
+
+	created, err := r.repo.Create(ctx, review)
+	if err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+	return dto.ConvertReviewToResponse(created), nil
+}
+
+// FindProductReviews implements ReviewUseCase.
+func (r *reviewUseCase) FindProductReviews(ctx context.Context, productID uint, includeHidden bool, page, size int) ([]dto.ReviewResponseDto, model.Paging, error) {
+	reviews, paging, err := r.repo.FindByProduct(ctx, productID, includeHidden, page, size)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	responseReviews := make([]dto.ReviewResponseDto, len(reviews))
+	for i, review := range reviews {
+		responseReviews[i] = dto.ConvertReviewToResponse(review)
+	}
+	return responseReviews, paging, nil
+}
+
+// UpdateReview implements ReviewUseCase.
+func (r *reviewUseCase) UpdateReview(ctx context.Context, userID, reviewID uint, payload dto.ReviewRequestDto) (dto.ReviewResponseDto, error) {
+	review, err := buildReview(payload)
+	if err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+
+	if err := r.checkAuthor(ctx, userID, reviewID); err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+	updated, err := r.repo.UpdateByID(ctx, reviewID, review)
+	if err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+	return dto.ConvertReviewToResponse(updated), nil
+}
+
+// DeleteReview implements ReviewUseCase.
+func (r *reviewUseCase) DeleteReview(ctx context.Context, userID, reviewID uint) error {
+	if err := r.checkAuthor(ctx, userID, reviewID); err != nil {
+		return err
+	}
+	return r.repo.DeleteByID(ctx, reviewID)
+}
+
+// SetReviewHidden implements ReviewUseCase.
+func (r *reviewUseCase) SetReviewHidden(ctx context.Context, reviewID uint, hidden bool) (dto.ReviewResponseDto, error) {
+	review, err := r.repo.SetHidden(ctx, reviewID, hidden)
+	if err != nil {
+		return dto.ReviewResponseDto{}, err
+	}
+	return dto.ConvertReviewToResponse(review), nil
+}
+
+func (r *reviewUseCase) checkAuthor(ctx context.Context, userID, reviewID uint) error {
+	review, err := r.repo.FindByID(ctx, reviewID)
+	if err != nil {
+		return err
+	}
+	if review.UserID != userID {
+		return ErrReviewForbidden
+	}
+	return nil
+}
+
+// buildReview validates the request payload and maps it to a Review entity
+func buildReview(payload dto.ReviewRequestDto) (entity.Review, error) {
+	review := entity.Review{
+		Rating: payload.Rating,
+		Title:  strings.TrimSpace(payload.Title),
+		Body:   strings.TrimSpace(payload.Body),
+	}
+
+	if review.Rating < 1 || review.Rating > 5 {
+		return entity.Review{}, fmt.Errorf("%w: rating must be between 1 and 5", ErrReviewInvalid)
+	}
+	if review.Title == "" {
+		return entity.Review{}, fmt.Errorf("%w: title is required", ErrReviewInvalid)
+	}
+	if len(review.Title) > 200 {
+		return entity.Review{}, fmt.Errorf("%w: title must be at most 200 characters", ErrReviewInvalid)
+	}
+
+	return review, nil
+}
+
+func NewReviewUseCase(repo repository.ReviewRepository) ReviewUseCase {
+	return &reviewUseCase{repo: repo}
+}
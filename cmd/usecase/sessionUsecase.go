@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+)
+
+// ErrSessionRevoked is returned by CheckSession for tokens of revoked or unknown sessions
+var ErrSessionRevoked = apperror.Unauthenticated("the session was revoked, please login again")
+
+// maxUserAgentLength fits the user agent column in characters, longer ones are cut
+const maxUserAgentLength = 512
+
+type SessionUseCase interface {
+	// StartSession records a login of the user and returns its token, twoFactor tells the login passed a second
+	// factor
+	StartSession(ctx context.Context, user dto.UserWithProducts, twoFactor bool, ip, userAgent string) (dto.AuthResponseDto, error)
+	// RenewSession returns a new token for the session with the current state of the user, such as a raised
+	// token version. twoFactor tells a second factor was just passed, the session keeps one passed before.
+	// Tokens of no session, issued before sessions were recorded, get a token of no session too.
+	RenewSession(ctx context.Context, user dto.UserWithProducts, id string, twoFactor bool) (dto.AuthResponseDto, error)
+	// CheckSession fails when the session of a token of the user was revoked, it records the request as the
+	// last activity of the session at most once per touch interval
+	CheckSession(ctx context.Context, id string, userID uint, ip string) error
+	// FindSessions returns the active sessions of the user, currentID marks the one of the request
+	FindSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionResponseDto, error)
+	// RevokeSession ends a session of the user, sessions of other users are not found
+	RevokeSession(ctx context.Context, actorID, userID uint, id, ip string) error
+}
+
+type sessionUseCase struct {
+	repo       repository.SessionRepository
+	auditUc    AuditUseCase
+	jwtService service.JwtService
+	cfg        config.SessionConfig
+}
+
+// StartSession implements SessionUseCase.
+func (s *sessionUseCase) StartSession(ctx context.Context, user dto.UserWithProducts, twoFactor bool, ip, userAgent string) (dto.AuthResponseDto, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	// The column counts characters, cutting bytes could split one
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+
+	now := time.Now()
+	_, err = s.repo.Create(ctx, entity.Session{
+		ID:           id,
+		UserID:       user.ID,
+		UserAgent:    userAgent,
+		IP:           ip,
+		LastSeenAt:   now,
+		LastSeenIP:   ip,
+		TokenVersion: user.TokenVersion,
+		TwoFactor:    twoFactor,
+		ExpiresAt:    now.Add(s.cfg.TTL),
+	})
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+	s.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &user.ID,
+		Action:     entity.AuditLogin,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		IP:         ip,
+		Details:    "session: " + id,
+	})
+	return s.jwtService.CreateToken(user, id, twoFactor)
+}
+
+// RenewSession implements SessionUseCase.
+func (s *sessionUseCase) RenewSession(ctx context.Context, user dto.UserWithProducts, id string, twoFactor bool) (dto.AuthResponseDto, error) {
+	if id != "" {
+		renewed, err := s.repo.Renew(ctx, id, user.TokenVersion, time.Now().Add(s.cfg.TTL), twoFactor)
+		if err != nil {
+			return dto.AuthResponseDto{}, err
+		}
+		if !renewed {
+			return dto.AuthResponseDto{}, ErrSessionRevoked
+		}
+		if !twoFactor {
+			session, err := s.repo.FindByID(ctx, id)
+			if err != nil {
+				return dto.AuthResponseDto{}, err
+			}
+			twoFactor = session.TwoFactor
+		}
+	}
+	return s.jwtService.CreateToken(user, id, twoFactor)
+}
+
+// CheckSession implements SessionUseCase.
+func (s *sessionUseCase) CheckSession(ctx context.Context, id string, userID uint, ip string) error {
+	session, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+
+	// The last activity is informative, failing to record it does not fail the request
+	if time.Since(session.LastSeenAt) >= s.cfg.TouchInterval {
+		if err := s.repo.Touch(ctx, id, ip, time.Now().Add(-s.cfg.TouchInterval)); err != nil {
+			slog.ErrorContext(ctx, "sessionUseCase.CheckSession: touch", "err", err)
+		}
+	}
+	return nil
+}
+
+// FindSessions implements SessionUseCase.
+func (s *sessionUseCase) FindSessions(ctx context.Context, userID uint, currentID string) ([]dto.SessionResponseDto, error) {
+	sessions, err := s.repo.FindActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responseSessions := make([]dto.SessionResponseDto, len(sessions))
+	for i, session := range sessions {
+		responseSessions[i] = dto.ConvertSessionToResponse(session, currentID)
+	}
+	return responseSessions, nil
+}
+
+// RevokeSession implements SessionUseCase.
+func (s *sessionUseCase) RevokeSession(ctx context.Context, actorID, userID uint, id, ip string) error {
+	session, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return repository.ErrSessionNotFound
+	}
+
+	revoked, err := s.repo.Revoke(ctx, session.ID)
+	if err != nil || !revoked {
+		return err
+	}
+	s.auditUc.Record(ctx, entity.AuditLog{
+		ActorID:    &actorID,
+		Action:     entity.AuditSessionRevoke,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(session.UserID), 10),
+		IP:         ip,
+		Details:    "session: " + session.ID,
+	})
+	return nil
+}
+
+func NewSessionUseCase(repo repository.SessionRepository, auditUc AuditUseCase, jwtService service.JwtService, cfg config.SessionConfig) SessionUseCase {
+	return &sessionUseCase{repo: repo, auditUc: auditUc, jwtService: jwtService, cfg: cfg}
+}
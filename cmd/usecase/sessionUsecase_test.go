@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/service"
+	"github.com/altsaqif/go-rest/cmd/shared/testdb"
+	"gorm.io/gorm"
+)
+
+func newTestSessionUseCase(t *testing.T) (SessionUseCase, service.JwtService, *gorm.DB) {
+	db := testdb.New(t)
+	jwtService := newTestJwtService()
+	auditUc := NewAuditUseCase(repository.NewAuditLogRepository(db))
+	uc := NewSessionUseCase(repository.NewSessionRepository(db), auditUc, jwtService, config.SessionConfig{TTL: time.Hour, TouchInterval: time.Minute})
+	return uc, jwtService, db
+}
+
+// tokenTwoFactor returns the twoFactor claim of token
+func tokenTwoFactor(t *testing.T, jwtService service.JwtService, token string) bool {
+	t.Helper()
+	claims, err := jwtService.ParseToken(token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	twoFactor, _ := claims["twoFactor"].(bool)
+	return twoFactor
+}
+
+func TestSessionTwoFactor(t *testing.T) {
+	uc, jwtService, db := newTestSessionUseCase(t)
+	ctx := context.Background()
+	// The claim tells what the session passed, not whether the user has 2FA enabled
+	user := createTestUser(t, db, entity.User{Email: "user@example.com", Password: "hash", TwoFactorEnabled: true})
+
+	token, err := uc.StartSession(ctx, user, false, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if tokenTwoFactor(t, jwtService, token.Token) {
+		t.Error("login without a second factor carries the twoFactor claim")
+	}
+	sessionID := tokenSessionID(t, jwtService, token.Token)
+
+	steps := []struct {
+		name      string
+		twoFactor bool
+		want      bool
+	}{
+		{name: "renewed without a second factor", want: false},
+		{name: "renewed after a second factor", twoFactor: true, want: true},
+		{name: "renewed later keeps the second factor", want: true},
+	}
+	for _, step := range steps {
+		token, err := uc.RenewSession(ctx, user, sessionID, step.twoFactor)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := tokenTwoFactor(t, jwtService, token.Token); got != step.want {
+			t.Errorf("%s: twoFactor = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	token, err = uc.StartSession(ctx, user, true, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("start with a second factor: %v", err)
+	}
+	if !tokenTwoFactor(t, jwtService, token.Token) {
+		t.Error("login with a second factor lacks the twoFactor claim")
+	}
+}
+
+func TestStartSessionUserAgent(t *testing.T) {
+	uc, jwtService, db := newTestSessionUseCase(t)
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "short", userAgent: "Mozilla/5.0", want: "Mozilla/5.0"},
+		{name: "at the limit", userAgent: strings.Repeat("é", maxUserAgentLength), want: strings.Repeat("é", maxUserAgentLength)},
+		{name: "cut inside a character", userAgent: "a" + strings.Repeat("é", maxUserAgentLength), want: "a" + strings.Repeat("é", maxUserAgentLength-1)},
+		{name: "cut after ascii", userAgent: strings.Repeat("a", maxUserAgentLength+10), want: strings.Repeat("a", maxUserAgentLength)},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, db, entity.User{Email: string(rune('a'+i)) + "@example.com", Password: "hash"})
+			token, err := uc.StartSession(context.Background(), user, false, "192.0.2.1", tt.userAgent)
+			if err != nil {
+				t.Fatalf("start: %v", err)
+			}
+
+			got := findTestSession(t, db, tokenSessionID(t, jwtService, token.Token)).UserAgent
+			if !utf8.ValidString(got) || got != tt.want {
+				t.Errorf("user agent of %d characters, valid %v, want %d characters", utf8.RuneCountInString(got), utf8.ValidString(got), utf8.RuneCountInString(tt.want))
+			}
+		})
+	}
+}
+
+func TestFindSessions(t *testing.T) {
+	uc, jwtService, db := newTestSessionUseCase(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, entity.User{Email: "user@example.com", Password: "hash"})
+	other := createTestUser(t, db, entity.User{Email: "other@example.com", Password: "hash"})
+
+	if _, err := uc.StartSession(ctx, user, false, "192.0.2.1", "old"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := uc.StartSession(ctx, other, false, "192.0.2.1", "other"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	// Raising the token version ends the sessions started before
+	if err := NewUserUseCase(repository.NewUserRepository(db)).RevokeTokens(ctx, user.ID); err != nil {
+		t.Fatalf("revoke tokens: %v", err)
+	}
+	user.TokenVersion++
+	token, err := uc.StartSession(ctx, user, false, "192.0.2.1", "current")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	currentID := tokenSessionID(t, jwtService, token.Token)
+
+	sessions, err := uc.FindSessions(ctx, user.ID, currentID)
+	if err != nil {
+		t.Fatalf("find sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != currentID || !sessions[0].Current {
+		t.Errorf("sessions = %+v, want the current session only", sessions)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := uc.FindSessions(cancelled, user.ID, currentID); err == nil {
+		t.Error("find sessions ignored a cancelled context")
+	}
+}
+
+// tokenSessionID returns the sid claim of token
+func tokenSessionID(t *testing.T, jwtService service.JwtService, token string) string {
+	t.Helper()
+	claims, err := jwtService.ParseToken(token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	sessionID, _ := claims["sid"].(string)
+	return sessionID
+}
+
+func findTestSession(t *testing.T, db *gorm.DB, id string) entity.Session {
+	t.Helper()
+	var session entity.Session
+	if err := db.Where("id = ?", id).First(&session).Error; err != nil {
+		t.Fatalf("find session %s: %v", id, err)
+	}
+	return session
+}
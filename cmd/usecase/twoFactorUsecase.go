@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/altsaqif/go-rest/cmd/config"
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
+	"github.com/altsaqif/go-rest/cmd/shared/totp"
+	"github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrTwoFactorEnabled     = apperror.Conflict("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetUp    = apperror.Conflict("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode = apperror.Unauthenticated("invalid two-factor code")
+)
+
+type TwoFactorUseCase interface {
+	// Setup stores a new secret for the user, 2FA stays off until Enable confirms a code of it
+	Setup(ctx context.Context, userID uint) (dto.TwoFactorSetupResponseDto, error)
+	// Enable turns 2FA on with a code of the secret from Setup and returns fresh recovery codes
+	Enable(ctx context.Context, userID uint, code string) ([]string, error)
+	// Check accepts a TOTP code or an unused recovery code, each of them only once
+	Check(ctx context.Context, user dto.UserWithProducts, code string) error
+}
+
+type twoFactorUseCase struct {
+	userUc UserUseCase
+	repo   repository.RecoveryCodeRepository
+	cfg    config.TwoFactorConfig
+}
+
+// Setup implements TwoFactorUseCase.
+func (t *twoFactorUseCase) Setup(ctx context.Context, userID uint) (dto.TwoFactorSetupResponseDto, error) {
+	user, err := t.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return dto.TwoFactorSetupResponseDto{}, err
+	}
+	if user.TwoFactorEnabled {
+		return dto.TwoFactorSetupResponseDto{}, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return dto.TwoFactorSetupResponseDto{}, err
+	}
+	if err := t.userUc.UpdateTOTPSecret(ctx, userID, secret); err != nil {
+		return dto.TwoFactorSetupResponseDto{}, err
+	}
+
+	uri := totp.ProvisioningURI(t.cfg.Issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return dto.TwoFactorSetupResponseDto{}, err
+	}
+	return dto.TwoFactorSetupResponseDto{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Enable implements TwoFactorUseCase.
+func (t *twoFactorUseCase) Enable(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := t.userUc.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := t.recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.repo.Replace(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	if err := t.userUc.EnableTwoFactor(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Check implements TwoFactorUseCase.
+func (t *twoFactorUseCase) Check(ctx context.Context, user dto.UserWithProducts, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotSetUp
+	}
+
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		used, err := t.userUc.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := t.repo.Use(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// recoveryCodes returns codes like "ABCDE-FGHJK" together with the hashes to store
+func (t *twoFactorUseCase) recoveryCodes() ([]string, []string, error) {
+	codes := make([]string, t.cfg.RecoveryCodes)
+	hashes := make([]string, t.cfg.RecoveryCodes)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(raw)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed back loosely
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewTwoFactorUseCase(userUc UserUseCase, repo repository.RecoveryCodeRepository, cfg config.TwoFactorConfig) TwoFactorUseCase {
+	return &twoFactorUseCase{userUc: userUc, repo: repo, cfg: cfg}
+}
@@ -1,20 +1,40 @@
 package usecase
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"time"
 
 	"github.com/altsaqif/go-rest/cmd/entity"
 	"github.com/altsaqif/go-rest/cmd/entity/dto"
 	"github.com/altsaqif/go-rest/cmd/repository"
+	"github.com/altsaqif/go-rest/cmd/shared/apperror"
 	"github.com/altsaqif/go-rest/cmd/shared/model"
+	"github.com/altsaqif/go-rest/cmd/shared/query"
 )
 
+// ErrTokenRevoked is returned by CheckToken for tokens of deleted or deactivated users and revoked tokens
+var ErrTokenRevoked = apperror.Unauthenticated("token has been revoked, log in again")
+
 type UserUseCase interface {
-	RegisterNewUser(payload entity.User) (dto.UserWithProducts, error)
-	FindUserByID(id uint) (dto.UserWithProducts, error)
-	FindUserByEmail(email string) (dto.UserWithProducts, error)
-	FindAllUsers(page, size int) ([]dto.UserWithProducts, model.Paging, error)
+	RegisterNewUser(ctx context.Context, payload entity.User) (dto.UserWithProducts, error)
+	FindUserByID(ctx context.Context, id uint) (dto.UserWithProducts, error)
+	FindUserByEmail(ctx context.Context, email string) (dto.UserWithProducts, error)
+	FindAllUsers(ctx context.Context, spec query.Spec) ([]dto.UserWithProducts, model.Paging, error)
+	// CountMatchingUsers and UpdateMatchingUsers leave out the user exceptID, usually the acting admin
+	CountMatchingUsers(ctx context.Context, spec query.Spec, exceptID uint) (int64, error)
+	UpdateMatchingUsers(ctx context.Context, spec query.Spec, exceptID uint, changes map[string]interface{}) (int64, error)
+	ExportUsers(ctx context.Context, spec query.Spec, fn func(user dto.UserExportDto) error) error
+	UpdatePassword(ctx context.Context, id uint, hash string) error
+	UpdateTOTPSecret(ctx context.Context, id uint, secret string) error
+	EnableTwoFactor(ctx context.Context, id uint, step int64) error
+	UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	MarkEmailVerified(ctx context.Context, id uint, email string) error
+	UpdateUser(ctx context.Context, id uint, changes map[string]interface{}) (dto.UserWithProducts, error)
+	RevokeTokens(ctx context.Context, id uint) error
+	// CheckToken fails when a token of the given version no longer belongs to an active user
+	CheckToken(ctx context.Context, id, version uint) error
+	AnonymizeUser(ctx context.Context, id uint, record entity.ErasureRecord) error
 }
 
 type userUseCase struct {
@@ -22,80 +42,101 @@ type userUseCase struct {
 }
 
 // FindAllUsers implements UserUseCase.
-func (u *userUseCase) FindAllUsers(page, size int) ([]dto.UserWithProducts, model.Paging, error) {
-	type result struct {
-		users  []dto.UserWithProducts
-		paging model.Paging
-		err    error
-	}
+func (u *userUseCase) FindAllUsers(ctx context.Context, spec query.Spec) ([]dto.UserWithProducts, model.Paging, error) {
+	return u.repo.FindAll(ctx, spec)
+}
 
-	resultChan := make(chan result)
-	go func() {
-		users, paging, err := u.repo.FindAll(page, size)
-		resultChan <- result{users, paging, err}
-	}()
+// CountMatchingUsers implements UserUseCase.
+func (u *userUseCase) CountMatchingUsers(ctx context.Context, spec query.Spec, exceptID uint) (int64, error) {
+	return u.repo.CountMatching(ctx, spec, exceptID)
+}
 
-	res := <-resultChan
-	return res.users, res.paging, res.err
+// UpdateMatchingUsers implements UserUseCase.
+func (u *userUseCase) UpdateMatchingUsers(ctx context.Context, spec query.Spec, exceptID uint, changes map[string]interface{}) (int64, error) {
+	return u.repo.UpdateMatching(ctx, spec, exceptID, changes)
 }
 
 // GetUserByEmail implements UserUseCase.
-func (u *userUseCase) FindUserByEmail(email string) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
+func (u *userUseCase) FindUserByEmail(ctx context.Context, email string) (dto.UserWithProducts, error) {
+	return u.repo.FindByEmail(ctx, email)
+}
+
+func (u *userUseCase) RegisterNewUser(ctx context.Context, payload entity.User) (dto.UserWithProducts, error) {
+	_, err := u.repo.FindByEmail(ctx, payload.Email)
+	if err == nil {
+		return dto.UserWithProducts{}, repository.ErrEmailTaken
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return dto.UserWithProducts{}, err
 	}
+	payload.UpdatedAt = time.Now()
+	return u.repo.Create(ctx, payload)
+}
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := u.repo.FindByEmail(email)
-		resultChan <- result{user, err}
-	}()
+func (u *userUseCase) FindUserByID(ctx context.Context, id uint) (dto.UserWithProducts, error) {
+	return u.repo.FindByID(ctx, id)
+}
 
-	res := <-resultChan
-	return res.user, res.err
+// ExportUsers implements UserUseCase.
+func (u *userUseCase) ExportUsers(ctx context.Context, spec query.Spec, fn func(user dto.UserExportDto) error) error {
+	return u.repo.Each(ctx, spec, func(user entity.User) error {
+		return fn(dto.ConvertUserToExport(user))
+	})
 }
 
-func (u *userUseCase) RegisterNewUser(payload entity.User) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
-	}
+// UpdatePassword implements UserUseCase, hash must already be hashed.
+func (u *userUseCase) UpdatePassword(ctx context.Context, id uint, hash string) error {
+	return u.repo.UpdatePassword(ctx, id, hash)
+}
 
-	resultChan := make(chan result)
-	go func() {
-		userExist, _ := u.repo.FindByEmail(payload.Email)
-		if userExist.Email == payload.Email {
-			resultChan <- result{dto.UserWithProducts{}, fmt.Errorf("user with email: %s already exists", payload.Email)}
-			return
-		}
-		payload.UpdatedAt = time.Now()
-		createdUser, err := u.repo.Create(payload)
-		if err != nil {
-			resultChan <- result{dto.UserWithProducts{}, err}
-			return
-		}
-		resultChan <- result{createdUser, nil}
-	}()
-
-	res := <-resultChan
-	return res.user, res.err
-}
-
-func (u *userUseCase) FindUserByID(id uint) (dto.UserWithProducts, error) {
-	type result struct {
-		user dto.UserWithProducts
-		err  error
-	}
+// UpdateTOTPSecret implements UserUseCase.
+func (u *userUseCase) UpdateTOTPSecret(ctx context.Context, id uint, secret string) error {
+	return u.repo.UpdateTOTPSecret(ctx, id, secret)
+}
 
-	resultChan := make(chan result)
-	go func() {
-		user, err := u.repo.FindByID(id)
-		resultChan <- result{user, err}
-	}()
+// EnableTwoFactor implements UserUseCase.
+func (u *userUseCase) EnableTwoFactor(ctx context.Context, id uint, step int64) error {
+	return u.repo.EnableTwoFactor(ctx, id, step)
+}
+
+// UseTOTPStep implements UserUseCase.
+func (u *userUseCase) UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	return u.repo.UseTOTPStep(ctx, id, step)
+}
+
+// MarkEmailVerified implements UserUseCase.
+func (u *userUseCase) MarkEmailVerified(ctx context.Context, id uint, email string) error {
+	return u.repo.MarkEmailVerified(ctx, id, email)
+}
+
+// UpdateUser implements UserUseCase.
+func (u *userUseCase) UpdateUser(ctx context.Context, id uint, changes map[string]interface{}) (dto.UserWithProducts, error) {
+	return u.repo.UpdateByID(ctx, id, changes)
+}
+
+// RevokeTokens implements UserUseCase.
+func (u *userUseCase) RevokeTokens(ctx context.Context, id uint) error {
+	return u.repo.RevokeTokens(ctx, id)
+}
+
+// CheckToken implements UserUseCase.
+func (u *userUseCase) CheckToken(ctx context.Context, id, version uint) error {
+	user, err := u.repo.FindTokenState(ctx, id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if user.DeactivatedAt != nil || user.TokenVersion != version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
 
-	res := <-resultChan
-	return res.user, res.err
+// AnonymizeUser implements UserUseCase.
+func (u *userUseCase) AnonymizeUser(ctx context.Context, id uint, record entity.ErasureRecord) error {
+	return u.repo.Anonymize(ctx, id, record)
 }
 
 func NewUserUseCase(repo repository.UserRepository) UserUseCase {
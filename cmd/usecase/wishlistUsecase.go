@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/altsaqif/go-rest/cmd/entity/dto"
+	"github.com/altsaqif/go-rest/cmd/repository"
+)
+
+type WishlistUseCase interface {
+	FindWishlist(ctx context.Context, userID uint) ([]dto.WishlistItemResponseDto, error)
+	AddToWishlist(ctx context.Context, userID, productID uint) (dto.WishlistItemResponseDto, error)
+	RemoveFromWishlist(ctx context.Context, userID, productID uint) error
+}
+
+type wishlistUseCase struct {
+	repo repository.WishlistRepository
+}
+
+// FindWishlist implements WishlistUseCase.
+func (w *wishlistUseCase) FindWishlist(ctx context.Context, userID uint) ([]dto.WishlistItemResponseDto, error) {
+	items, err := w.repo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responseItems := make([]dto.WishlistItemResponseDto, len(items))
+	for i, item := range items {
+		responseItems[i] = dto.ConvertWishlistItemToResponse(item)
+	}
+	return responseItems, nil
+}
+
+// AddToWishlist implements WishlistUseCase.
+func (w *wishlistUseCase) AddToWishlist(ctx context.Context, userID, productID uint) (dto.WishlistItemResponseDto, error) {
+	item, err := w.repo.Add(ctx, userID, productID)
+	if err != nil {
+		return dto.WishlistItemResponseDto{}, err
+	}
+	return dto.ConvertWishlistItemToResponse(item), nil
+}
+
+// RemoveFromWishlist implements WishlistUseCase.
+func (w *wishlistUseCase) RemoveFromWishlist(ctx context.Context, userID, productID uint) error {
+	return w.repo.Remove(ctx, userID, productID)
+}
+
+func NewWishlistUseCase(repo repository.WishlistRepository) WishlistUseCase {
+	return &wishlistUseCase{repo: repo}
+}
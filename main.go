@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/altsaqif/go-rest/cmd/delivery"
 )
@@ -17,7 +17,7 @@ import (
 // @BasePath /api/v1
 
 func main() {
-	log.Println("Starting REST API with GIN")
+	slog.Info("Starting REST API with GIN")
 	srv := delivery.NewServer()
 	srv.Run()
 }